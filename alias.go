@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 收件人匹配方式
+const (
+	MatchedByEmail     = "email"            // 完整邮箱地址
+	MatchedByTeam      = "team"             // 团队收件人
+	MatchedByEName     = "e_name"           // 英文名精确匹配
+	MatchedByAlias     = "alias"            // 别名表
+	MatchedByFuzzyName = "fuzzy_e_name"     // 英文名忽略大小写和空格
+	MatchedByName      = "name"             // 中文姓名
+	MatchedByLocalPart = "email_local_part" // 邮箱 @ 前的部分
+)
+
+// identityIndex 字段名：模糊匹配时依次尝试
+const (
+	identityFieldEName     = "e_name"
+	identityFieldName      = "name"
+	identityFieldLocalPart = "email_local_part"
+)
+
+// fuzzyMatchOrder 模糊匹配顺序，某一步匹配到多个用户时判定为歧义，不再继续
+var fuzzyMatchOrder = []struct {
+	field     string
+	matchedBy string
+}{
+	{identityFieldEName, MatchedByFuzzyName},
+	{identityFieldName, MatchedByName},
+	{identityFieldLocalPart, MatchedByLocalPart},
+}
+
+// UserAlias 收件人别名
+type UserAlias struct {
+	ID        int       `json:"id"`
+	Alias     string    `json:"alias"`
+	EName     string    `json:"e_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserAliasRequest 新增别名请求
+type UserAliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+	EName string `json:"e_name" binding:"required"`
+}
+
+// RecipientResolution 收件人解析结果
+type RecipientResolution struct {
+	Input      string   `json:"input"`
+	EName      string   `json:"e_name,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Email      string   `json:"email,omitempty"`
+	MatchedBy  string   `json:"matched_by,omitempty"`
+	Resolver   string   `json:"resolver,omitempty"`
+	Found      bool     `json:"found"` // 是否解析到可用的邮箱或团队
+	Ambiguous  bool     `json:"ambiguous,omitempty"`
+	Candidates []string `json:"candidates,omitempty"` // 歧义时匹配到的英文名
+}
+
+// identityIndex 用户模糊匹配索引，键为归一化后的英文名、姓名和邮箱前缀
+type identityIndex struct {
+	fields map[string]map[string][]UserInfo
+}
+
+var (
+	aliasesMu sync.RWMutex
+	aliases   = map[string]string{} // 归一化别名 -> 英文名
+)
+
+// normalizeIdentity 归一化用于模糊匹配的字符串：转小写并去掉空白
+func normalizeIdentity(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
+
+// newIdentityIndex 根据用户列表建立模糊匹配索引
+func newIdentityIndex(users []UserInfo) *identityIndex {
+	idx := &identityIndex{fields: map[string]map[string][]UserInfo{
+		identityFieldEName:     {},
+		identityFieldName:      {},
+		identityFieldLocalPart: {},
+	}}
+	add := func(field, value string, user UserInfo) {
+		if key := normalizeIdentity(value); key != "" {
+			idx.fields[field][key] = append(idx.fields[field][key], user)
+		}
+	}
+	for _, user := range users {
+		add(identityFieldEName, user.EName, user)
+		add(identityFieldName, user.Name, user)
+		if at := strings.Index(user.Email, "@"); at > 0 {
+			add(identityFieldLocalPart, user.Email[:at], user)
+		}
+	}
+	return idx
+}
+
+// lookup 按字段查找归一化键对应的用户
+func (idx *identityIndex) lookup(field, key string) []UserInfo {
+	if idx == nil {
+		return nil
+	}
+	return idx.fields[field][key]
+}
+
+// createUserAliasTable 创建收件人别名表
+func createUserAliasTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS user_aliases (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		alias VARCHAR(255) NOT NULL,
+		e_name VARCHAR(100) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE KEY uk_alias (alias),
+		INDEX idx_e_name (e_name)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// GetUserAliases 查询别名，eName 为空时返回全部
+func GetUserAliases(eName string) ([]UserAlias, error) {
+	query := `SELECT id, alias, e_name, created_at FROM user_aliases`
+	var args []interface{}
+	if eName != "" {
+		query += ` WHERE e_name = ?`
+		args = append(args, eName)
+	}
+	query += ` ORDER BY e_name, alias`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		LogDatabase("SELECT", "user_aliases", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询别名失败: %v", err)
+	}
+	defer rows.Close()
+
+	list := []UserAlias{}
+	for rows.Next() {
+		var alias UserAlias
+		if err := rows.Scan(&alias.ID, &alias.Alias, &alias.EName, &alias.CreatedAt); err != nil {
+			LogDatabase("SELECT", "user_aliases", false, err.Error(), 0)
+			return nil, fmt.Errorf("扫描别名失败: %v", err)
+		}
+		list = append(list, alias)
+	}
+
+	LogDatabase("SELECT", "user_aliases", true, "", int64(len(list)))
+	return list, nil
+}
+
+// InsertUserAlias 新增别名
+func InsertUserAlias(alias *UserAlias) error {
+	result, err := db.Exec(`INSERT INTO user_aliases (alias, e_name) VALUES (?, ?)`, alias.Alias, alias.EName)
+	if err != nil {
+		LogDatabase("INSERT", "user_aliases", false, err.Error(), 0)
+		return fmt.Errorf("新增别名失败: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		LogDatabase("INSERT", "user_aliases", false, err.Error(), 0)
+		return fmt.Errorf("获取插入ID失败: %v", err)
+	}
+	alias.ID = int(id)
+	alias.CreatedAt = time.Now()
+	LogDatabase("INSERT", "user_aliases", true, "", 1)
+	return nil
+}
+
+// DeleteUserAlias 删除别名，返回是否存在
+func DeleteUserAlias(alias string) (bool, error) {
+	result, err := db.Exec(`DELETE FROM user_aliases WHERE alias = ?`, alias)
+	if err != nil {
+		LogDatabase("DELETE", "user_aliases", false, err.Error(), 0)
+		return false, fmt.Errorf("删除别名失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("DELETE", "user_aliases", true, "", affected)
+	return affected > 0, nil
+}
+
+// InitAliases 从数据库加载别名缓存
+func InitAliases() error {
+	list, err := GetUserAliases("")
+	if err != nil {
+		return err
+	}
+
+	byAlias := make(map[string]string, len(list))
+	for _, alias := range list {
+		byAlias[normalizeIdentity(alias.Alias)] = alias.EName
+	}
+
+	aliasesMu.Lock()
+	aliases = byAlias
+	aliasesMu.Unlock()
+
+	LogSystem(logrus.InfoLevel, "users", "收件人别名加载成功", map[string]interface{}{
+		"alias_count": len(byAlias),
+	})
+	log.Printf("收件人别名加载成功，共 %d 个", len(byAlias))
+	return nil
+}
+
+// lookupAlias 根据别名查找英文名，忽略大小写和空格
+func lookupAlias(input string) (string, bool) {
+	aliasesMu.RLock()
+	defer aliasesMu.RUnlock()
+	eName, ok := aliases[normalizeIdentity(input)]
+	return eName, ok
+}
+
+// searchUsers 在所有解析器中模糊查找，同一英文名只保留优先级最高的解析器的结果
+func searchUsers(field, key string) ([]UserInfo, []string) {
+	resolversMu.RLock()
+	chain := resolvers
+	resolversMu.RUnlock()
+
+	seen := make(map[string]bool)
+	var users []UserInfo
+	var names []string
+	for _, resolver := range chain {
+		for _, user := range resolver.Search(field, key) {
+			id := strings.ToLower(user.EName)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			users = append(users, user)
+			names = append(names, resolver.Name())
+		}
+	}
+	return users, names
+}
+
+// resolveRecipient 解析收件人：邮箱、团队收件人、英文名精确匹配、别名，
+// 最后依次按英文名（忽略大小写和空格）、中文姓名、邮箱前缀模糊匹配，匹配到多个用户时判定为歧义
+func resolveRecipient(input string) RecipientResolution {
+	input = strings.TrimSpace(input)
+	result := RecipientResolution{Input: input}
+	if input == "" {
+		return result
+	}
+
+	if strings.Contains(input, "@") {
+		result.Email = input
+		result.MatchedBy = MatchedByEmail
+		result.Found = true
+		return result
+	}
+	if isTeamRecipient(input) {
+		result.MatchedBy = MatchedByTeam
+		result.Found = validateTeamRecipient(input) == nil
+		return result
+	}
+
+	fill := func(user UserInfo, matchedBy, resolver string) RecipientResolution {
+		result.EName = user.EName
+		result.Name = user.Name
+		result.Email = user.Email
+		result.MatchedBy = matchedBy
+		result.Resolver = resolver
+		result.Found = user.Email != ""
+		return result
+	}
+
+	if user, resolver, ok := resolveUser(input); ok {
+		return fill(user, MatchedByEName, resolver)
+	}
+	if eName, ok := lookupAlias(input); ok {
+		if user, resolver, ok := resolveUser(eName); ok {
+			return fill(user, MatchedByAlias, resolver)
+		}
+	}
+
+	key := normalizeIdentity(input)
+	for _, step := range fuzzyMatchOrder {
+		users, resolverNames := searchUsers(step.field, key)
+		if len(users) == 1 {
+			return fill(users[0], step.matchedBy, resolverNames[0])
+		}
+		if len(users) > 1 {
+			result.Ambiguous = true
+			for _, user := range users {
+				result.Candidates = append(result.Candidates, user.EName)
+			}
+			LogSystem(logrus.WarnLevel, "users", "收件人匹配到多个用户", map[string]interface{}{
+				"recipient":  input,
+				"matched_by": step.matchedBy,
+				"candidates": result.Candidates,
+			})
+			return result
+		}
+	}
+	return result
+}
+
+// canonicalRecipient 将唯一匹配到用户的收件人统一为英文名，其他情况保持原样
+func canonicalRecipient(input string) string {
+	resolution := resolveRecipient(input)
+	if resolution.EName == "" || resolution.Ambiguous {
+		return input
+	}
+	return resolution.EName
+}
+
+// GetUserAliasesHandler 查询别名，支持 e_name 过滤
+func GetUserAliasesHandler(c *gin.Context) {
+	list, err := GetUserAliases(c.Query("e_name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_aliases_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_aliases_ok"),
+		"data":    list,
+		"total":   len(list),
+	})
+}
+
+// CreateUserAliasHandler 新增别名，别名需唯一且指向已存在的用户
+func CreateUserAliasHandler(c *gin.Context) {
+	var req UserAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+
+	alias := UserAlias{Alias: strings.TrimSpace(req.Alias), EName: strings.TrimSpace(req.EName)}
+	if alias.Alias == "" || strings.ContainsAny(alias.Alias, "@,，") || isTeamRecipient(alias.Alias) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.alias_invalid"),
+		})
+		return
+	}
+	if _, _, ok := resolveUser(alias.EName); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.user_not_found_name", alias.EName),
+		})
+		return
+	}
+	if eName, ok := lookupAlias(alias.Alias); ok {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": apiMessage(c, "api.alias_exists", eName),
+		})
+		return
+	}
+
+	if err := InsertUserAlias(&alias); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.create_alias_failed", err),
+		})
+		return
+	}
+	reloadAliases()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": apiMessage(c, "api.create_alias_ok"),
+		"data":    alias,
+	})
+}
+
+// DeleteUserAliasHandler 删除别名
+func DeleteUserAliasHandler(c *gin.Context) {
+	found, err := DeleteUserAlias(c.Param("alias"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.delete_alias_failed", err),
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": apiMessage(c, "api.alias_not_found"),
+		})
+		return
+	}
+	reloadAliases()
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.delete_alias_ok"),
+	})
+}
+
+// reloadAliases 别名变更后刷新缓存，失败时仅记录日志
+func reloadAliases() {
+	if err := InitAliases(); err != nil {
+		LogSystem(logrus.WarnLevel, "users", "刷新别名缓存失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// ResolveRecipientsHandler 预览收件人字符串的解析结果，支持逗号分隔的多个收件人
+func ResolveRecipientsHandler(c *gin.Context) {
+	input := c.Query("recipient")
+	if strings.TrimSpace(input) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.recipient_required"),
+		})
+		return
+	}
+
+	input = strings.ReplaceAll(input, "，", ",")
+	results := []RecipientResolution{}
+	for _, part := range strings.Split(input, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		results = append(results, resolveRecipient(part))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.resolve_recipient_ok"),
+		"data":    results,
+	})
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 汇总邮件附件格式
+const (
+	AttachmentNone = "none" // 不附带告警列表
+	AttachmentCSV  = "csv"
+	AttachmentXLSX = "xlsx"
+)
+
+// csvContentType CSV 文件的 MIME 类型
+const csvContentType = "text/csv; charset=utf-8"
+
+// utf8BOM 写在 CSV 开头，Excel 才能正确识别中文
+const utf8BOM = "\xEF\xBB\xBF"
+
+// attachmentColumns 告警列表附件的列
+var attachmentColumns = []string{"id", "time", "severity", "source", "domain", "message"}
+
+// emailAttachment 邮件附件，Content 序列化为 base64
+type emailAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     []byte `json:"content"`
+}
+
+// AttachmentInfo 附件概要，用于预览和日志
+type AttachmentInfo struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+}
+
+// info 附件概要
+func (a emailAttachment) info() AttachmentInfo {
+	return AttachmentInfo{Filename: a.Filename, ContentType: a.ContentType, Size: len(a.Content)}
+}
+
+// validAttachmentFormat 校验附件格式，为空表示使用默认配置
+func validAttachmentFormat(format string) bool {
+	switch format {
+	case "", AttachmentNone, AttachmentCSV, AttachmentXLSX:
+		return true
+	}
+	return false
+}
+
+// attachmentFormatFor 收件人的附件格式：用户偏好优先，未设置时使用 EMAIL_ATTACHMENT_FORMAT
+func attachmentFormatFor(recipient string) string {
+	if pref := preferencesFor(recipient); pref != nil && pref.Attachment != "" {
+		return pref.Attachment
+	}
+	if format := currentEmailConfig().AttachmentFormat; format != "" {
+		return format
+	}
+	return AttachmentNone
+}
+
+// alertRow 告警在附件中的一行
+func alertRow(alert Alert) []string {
+	return []string{
+		strconv.Itoa(alert.ID),
+		formatDisplayTime(alert.AlertTime),
+		alert.Severity,
+		alert.Source,
+		alert.Domain,
+		alert.Message,
+	}
+}
+
+// writeAlertsCSV 以带 BOM 的 UTF-8 CSV 写入告警列表
+func writeAlertsCSV(w io.Writer, alerts []Alert) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return fmt.Errorf("写入CSV失败: %v", err)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(attachmentColumns); err != nil {
+		return fmt.Errorf("写入CSV失败: %v", err)
+	}
+	for _, alert := range alerts {
+		if err := cw.Write(spreadsheetSafeRow(alertRow(alert))); err != nil {
+			return fmt.Errorf("写入CSV失败: %v", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("写入CSV失败: %v", err)
+	}
+	return nil
+}
+
+// writeAlertsXLSX 以 XLSX 写入告警列表
+func writeAlertsXLSX(w io.Writer, alerts []Alert) error {
+	xw, err := newXLSXWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := xw.WriteRow(attachmentColumns); err != nil {
+		return err
+	}
+	for _, alert := range alerts {
+		if err := xw.WriteRow(alertRow(alert)); err != nil {
+			return err
+		}
+	}
+	return xw.Close()
+}
+
+// filenamePattern 文件名中不安全的字符
+var filenamePattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// buildAlertsAttachment 生成告警列表附件，文件名包含收件人和日期
+func buildAlertsAttachment(recipient string, alerts []Alert, format string) (*emailAttachment, error) {
+	name := fmt.Sprintf("alerts-%s-%s", filenamePattern.ReplaceAllString(recipient, "_"), time.Now().In(serverLocation).Format("20060102"))
+	var buf bytes.Buffer
+	switch format {
+	case AttachmentCSV:
+		if err := writeAlertsCSV(&buf, alerts); err != nil {
+			return nil, err
+		}
+		return &emailAttachment{Filename: name + ".csv", ContentType: csvContentType, Content: buf.Bytes()}, nil
+	case AttachmentXLSX:
+		if err := writeAlertsXLSX(&buf, alerts); err != nil {
+			return nil, err
+		}
+		return &emailAttachment{Filename: name + ".xlsx", ContentType: xlsxContentType, Content: buf.Bytes()}, nil
+	}
+	return nil, fmt.Errorf("不支持的附件格式: %s", format)
+}
+
+// digestAttachmentFor 按收件人偏好为告警较多的邮件生成告警列表附件。
+// 附件超过 EMAIL_ATTACHMENT_MAX_KB 时不附带，改为返回下载链接（未配置 EMAIL_LINK_BASE_URL 时为空）
+func digestAttachmentFor(userAlerts UserAlerts) (*emailAttachment, string) {
+	emailConfig := currentEmailConfig()
+	format := attachmentFormatFor(userAlerts.Recipient)
+	if format == AttachmentNone || len(userAlerts.Alerts) == 0 || len(userAlerts.Alerts) < emailConfig.AttachmentMinAlerts {
+		return nil, ""
+	}
+	if !emailConfig.Attachments {
+		// 网关不支持附件时直接给出下载链接
+		return nil, digestDownloadURL(userAlerts, format)
+	}
+
+	attachment, err := buildAlertsAttachment(userAlerts.Recipient, userAlerts.Alerts, format)
+	if err != nil {
+		LogSystem(logrus.WarnLevel, "email", "生成告警列表附件失败，不附带附件", map[string]interface{}{
+			"recipient": userAlerts.Recipient,
+			"format":    format,
+			"error":     err.Error(),
+		})
+		return nil, ""
+	}
+
+	maxBytes := emailConfig.AttachmentMaxKB * 1024
+	if maxBytes <= 0 || len(attachment.Content) <= maxBytes {
+		return attachment, ""
+	}
+
+	link := digestDownloadURL(userAlerts, format)
+	LogSystem(logrus.InfoLevel, "email", "告警列表附件超过大小限制，改为下载链接", map[string]interface{}{
+		"recipient": userAlerts.Recipient,
+		"size":      len(attachment.Content),
+		"max_bytes": maxBytes,
+		"has_link":  link != "",
+	})
+	return nil, link
+}
+
+// digestDownloadURL 告警列表下载链接，时间范围覆盖邮件中所有告警。
+// recipient 为展开后的具体人员，group 为邮件所在的路由分组，下载时按汇总任务的方式重新筛选出同一批告警
+func digestDownloadURL(userAlerts UserAlerts, format string) string {
+	base := strings.TrimRight(currentEmailConfig().LinkBaseURL, "/")
+	if base == "" {
+		return ""
+	}
+	start, end := userAlerts.Alerts[0].AlertTime, userAlerts.Alerts[0].AlertTime
+	for _, alert := range userAlerts.Alerts {
+		if alert.AlertTime.Before(start) {
+			start = alert.AlertTime
+		}
+		if alert.AlertTime.After(end) {
+			end = alert.AlertTime
+		}
+	}
+
+	query := url.Values{}
+	query.Set("recipient", userAlerts.Recipient)
+	query.Set("start_time", start.Truncate(time.Second).Format(time.RFC3339))
+	query.Set("end_time", end.Truncate(time.Second).Add(time.Second).Format(time.RFC3339))
+	query.Set("format", format)
+	if group := routeGroupKey(&userAlerts.Alerts[0], RouteAlert(&userAlerts.Alerts[0])); group != "" {
+		query.Set("group", group)
+	}
+	return base + "/api/v1/digests/attachment?" + query.Encode()
+}
+
+// digestAlertsFor 按汇总任务的方式取出人员在时间范围内收到的告警：团队收件人展开后合并，
+// 去掉已静默、已抑制和已立即发送成功的告警；group 不为空时只保留该路由分组中的告警
+func digestAlertsFor(recipient string, startTime, endTime time.Time, group string) ([]Alert, error) {
+	userAlertsList, err := GetAlertsGroupedByRecipient(startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []Alert
+	for _, userAlerts := range expandTeamRecipients(userAlertsList) {
+		if userAlerts.Recipient != recipient {
+			continue
+		}
+		for _, alert := range userAlerts.Alerts {
+			// 汇总任务发送时已把静默和抑制结果写回数据库
+			if alert.Silenced || alert.InhibitedBy != 0 {
+				continue
+			}
+			routes := RouteAlert(&alert)
+			if alert.NotifiedAt != nil && sentImmediately(recipient, &alert, routes) {
+				continue
+			}
+			if group != "" && routeGroupKey(&alert, routes) != group {
+				continue
+			}
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+// DigestAttachmentHandler 下载收件人在时间范围内汇总邮件中的告警列表（CSV 或 XLSX），用于无法附带附件时邮件中的下载链接
+func DigestAttachmentHandler(c *gin.Context) {
+	recipient := strings.TrimSpace(c.Query("recipient"))
+	if recipient == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.recipient_param_required"),
+		})
+		return
+	}
+	format := c.DefaultQuery("format", AttachmentCSV)
+	if format != AttachmentCSV && format != AttachmentXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.attachment_format_invalid"),
+		})
+		return
+	}
+	startTime, err := parseFlexibleTime(c.Query("start_time"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_start_time"),
+		})
+		return
+	}
+	endTime, err := parseFlexibleTime(c.Query("end_time"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_end_time"),
+		})
+		return
+	}
+
+	alerts, err := digestAlertsFor(recipient, startTime, endTime, c.Query("group"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_alerts_failed", err),
+		})
+		return
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].AlertTime.Before(alerts[j].AlertTime) })
+
+	attachment, err := buildAlertsAttachment(recipient, alerts, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.build_attachment_failed", err),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.Filename))
+	c.Data(http.StatusOK, attachment.ContentType, attachment.Content)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Config 配置结构
@@ -14,6 +15,12 @@ type Config struct {
 	Server   ServerConfig
 	Log      LogConfig
 	Cron     CronConfig
+	Report   ReportConfig
+	Ingest   IngestConfig
+	Routing  RoutingConfig
+	Users    UsersConfig
+	LDAP     LDAPConfig
+	Fallback FallbackConfig
 }
 
 // DatabaseConfig 数据库配置
@@ -27,8 +34,11 @@ type DatabaseConfig struct {
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Host string
-	Port string
+	Host     string
+	Port     string
+	Timezone string // 服务器时区，默认 Asia/Shanghai
+	Locale   string // 默认语言（zh-CN / en-US），用于未设置语言偏好的收件人、管理员邮件和未携带 Accept-Language 的接口请求
+	ConfigWatch bool // 是否监听配置文件变化自动重新加载，默认 true
 }
 
 // CronConfig 定时任务配置
@@ -41,6 +51,68 @@ type CronConfig struct {
 	Enabled      bool   // 是否启用定时任务，默认 true
 }
 
+// ReportConfig 周报/月报配置
+type ReportConfig struct {
+	Enabled         bool     // 是否启用周报/月报，默认 false
+	WeeklySchedule  string   // 周报cron表达式，默认 "0 9 * * 1" (每周一早上9点)，为 off 时不发送周报
+	MonthlySchedule string   // 月报cron表达式，默认 "0 9 1 * *" (每月1日早上9点)，为 off 时不发送月报
+	Recipients      []string // 收件人（用户名或邮箱），为空时发给各团队负责人
+	TopN            int      // 告警最多的域名、收件人各列出前 N 个，默认 10
+}
+
+// IngestConfig 告警写入保护配置（限流与告警风暴检测）
+type IngestConfig struct {
+	RateLimitEnabled bool     // 是否启用写入限流，默认 true
+	RatePerMinute    int      // 每个来源每分钟允许写入的告警数（批量请求按条目计），默认 60
+	Burst            int      // 令牌桶容量（允许的突发告警数），默认 20
+	APIKeys          []string // 作为限流维度的 X-API-Key，不在列表中的 Key 按来源或IP限流
+	StormEnabled     bool     // 是否启用告警风暴检测，默认 true
+	StormWindow      int      // 风暴检测窗口（秒），默认 300
+	StormThreshold   int      // 窗口内相似告警超过该数量视为风暴，默认 10
+	IdempotencyTTL   int      // 幂等键保留时间（小时），默认 24
+}
+
+// RoutingConfig 路由规则配置
+type RoutingConfig struct {
+	File        string // 路由规则文件路径，默认 routing.json，不存在时使用默认路由
+	InhibitFile string // 抑制规则文件路径，默认 inhibit_rules.json，不存在时不启用抑制
+	EscalationFile     string // 升级策略文件路径，默认 escalation_policies.json，不存在时不启用升级
+	EscalationInterval int    // 升级检查间隔（秒），默认 60
+	TeamsFile          string // 团队与值班轮换配置文件路径，默认 teams.json，不存在时不启用团队收件人
+}
+
+// UsersConfig 用户目录配置
+type UsersConfig struct {
+	SeedFile string // 用户表为空时导入的用户列表文件，默认 userlist.json
+}
+
+// FallbackConfig 兜底收件人配置
+type FallbackConfig struct {
+	Recipients      []string // 收件人未找到时的默认兜底邮箱，默认 liyongchang@kugou.net
+	AdminRecipients []string // 系统通知（告警风暴、升级到管理员）收件人，默认同 Recipients
+	Webhook         string   // 默认兜底群机器人地址（企业微信/钉钉文本消息），为空时不发送
+	RulesFile       string   // 按来源/团队的兜底规则文件，默认 fallback_rules.json，不存在时全部使用默认兜底
+	UnknownPolicy   string   // 未知收件人策略：fallback（发送给兜底收件人）或 reject（写入时返回422），默认 fallback
+	CreateTask      bool     // 是否为未解析收件人自动创建待处理任务，默认 false
+}
+
+// LDAPConfig LDAP用户目录配置
+type LDAPConfig struct {
+	Enabled            bool   // 是否启用LDAP解析收件人，默认 false
+	URL                string // 如 ldap://ldap.example.com:389 或 ldaps://ldap.example.com:636
+	BindDN             string // 绑定账号，为空时匿名查询
+	BindPassword       string
+	BaseDN             string // 搜索根节点
+	Filter             string // 用户过滤条件，默认 (objectClass=person)
+	ENameAttr          string // 英文名属性，默认 sAMAccountName
+	NameAttr           string // 姓名属性，默认 displayName
+	EmailAttr          string // 邮箱属性，默认 mail
+	StartTLS           bool   // 是否使用 StartTLS
+	InsecureSkipVerify bool   // 是否跳过证书校验
+	TimeoutSeconds     int    // 连接和查询超时（秒），默认 10
+	SyncMinutes        int    // 同步间隔（分钟），默认 60，0 表示仅启动时同步
+}
+
 // LoadConfig 加载配置
 func LoadConfig() *Config {
 	// 加载.env文件
@@ -62,10 +134,28 @@ func LoadConfig() *Config {
 			To:          []string{}, // 不再使用固定收件人列表
 			DebugMode:   getEnvAsBool("EMAIL_DEBUG_MODE", false),
 			DebugAPIUrl: getEnv("EMAIL_DEBUG_API_URL", "http://10.16.2.146:6709/mail/email/send_email.php"),
+			TemplateDir: getEnv("EMAIL_TEMPLATE_DIR", "templates"),
+			SendWorkers:          getEnvAsInt("EMAIL_SEND_WORKERS", 5),               // 并发发送数
+			GatewayRatePerMinute: getEnvAsInt("EMAIL_GATEWAY_RATE_PER_MINUTE", 600), // 每个网关每分钟请求数
+			RequestTimeout:       getEnvAsInt("EMAIL_REQUEST_TIMEOUT", 30),           // 单次请求超时（秒）
+			SendDeadline:         getEnvAsInt("EMAIL_SEND_DEADLINE", 600),            // 批量发送整体截止时间（秒）
+			Multipart:            getEnvAsBool("EMAIL_MULTIPART_ENABLED", false),     // 网关是否支持 multipart 正文
+			PlainText:            getEnvAsBool("EMAIL_PLAIN_TEXT_ENABLED", false),    // 网关是否支持 plain 正文
+			Attachments:          getEnvAsBool("EMAIL_ATTACHMENTS_ENABLED", false),   // 网关是否支持附件
+			AttachmentFormat:     getEnv("EMAIL_ATTACHMENT_FORMAT", AttachmentNone),  // 告警列表附件默认格式
+			AttachmentMinAlerts:  getEnvAsInt("EMAIL_ATTACHMENT_MIN_ALERTS", 50),     // 告警数达到该值时附带附件
+			AttachmentMaxKB:      getEnvAsInt("EMAIL_ATTACHMENT_MAX_KB", 5120),       // 附件大小上限（KB）
+			LinkBaseURL:          getEnv("EMAIL_LINK_BASE_URL", ""),                  // 邮件中下载链接的服务地址
+			TestAllowedDomains:   getEnvAsSlice("TEST_EMAIL_ALLOWED_DOMAINS", []string{}), // 测试通知允许直接填写的邮箱域名
+			TestMaxRecipients:    getEnvAsInt("TEST_EMAIL_MAX_RECIPIENTS", 10),            // 测试通知最多收件人数
+			TestMaxMessages:      getEnvAsInt("TEST_EMAIL_MAX_MESSAGES", 10),              // 测试通知最多告警条数
 		},
 		Server: ServerConfig{
 			Host: getEnv("SERVER_HOST", "0.0.0.0"),
 			Port: getEnv("SERVER_PORT", "8080"),
+			Timezone: getEnv("SERVER_TIMEZONE", "Asia/Shanghai"),
+			Locale: getEnv("SERVER_LOCALE", LocaleZhCN),
+			ConfigWatch: getEnvAsBool("CONFIG_WATCH_ENABLED", true),
 		},
 		Log: LogConfig{
 			Level:      getEnv("LOG_LEVEL", "info"),
@@ -84,33 +174,116 @@ func LoadConfig() *Config {
 			EndMinute:   getEnvAsInt("CRON_END_MINUTE", 0),         // 查询结束分钟：0分
 			Enabled:     getEnvAsBool("CRON_ENABLED", true),        // 是否启用定时任务
 		},
+		Report: ReportConfig{
+			Enabled:         getEnvAsBool("REPORT_ENABLED", false),
+			WeeklySchedule:  getEnv("REPORT_WEEKLY_SCHEDULE", "0 9 * * 1"),  // 每周一早上9点，统计上一周
+			MonthlySchedule: getEnv("REPORT_MONTHLY_SCHEDULE", "0 9 1 * *"), // 每月1日早上9点，统计上一个月
+			Recipients:      getEnvAsSlice("REPORT_RECIPIENTS", nil),
+			TopN:            getEnvAsInt("REPORT_TOP_N", 10),
+		},
+		Ingest: IngestConfig{
+			RateLimitEnabled: getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			RatePerMinute:    getEnvAsInt("RATE_LIMIT_PER_MINUTE", 60),
+			Burst:            getEnvAsInt("RATE_LIMIT_BURST", 20),
+			APIKeys:          getEnvAsSlice("RATE_LIMIT_API_KEYS", nil),
+			StormEnabled:     getEnvAsBool("STORM_ENABLED", true),
+			StormWindow:      getEnvAsInt("STORM_WINDOW_SECONDS", 300),
+			StormThreshold:   getEnvAsInt("STORM_THRESHOLD", 10),
+			IdempotencyTTL:   getEnvAsInt("IDEMPOTENCY_TTL_HOURS", 24),
+		},
+		Routing: RoutingConfig{
+			File:        getEnv("ROUTING_FILE", "routing.json"),
+			InhibitFile: getEnv("INHIBIT_RULES_FILE", "inhibit_rules.json"),
+			EscalationFile:     getEnv("ESCALATION_POLICIES_FILE", "escalation_policies.json"),
+			EscalationInterval: getEnvAsInt("ESCALATION_CHECK_SECONDS", 60),
+			TeamsFile:          getEnv("TEAMS_FILE", "teams.json"),
+		},
+		Users: UsersConfig{
+			SeedFile: getEnv("USERLIST_FILE", "userlist.json"),
+		},
+		LDAP: LDAPConfig{
+			Enabled:            getEnvAsBool("LDAP_ENABLED", false),
+			URL:                getEnv("LDAP_URL", ""),
+			BindDN:             getEnv("LDAP_BIND_DN", ""),
+			BindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+			BaseDN:             getEnv("LDAP_BASE_DN", ""),
+			Filter:             getEnv("LDAP_USER_FILTER", "(objectClass=person)"),
+			ENameAttr:          getEnv("LDAP_ATTR_ENAME", "sAMAccountName"),
+			NameAttr:           getEnv("LDAP_ATTR_NAME", "displayName"),
+			EmailAttr:          getEnv("LDAP_ATTR_EMAIL", "mail"),
+			StartTLS:           getEnvAsBool("LDAP_START_TLS", false),
+			InsecureSkipVerify: getEnvAsBool("LDAP_INSECURE_SKIP_VERIFY", false),
+			TimeoutSeconds:     getEnvAsInt("LDAP_TIMEOUT_SECONDS", 10),
+			SyncMinutes:        getEnvAsInt("LDAP_SYNC_MINUTES", 60),
+		},
+		Fallback: FallbackConfig{
+			Recipients:      getEnvAsSlice("FALLBACK_RECIPIENTS", []string{"liyongchang@kugou.net"}),
+			AdminRecipients: getEnvAsSlice("ADMIN_RECIPIENTS", nil),
+			Webhook:         getEnv("FALLBACK_WEBHOOK", ""),
+			RulesFile:       getEnv("FALLBACK_RULES_FILE", "fallback_rules.json"),
+			UnknownPolicy:   getEnv("UNKNOWN_RECIPIENT_POLICY", UnknownRecipientFallback),
+			CreateTask:      getEnvAsBool("UNRESOLVED_TASK_ENABLED", false),
+		},
 	}
 	
 	return config
 }
 
-// loadEnvFile 加载.env文件
+// envFile 环境变量配置文件
+const envFile = ".env"
+
+// envOriginal 被 .env 覆盖前的环境变量取值
+type envOriginal struct {
+	value string
+	set   bool
+}
+
+// envFileKeys 记录当前由 .env 设置的变量，重新加载时恢复已从 .env 删除的变量
+var (
+	envFileMu   sync.Mutex
+	envFileKeys = make(map[string]envOriginal)
+)
+
+// loadEnvFile 加载.env文件，上次加载后从文件中删除的变量恢复为原来的取值（原来未设置则清除）
 func loadEnvFile() {
-	file, err := os.Open(".env")
-	if err != nil {
-		// .env文件不存在，使用环境变量
-		return
+	envFileMu.Lock()
+	defer envFileMu.Unlock()
+
+	values := make(map[string]string)
+	if file, err := os.Open(envFile); err == nil {
+		// .env文件不存在时只使用环境变量
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) == 2 {
+				values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+		}
+		file.Close()
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+	for key, original := range envFileKeys {
+		if _, ok := values[key]; ok {
 			continue
 		}
-
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			os.Setenv(key, value)
+		if original.set {
+			os.Setenv(key, original.value)
+		} else {
+			os.Unsetenv(key)
+		}
+		delete(envFileKeys, key)
+	}
+	for key, value := range values {
+		if _, ok := envFileKeys[key]; !ok {
+			original, set := os.LookupEnv(key)
+			envFileKeys[key] = envOriginal{value: original, set: set}
 		}
+		os.Setenv(key, value)
 	}
 }
 
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// cronJobAlertNotification 汇总邮件定时任务名称
+const cronJobAlertNotification = "alert_notification"
+
+// CronRun 定时任务执行记录，包含每个收件人的发送结果
+type CronRun struct {
+	ID           int64                 `json:"id"`
+	JobName      string                `json:"job_name"`
+	StartedAt    time.Time             `json:"started_at"`
+	FinishedAt   time.Time             `json:"finished_at"`
+	DurationMs   int64                 `json:"duration_ms"`
+	Success      bool                  `json:"success"`
+	Message      string                `json:"message"`
+	SuccessCount int                   `json:"success_count"`
+	FailCount    int                   `json:"fail_count"`
+	Results      []RecipientSendResult `json:"results,omitempty"` // 列表接口不返回，查询单条记录时返回
+}
+
+// startCronRun 开始记录一次定时任务执行
+func startCronRun(jobName string) *CronRun {
+	return &CronRun{JobName: jobName, StartedAt: time.Now()}
+}
+
+// finish 结束本次执行：记录定时任务日志并保存执行记录，保存失败只记录日志
+func (r *CronRun) finish(success bool, message string, result *SendResult) {
+	r.FinishedAt = time.Now()
+	duration := r.FinishedAt.Sub(r.StartedAt)
+	r.DurationMs = duration.Milliseconds()
+	r.Success = success
+	r.Message = message
+	if result != nil {
+		r.Results = result.Recipients
+		r.FailCount = len(result.Failed())
+		r.SuccessCount = len(result.Recipients) - r.FailCount
+	}
+
+	LogCronJob(r.JobName, success, message, duration.String())
+	if err := InsertCronRun(r); err != nil {
+		LogSystem(logrus.WarnLevel, "cron", "保存定时任务执行记录失败", map[string]interface{}{
+			"job_name": r.JobName,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// createCronRunTable 创建定时任务执行记录表
+func createCronRunTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS cron_runs (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		job_name VARCHAR(100) NOT NULL,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME NOT NULL,
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		success TINYINT(1) NOT NULL DEFAULT 0,
+		message TEXT,
+		success_count INT NOT NULL DEFAULT 0,
+		fail_count INT NOT NULL DEFAULT 0,
+		results MEDIUMTEXT,
+		INDEX idx_job_started (job_name, started_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// InsertCronRun 保存定时任务执行记录
+func InsertCronRun(run *CronRun) error {
+	results, err := json.Marshal(run.Results)
+	if err != nil {
+		return fmt.Errorf("序列化发送结果失败: %v", err)
+	}
+
+	res, err := db.Exec(`INSERT INTO cron_runs (job_name, started_at, finished_at, duration_ms, success, message, success_count, fail_count, results)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.JobName, run.StartedAt, run.FinishedAt, run.DurationMs, run.Success, run.Message,
+		run.SuccessCount, run.FailCount, string(results))
+	if err != nil {
+		LogDatabase("INSERT", "cron_runs", false, err.Error(), 0)
+		return fmt.Errorf("保存定时任务执行记录失败: %v", err)
+	}
+
+	run.ID, _ = res.LastInsertId()
+	LogDatabase("INSERT", "cron_runs", true, "", 1)
+	return nil
+}
+
+// GetCronRuns 查询最近的定时任务执行记录，jobName 为空时返回全部任务，不含每个收件人的结果
+func GetCronRuns(jobName string, limit int) ([]CronRun, error) {
+	query := `SELECT id, job_name, started_at, finished_at, duration_ms, success, message, success_count, fail_count
+		FROM cron_runs`
+	var args []interface{}
+	if jobName != "" {
+		query += ` WHERE job_name = ?`
+		args = append(args, jobName)
+	}
+	query += ` ORDER BY started_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		LogDatabase("SELECT", "cron_runs", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询定时任务执行记录失败: %v", err)
+	}
+	defer rows.Close()
+
+	runs := []CronRun{}
+	for rows.Next() {
+		var run CronRun
+		var message sql.NullString
+		if err := rows.Scan(&run.ID, &run.JobName, &run.StartedAt, &run.FinishedAt, &run.DurationMs,
+			&run.Success, &message, &run.SuccessCount, &run.FailCount); err != nil {
+			LogDatabase("SELECT", "cron_runs", false, err.Error(), 0)
+			return nil, fmt.Errorf("扫描定时任务执行记录失败: %v", err)
+		}
+		run.Message = message.String
+		runs = append(runs, run)
+	}
+
+	LogDatabase("SELECT", "cron_runs", true, "", int64(len(runs)))
+	return runs, nil
+}
+
+// GetCronRunByID 查询单条定时任务执行记录及每个收件人的发送结果，不存在时返回 nil
+func GetCronRunByID(id int64) (*CronRun, error) {
+	var run CronRun
+	var message, results sql.NullString
+	err := db.QueryRow(`SELECT id, job_name, started_at, finished_at, duration_ms, success, message, success_count, fail_count, results
+		FROM cron_runs WHERE id = ?`, id).Scan(&run.ID, &run.JobName, &run.StartedAt, &run.FinishedAt, &run.DurationMs,
+		&run.Success, &message, &run.SuccessCount, &run.FailCount, &results)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		LogDatabase("SELECT", "cron_runs", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询定时任务执行记录失败: %v", err)
+	}
+
+	run.Message = message.String
+	if results.String != "" {
+		if err := json.Unmarshal([]byte(results.String), &run.Results); err != nil {
+			return nil, fmt.Errorf("解析发送结果失败: %v", err)
+		}
+	}
+	LogDatabase("SELECT", "cron_runs", true, "", 1)
+	return &run, nil
+}
+
+// GetCronRunsHandler 查询定时任务执行历史，支持 job_name 过滤和 limit（默认20，最大200）
+func GetCronRunsHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.cron_limit_invalid"),
+		})
+		return
+	}
+
+	runs, err := GetCronRuns(c.Query("job_name"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_cron_runs_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_cron_runs_ok"),
+		"data":    runs,
+	})
+}
+
+// GetCronRunHandler 查询单次执行记录，包含每个收件人的发送结果
+func GetCronRunHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.cron_run_id_invalid"),
+		})
+		return
+	}
+
+	run, err := GetCronRunByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_cron_runs_failed", err),
+		})
+		return
+	}
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": apiMessage(c, "api.cron_run_not_found"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_cron_runs_ok"),
+		"data":    run,
+	})
+}
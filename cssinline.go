@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// 邮件客户端（尤其是 Gmail、Outlook 和手机客户端）经常忽略 <style> 中的样式，
+// 渲染后的 HTML 邮件把能内联的样式写入元素的 style 属性，其余规则（伪类、@media 等）保留在 <style> 中
+
+// cssCompound 简单选择器，如 div、.header、h4.title、#main
+type cssCompound struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// cssDeclaration 样式声明
+type cssDeclaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+// cssRule 可内联的样式规则，selector 为后代选择器链
+type cssRule struct {
+	selector    []cssCompound
+	specificity int
+	order       int
+	decls       []cssDeclaration
+}
+
+var cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// parseCSS 解析样式表，返回可内联的规则和需要保留在 <style> 中的其余内容
+func parseCSS(css string) ([]cssRule, string) {
+	css = cssCommentPattern.ReplaceAllString(css, "")
+	var rules []cssRule
+	var rest strings.Builder
+
+	for {
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break
+		}
+		selectorText := strings.TrimSpace(css[:open])
+
+		// @media 等规则块可能嵌套，按括号配对整体保留
+		if strings.HasPrefix(selectorText, "@") {
+			depth, end := 0, len(css)
+			for i := open; i < len(css); i++ {
+				if css[i] == '{' {
+					depth++
+				} else if css[i] == '}' {
+					depth--
+					if depth == 0 {
+						end = i + 1
+						break
+					}
+				}
+			}
+			rest.WriteString(strings.TrimSpace(css[:end]) + "\n")
+			css = css[end:]
+			continue
+		}
+
+		closing := strings.Index(css[open:], "}")
+		if closing < 0 {
+			break
+		}
+		body := css[open+1 : open+closing]
+		css = css[open+closing+1:]
+
+		decls := parseDeclarations(body)
+		for _, selector := range strings.Split(selectorText, ",") {
+			selector = strings.TrimSpace(selector)
+			compounds, specificity, ok := parseSelector(selector)
+			if !ok {
+				fmt.Fprintf(&rest, "%s { %s }\n", selector, strings.TrimSpace(body))
+				continue
+			}
+			rules = append(rules, cssRule{selector: compounds, specificity: specificity, order: len(rules), decls: decls})
+		}
+	}
+	return rules, rest.String()
+}
+
+var cssImportantPattern = regexp.MustCompile(`(?i)\s*!\s*important\s*$`)
+
+// parseDeclarations 解析 "color: red; margin: 0" 形式的声明，!important 单独记录
+func parseDeclarations(body string) []cssDeclaration {
+	var decls []cssDeclaration
+	for _, part := range splitDeclarations(body) {
+		colon := strings.Index(part, ":")
+		if colon < 0 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(part[:colon]))
+		value := strings.Join(strings.Fields(part[colon+1:]), " ")
+		important := cssImportantPattern.MatchString(value)
+		if important {
+			value = cssImportantPattern.ReplaceAllString(value, "")
+		}
+		if property != "" && value != "" {
+			decls = append(decls, cssDeclaration{property: property, value: value, important: important})
+		}
+	}
+	return decls
+}
+
+// splitDeclarations 按分号拆分声明，忽略括号和引号中的分号，如 url(data:image/png;base64,...)
+func splitDeclarations(body string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ';' && depth == 0:
+			parts = append(parts, body[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, body[start:])
+}
+
+// parseSelector 解析由简单选择器和后代组合符组成的选择器，伪类、子选择器、属性选择器等不内联
+func parseSelector(selector string) ([]cssCompound, int, bool) {
+	if selector == "" || strings.ContainsAny(selector, ":>+~[*") {
+		return nil, 0, false
+	}
+
+	var compounds []cssCompound
+	specificity := 0
+	for _, field := range strings.Fields(selector) {
+		var c cssCompound
+		for i, part := range splitCompound(field) {
+			switch {
+			case strings.HasPrefix(part, "."):
+				c.classes = append(c.classes, part[1:])
+				specificity += 10
+			case strings.HasPrefix(part, "#"):
+				c.id = part[1:]
+				specificity += 100
+			case i == 0:
+				c.tag = strings.ToLower(part)
+				specificity++
+			}
+		}
+		compounds = append(compounds, c)
+	}
+	return compounds, specificity, true
+}
+
+// splitCompound 将 h4.title#main 拆分为 h4、.title、#main
+func splitCompound(s string) []string {
+	var parts []string
+	start := 0
+	for i := 1; i < len(s); i++ {
+		if s[i] == '.' || s[i] == '#' {
+			parts = append(parts, s[start:i])
+			start = i
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// matches 判断元素是否匹配简单选择器
+func (c cssCompound) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode || (c.tag != "" && n.Data != c.tag) {
+		return false
+	}
+	if c.id != "" && attrValue(n, "id") != c.id {
+		return false
+	}
+	classes := strings.Fields(attrValue(n, "class"))
+	for _, want := range c.classes {
+		found := false
+		for _, class := range classes {
+			if class == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matches 判断元素是否匹配后代选择器链
+func (r cssRule) matches(n *html.Node) bool {
+	last := len(r.selector) - 1
+	if !r.selector[last].matches(n) {
+		return false
+	}
+	i := last - 1
+	for p := n.Parent; p != nil && i >= 0; p = p.Parent {
+		if r.selector[i].matches(p) {
+			i--
+		}
+	}
+	return i < 0
+}
+
+// attrValue 获取元素属性
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// setAttr 设置元素属性
+func setAttr(n *html.Node, key, value string) {
+	for i, attr := range n.Attr {
+		if attr.Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
+
+// inlineCSS 将 <style> 中的规则按优先级写入元素的 style 属性，元素原有的 style 优先级最高
+func inlineCSS(document string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", fmt.Errorf("解析邮件HTML失败: %v", err)
+	}
+
+	var rules []cssRule
+	var styles []*html.Node
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "style" {
+			styles = append(styles, n)
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(doc)
+
+	for _, style := range styles {
+		var css strings.Builder
+		for child := style.FirstChild; child != nil; child = child.NextSibling {
+			css.WriteString(child.Data)
+		}
+		parsed, rest := parseCSS(css.String())
+		for _, rule := range parsed {
+			rule.order = len(rules)
+			rules = append(rules, rule)
+		}
+		if strings.TrimSpace(rest) == "" {
+			style.Parent.RemoveChild(style)
+			continue
+		}
+		for style.FirstChild != nil {
+			style.RemoveChild(style.FirstChild)
+		}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: "\n" + rest})
+	}
+
+	var apply func(n *html.Node)
+	apply = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			var matched []cssRule
+			for _, rule := range rules {
+				if rule.matches(n) {
+					matched = append(matched, rule)
+				}
+			}
+			if len(matched) > 0 {
+				sort.SliceStable(matched, func(i, j int) bool {
+					if matched[i].specificity != matched[j].specificity {
+						return matched[i].specificity < matched[j].specificity
+					}
+					return matched[i].order < matched[j].order
+				})
+				var decls []cssDeclaration
+				for _, rule := range matched {
+					decls = append(decls, rule.decls...)
+				}
+				decls = append(decls, parseDeclarations(attrValue(n, "style"))...)
+				setAttr(n, "style", joinDeclarations(decls))
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			apply(child)
+		}
+	}
+	apply(doc)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return "", fmt.Errorf("生成邮件HTML失败: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// joinDeclarations 合并声明，同一属性后出现的覆盖先出现的，但 !important 的声明只会被后出现的 !important 声明覆盖；
+// 保持属性首次出现的顺序
+func joinDeclarations(decls []cssDeclaration) string {
+	values := make(map[string]cssDeclaration, len(decls))
+	var order []string
+	for _, decl := range decls {
+		current, ok := values[decl.property]
+		if !ok {
+			order = append(order, decl.property)
+		} else if current.important && !decl.important {
+			continue
+		}
+		values[decl.property] = decl
+	}
+	parts := make([]string, 0, len(order))
+	for _, property := range order {
+		decl := values[property]
+		if decl.important {
+			parts = append(parts, property+": "+decl.value+" !important")
+		} else {
+			parts = append(parts, property+": "+decl.value)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSplitDeclarations 括号和引号中的分号不拆分
+func TestSplitDeclarations(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"color: red; margin: 0", []string{"color: red", " margin: 0"}},
+		{"color: red;", []string{"color: red", ""}},
+		{"background: url(data:image/png;base64,AAAA); color: red", []string{"background: url(data:image/png;base64,AAAA)", " color: red"}},
+		{`font-family: "A;B", 'C;D'; color: red`, []string{`font-family: "A;B", 'C;D'`, " color: red"}},
+		{`content: "a\";b"; color: red`, []string{`content: "a\";b"`, " color: red"}},
+		{"width: calc((100% - 10px) / 2); height: 0", []string{"width: calc((100% - 10px) / 2)", " height: 0"}},
+	}
+	for _, tc := range cases {
+		if got := splitDeclarations(tc.body); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitDeclarations(%q) = %q, want %q", tc.body, got, tc.want)
+		}
+	}
+}
+
+// TestJoinDeclarations 后出现的声明覆盖先出现的，!important 只会被 !important 覆盖
+func TestJoinDeclarations(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules string
+		style string
+		want  string
+	}{
+		{"inline wins", "color: red; margin: 0", "color: blue", "color: blue; margin: 0"},
+		{"important beats inline", "color: red !important", "color: blue", "color: red !important"},
+		{"inline important beats important", "color: red !important", "color: blue !important", "color: blue !important"},
+		{"important keeps first order", "color: red !important; margin: 0", "margin: 1px; color: blue", "color: red !important; margin: 1px"},
+		{"case insensitive", "COLOR: red ! IMPORTANT", "color: blue", "color: red !important"},
+	}
+	for _, tc := range cases {
+		decls := append(parseDeclarations(tc.rules), parseDeclarations(tc.style)...)
+		if got := joinDeclarations(decls); got != tc.want {
+			t.Errorf("%s: joinDeclarations = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestInlineCSS 规则按优先级写入 style 属性，伪类和 @media 规则保留在 <style> 中
+func TestInlineCSS(t *testing.T) {
+	cases := []struct {
+		name    string
+		css     string
+		body    string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "descendant",
+			css:  ".alert td { color: red } td { padding: 0 }",
+			body: `<table class="alert"><tr><td>a</td></tr></table><table><tr><td>b</td></tr></table>`,
+			want: []string{`<td style="padding: 0; color: red">a</td>`, `<td style="padding: 0">b</td>`},
+		},
+		{
+			name: "specificity",
+			css:  "#main { color: green } p.title { color: blue } p { color: red }",
+			body: `<p id="main" class="title">a</p><p class="title">b</p>`,
+			want: []string{`<p id="main" class="title" style="color: green">a</p>`, `<p class="title" style="color: blue">b</p>`},
+		},
+		{
+			name: "inline style attribute",
+			css:  "p { color: red !important; margin: 0 }",
+			body: `<p style="color: blue; margin: 4px">a</p>`,
+			want: []string{`<p style="color: red !important; margin: 4px">a</p>`},
+		},
+		{
+			name:    "pseudo class and media kept",
+			css:     "a { color: red } a:hover { color: blue } @media (max-width: 600px) { a { color: green } }",
+			body:    `<a href="#">a</a>`,
+			want:    []string{`<a href="#" style="color: red">a</a>`, "a:hover { color: blue }", "@media (max-width: 600px) { a { color: green } }"},
+			notWant: []string{`style="color: blue"`, `style="color: green"`},
+		},
+		{
+			name:    "style removed when fully inlined",
+			css:     "/* header */ h4 { margin: 0 }",
+			body:    `<h4>a</h4>`,
+			want:    []string{`<h4 style="margin: 0">a</h4>`},
+			notWant: []string{"<style>"},
+		},
+	}
+	for _, tc := range cases {
+		document := "<html><head><style>" + tc.css + "</style></head><body>" + tc.body + "</body></html>"
+		got, err := inlineCSS(document)
+		if err != nil {
+			t.Fatalf("%s: inlineCSS: %v", tc.name, err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: output missing %q:\n%s", tc.name, want, got)
+			}
+		}
+		for _, notWant := range tc.notWant {
+			if strings.Contains(got, notWant) {
+				t.Errorf("%s: output contains %q:\n%s", tc.name, notWant, got)
+			}
+		}
+	}
+}
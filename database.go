@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -17,11 +21,17 @@ func InitDB() error {
 	var err error
 	
 	// 先连接到MySQL服务器（不指定数据库）
-	dsnWithoutDB := fmt.Sprintf("%s:%s@tcp(%s:%d)/?charset=utf8mb4&parseTime=True&loc=Local",
+	// loc 使用服务器时区，保证DATETIME字段的读写与定时任务、邮件展示一致；
+	// time_zone 设置会话时区，使列默认值和 NOW() 写入的时间也按服务器时区
+	loc := url.QueryEscape(serverLocation.String())
+	timeZone := url.QueryEscape(mysqlTimeZone())
+	dsnWithoutDB := fmt.Sprintf("%s:%s@tcp(%s:%d)/?charset=utf8mb4&parseTime=True&loc=%s&time_zone=%s",
 		config.Database.Username,
 		config.Database.Password,
 		config.Database.Host,
-		config.Database.Port)
+		config.Database.Port,
+		loc,
+		timeZone)
 	
 	// 连接MySQL服务器
 	tempDB, err := sql.Open("mysql", dsnWithoutDB)
@@ -44,12 +54,14 @@ func InitDB() error {
 	log.Printf("数据库 %s 创建/确认成功", config.Database.Database)
 	
 	// 现在连接到指定数据库
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=%s&time_zone=%s",
 		config.Database.Username,
 		config.Database.Password,
 		config.Database.Host,
 		config.Database.Port,
-		config.Database.Database)
+		config.Database.Database,
+		loc,
+		timeZone)
 	
 	db, err = sql.Open("mysql", dsn)
 	if err != nil {
@@ -70,6 +82,30 @@ func InitDB() error {
 	if err = createTable(); err != nil {
 		return fmt.Errorf("创建表失败: %v", err)
 	}
+	if err = createIdempotencyTable(); err != nil {
+		return fmt.Errorf("创建幂等键表失败: %v", err)
+	}
+	if err = createSilenceTable(); err != nil {
+		return fmt.Errorf("创建静默规则表失败: %v", err)
+	}
+	if err = createAlertEventTable(); err != nil {
+		return fmt.Errorf("创建告警时间线表失败: %v", err)
+	}
+	if err = createUserTable(); err != nil {
+		return fmt.Errorf("创建用户表失败: %v", err)
+	}
+	if err = createUserAliasTable(); err != nil {
+		return fmt.Errorf("创建别名表失败: %v", err)
+	}
+	if err = createUserPreferenceTable(); err != nil {
+		return fmt.Errorf("创建用户通知偏好表失败: %v", err)
+	}
+	if err = createUnresolvedRecipientTable(); err != nil {
+		return fmt.Errorf("创建未解析收件人表失败: %v", err)
+	}
+	if err = createCronRunTable(); err != nil {
+		return fmt.Errorf("创建定时任务执行记录表失败: %v", err)
+	}
 	
 	log.Println("数据库连接成功")
 	return nil
@@ -89,31 +125,169 @@ func createTable() error {
 		id INT AUTO_INCREMENT PRIMARY KEY,
 		message TEXT NOT NULL,
 		recipient VARCHAR(255) NOT NULL,
+		source VARCHAR(100) NOT NULL DEFAULT '',
+		domain VARCHAR(255) NOT NULL DEFAULT '',
+		severity VARCHAR(20) NOT NULL DEFAULT 'warning',
+		labels TEXT,
+		route VARCHAR(100) NOT NULL DEFAULT '',
+		silence_id INT NOT NULL DEFAULT 0,
+		inhibited_by INT NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL DEFAULT 'open',
+		acknowledged_at DATETIME NULL,
+		acknowledged_by VARCHAR(100) NOT NULL DEFAULT '',
+		resolved_at DATETIME NULL,
+		resolved_by VARCHAR(100) NOT NULL DEFAULT '',
+		escalation_policy VARCHAR(100) NOT NULL DEFAULT '',
+		escalation_step INT NOT NULL DEFAULT 0,
+		escalation_next_at DATETIME NULL,
+		notified_at DATETIME NULL,
 		alert_time DATETIME NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 		INDEX idx_alert_time (alert_time),
-		INDEX idx_recipient (recipient)
+		INDEX idx_recipient (recipient),
+		INDEX idx_escalation_next_at (escalation_next_at)
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 	`
 	
-	_, err := db.Exec(query)
-	return err
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	// 兼容旧表结构：补充后续版本新增的字段
+	columns := []struct{ name, definition string }{
+		{"source", "VARCHAR(100) NOT NULL DEFAULT '' AFTER recipient"},
+		{"domain", "VARCHAR(255) NOT NULL DEFAULT '' AFTER source"},
+		{"severity", "VARCHAR(20) NOT NULL DEFAULT 'warning' AFTER domain"},
+		{"silence_id", "INT NOT NULL DEFAULT 0 AFTER severity"},
+		{"labels", "TEXT AFTER severity"},
+		{"route", "VARCHAR(100) NOT NULL DEFAULT '' AFTER labels"},
+		{"inhibited_by", "INT NOT NULL DEFAULT 0 AFTER silence_id"},
+		{"status", "VARCHAR(20) NOT NULL DEFAULT 'open' AFTER inhibited_by"},
+		{"acknowledged_at", "DATETIME NULL AFTER status"},
+		{"acknowledged_by", "VARCHAR(100) NOT NULL DEFAULT '' AFTER acknowledged_at"},
+		{"resolved_at", "DATETIME NULL AFTER acknowledged_by"},
+		{"resolved_by", "VARCHAR(100) NOT NULL DEFAULT '' AFTER resolved_at"},
+		{"escalation_policy", "VARCHAR(100) NOT NULL DEFAULT '' AFTER resolved_by"},
+		{"escalation_step", "INT NOT NULL DEFAULT 0 AFTER escalation_policy"},
+		{"escalation_next_at", "DATETIME NULL AFTER escalation_step"},
+		{"notified_at", "DATETIME NULL AFTER escalation_next_at"},
+	}
+	for _, column := range columns {
+		if err := ensureColumn("alerts", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureColumn 检查字段是否存在，不存在则添加
+func ensureColumn(table, column, definition string) error {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.COLUMNS 
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("检查字段 %s.%s 失败: %v", table, column, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", table, column, definition)); err != nil {
+		return fmt.Errorf("添加字段 %s.%s 失败: %v", table, column, err)
+	}
+	LogSystem(logrus.InfoLevel, "database", "数据表字段已补充", map[string]interface{}{
+		"table":  table,
+		"column": column,
+	})
+	return nil
+}
+
+// alertColumns 告警查询字段列表，与scanAlert的扫描顺序保持一致
+const alertColumns = "id, message, recipient, source, domain, severity, labels, route, silence_id, inhibited_by, status, " +
+	"acknowledged_at, acknowledged_by, resolved_at, resolved_by, escalation_policy, escalation_step, escalation_next_at, " +
+	"notified_at, alert_time, created_at, updated_at"
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAlert 扫描一行告警信息
+func scanAlert(scanner rowScanner) (Alert, error) {
+	var alert Alert
+	var labels sql.NullString
+	var acknowledgedAt, resolvedAt, escalationNextAt, notifiedAt sql.NullTime
+	err := scanner.Scan(&alert.ID, &alert.Message, &alert.Recipient, &alert.Source,
+		&alert.Domain, &alert.Severity, &labels, &alert.Route, &alert.SilenceID,
+		&alert.InhibitedBy, &alert.Status, &acknowledgedAt, &alert.AcknowledgedBy,
+		&resolvedAt, &alert.ResolvedBy, &alert.EscalationPolicy, &alert.EscalationStep,
+		&escalationNextAt, &notifiedAt, &alert.AlertTime, &alert.CreatedAt, &alert.UpdatedAt)
+	if err != nil {
+		return alert, err
+	}
+	if acknowledgedAt.Valid {
+		alert.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	if resolvedAt.Valid {
+		alert.ResolvedAt = &resolvedAt.Time
+	}
+	if escalationNextAt.Valid {
+		alert.EscalationNextAt = &escalationNextAt.Time
+	}
+	if notifiedAt.Valid {
+		alert.NotifiedAt = &notifiedAt.Time
+	}
+	if labels.String != "" {
+		if err := json.Unmarshal([]byte(labels.String), &alert.Labels); err != nil {
+			return alert, fmt.Errorf("解析告警标签失败: %v", err)
+		}
+	}
+	alert.Silenced = alert.SilenceID != 0
+	return alert, nil
+}
+
+// sqlExecer 可执行写入语句的 *sql.DB 或 *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
 }
 
 // InsertAlert 插入告警信息
 func InsertAlert(alert *Alert) error {
+	return insertAlert(db, alert)
+}
+
+// insertAlert 通过 *sql.DB 或事务插入告警信息
+func insertAlert(ex sqlExecer, alert *Alert) error {
 	LogSystem(logrus.InfoLevel, "database", "准备插入告警信息", map[string]interface{}{
 		"recipient": alert.Recipient,
 		"message": alert.Message,
 	})
 	
 	query := `
-	INSERT INTO alerts (message, recipient, alert_time)
-	VALUES (?, ?, ?)
+	INSERT INTO alerts (message, recipient, source, domain, severity, labels, route, silence_id, status, alert_time, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
-	result, err := db.Exec(query, alert.Message, alert.Recipient, alert.AlertTime)
+	if alert.Status == "" {
+		alert.Status = AlertStatusOpen
+	}
+	// 创建时间由程序写入，与时间线事件、升级和免打扰判断使用同一时钟
+	alert.CreatedAt = time.Now()
+	alert.UpdatedAt = alert.CreatedAt
+	
+	var labels interface{}
+	if len(alert.Labels) > 0 {
+		data, err := json.Marshal(alert.Labels)
+		if err != nil {
+			return fmt.Errorf("序列化告警标签失败: %v", err)
+		}
+		labels = string(data)
+	}
+	
+	result, err := ex.Exec(query, alert.Message, alert.Recipient, alert.Source,
+		alert.Domain, alert.Severity, labels, alert.Route, alert.SilenceID, alert.Status, alert.AlertTime,
+		alert.CreatedAt, alert.UpdatedAt)
 	if err != nil {
 		LogDatabase("INSERT", "alerts", false, err.Error(), 0)
 		return fmt.Errorf("插入告警信息失败: %v", err)
@@ -130,27 +304,103 @@ func InsertAlert(alert *Alert) error {
 	return nil
 }
 
-// GetAlerts 获取所有告警信息
-func GetAlerts() ([]Alert, error) {
-	LogSystem(logrus.InfoLevel, "database", "查询所有告警信息", nil)
-	
-	query := `SELECT id, message, recipient, alert_time, created_at, updated_at FROM alerts ORDER BY alert_time DESC`
+// MarkAlertsSilenced 将告警标记为被指定静默规则屏蔽
+func MarkAlertsSilenced(silenceID int, alertIDs []int) error {
+	if len(alertIDs) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(alertIDs))
+	args := []interface{}{silenceID}
+	for i, id := range alertIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	query := `UPDATE alerts SET silence_id = ? WHERE silence_id = 0 AND id IN (` + strings.Join(placeholders, ",") + `)`
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return fmt.Errorf("标记静默告警失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return nil
+}
+
+// MarkAlertsNotified 记录告警已立即通知成功，汇总任务只跳过已记录的告警
+func MarkAlertsNotified(alertIDs []int) error {
+	if len(alertIDs) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(alertIDs))
+	args := []interface{}{time.Now()}
+	for i, id := range alertIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	query := `UPDATE alerts SET notified_at = ? WHERE notified_at IS NULL AND id IN (` + strings.Join(placeholders, ",") + `)`
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return fmt.Errorf("记录告警通知状态失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return nil
+}
+
+// MarkAlertsInhibited 将告警标记为被指定源告警抑制
+func MarkAlertsInhibited(sourceID int, alertIDs []int) error {
+	if len(alertIDs) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(alertIDs))
+	args := []interface{}{sourceID}
+	for i, id := range alertIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	query := `UPDATE alerts SET inhibited_by = ? WHERE id IN (` + strings.Join(placeholders, ",") + `)`
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return fmt.Errorf("标记抑制告警失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return nil
+}
+
+// GetInhibitedAlerts 获取时间范围内被抑制的告警
+func GetInhibitedAlerts(startTime, endTime time.Time) ([]Alert, error) {
+	query := `
+	SELECT ` + alertColumns + ` 
+	FROM alerts 
+	WHERE inhibited_by <> 0 AND alert_time BETWEEN ? AND ?
+	ORDER BY alert_time DESC
+	`
 	
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, startTime, endTime)
 	if err != nil {
 		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
-		return nil, fmt.Errorf("查询告警信息失败: %v", err)
+		return nil, fmt.Errorf("查询被抑制告警失败: %v", err)
 	}
 	defer rows.Close()
 	
-	var alerts []Alert
+	alerts := []Alert{}
 	for rows.Next() {
-		var alert Alert
-		err := rows.Scan(&alert.ID, &alert.Message, &alert.Recipient, 
-			&alert.AlertTime, &alert.CreatedAt, &alert.UpdatedAt)
+		alert, err := scanAlert(rows)
 		if err != nil {
 			LogDatabase("SELECT", "alerts", false, err.Error(), 0)
-			return nil, fmt.Errorf("扫描告警信息失败: %v", err)
+			return nil, fmt.Errorf("扫描被抑制告警失败: %v", err)
 		}
 		alerts = append(alerts, alert)
 	}
@@ -159,6 +409,337 @@ func GetAlerts() ([]Alert, error) {
 	return alerts, nil
 }
 
+// updateAlertMessage 通过 *sql.DB 或事务更新告警信息内容
+func updateAlertMessage(ex sqlExecer, id int, message string) error {
+	result, err := ex.Exec(`UPDATE alerts SET message = ? WHERE id = ?`, message, id)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return fmt.Errorf("更新告警信息失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return nil
+}
+
+// GetAlertByID 根据ID获取告警信息，不存在时返回 nil
+func GetAlertByID(id int) (*Alert, error) {
+	row := db.QueryRow(`SELECT `+alertColumns+` FROM alerts WHERE id = ?`, id)
+	alert, err := scanAlert(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询告警信息失败: %v", err)
+	}
+	LogDatabase("SELECT", "alerts", true, "", 1)
+	return &alert, nil
+}
+
+// AcknowledgeAlert 确认告警并停止升级，仅未确认的告警可以确认，返回是否更新成功
+func AcknowledgeAlert(id int, by string) (bool, error) {
+	result, err := db.Exec(`UPDATE alerts SET status = ?, acknowledged_at = ?, acknowledged_by = ?, escalation_next_at = NULL 
+		WHERE id = ? AND status = ?`, AlertStatusAcknowledged, time.Now(), by, id, AlertStatusOpen)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return false, fmt.Errorf("确认告警失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return affected > 0, nil
+}
+
+// ResolveAlert 恢复告警并停止升级，已恢复的告警不能重复恢复，返回是否更新成功
+func ResolveAlert(id int, by string) (bool, error) {
+	result, err := db.Exec(`UPDATE alerts SET status = ?, resolved_at = ?, resolved_by = ?, escalation_next_at = NULL 
+		WHERE id = ? AND status <> ?`, AlertStatusResolved, time.Now(), by, id, AlertStatusResolved)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return false, fmt.Errorf("恢复告警失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return affected > 0, nil
+}
+
+// StartAlertEscalation 为告警绑定升级策略，已绑定策略的告警（如风暴汇总告警）不会重复绑定
+func StartAlertEscalation(id int, policy string, nextAt time.Time) (bool, error) {
+	result, err := db.Exec(`UPDATE alerts SET escalation_policy = ?, escalation_step = 0, escalation_next_at = ? 
+		WHERE id = ? AND escalation_policy = '' AND status = ?`, policy, nextAt, id, AlertStatusOpen)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return false, fmt.Errorf("启动告警升级失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return affected > 0, nil
+}
+
+// AdvanceAlertEscalation 将告警推进到下一升级步骤，nextAt 为 nil 表示升级结束。
+// 仅当告警仍未确认且步骤未被其他任务推进时更新，返回是否抢占成功
+func AdvanceAlertEscalation(id, fromStep int, nextAt *time.Time) (bool, error) {
+	result, err := db.Exec(`UPDATE alerts SET escalation_step = ?, escalation_next_at = ? 
+		WHERE id = ? AND escalation_step = ? AND status = ? AND escalation_next_at IS NOT NULL`,
+		fromStep+1, nextAt, id, fromStep, AlertStatusOpen)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return false, fmt.Errorf("推进告警升级失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return affected > 0, nil
+}
+
+// StopAlertEscalation 结束告警升级
+func StopAlertEscalation(id int) error {
+	result, err := db.Exec(`UPDATE alerts SET escalation_next_at = NULL WHERE id = ?`, id)
+	if err != nil {
+		LogDatabase("UPDATE", "alerts", false, err.Error(), 0)
+		return fmt.Errorf("结束告警升级失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "alerts", true, "", affected)
+	return nil
+}
+
+// GetDueEscalations 获取到期需要执行升级步骤的未确认告警
+func GetDueEscalations(now time.Time) ([]Alert, error) {
+	query := `
+	SELECT ` + alertColumns + ` 
+	FROM alerts 
+	WHERE status = ? AND escalation_next_at IS NOT NULL AND escalation_next_at <= ?
+	ORDER BY escalation_next_at ASC
+	`
+
+	rows, err := db.Query(query, AlertStatusOpen, now)
+	if err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询待升级告警失败: %v", err)
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+			return nil, fmt.Errorf("扫描待升级告警失败: %v", err)
+		}
+		alerts = append(alerts, alert)
+	}
+
+	LogDatabase("SELECT", "alerts", true, "", int64(len(alerts)))
+	return alerts, nil
+}
+
+// AlertFilter 告警查询条件，列表和导出接口共用，零值表示不限制
+type AlertFilter struct {
+	Recipient string
+	Source    string
+	Domain    string
+	Severity  string
+	Status    string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// where 生成 WHERE 子句和参数，没有条件时返回空字符串
+func (f AlertFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, field := range []struct {
+		column string
+		value  string
+	}{
+		{"recipient", f.Recipient},
+		{"source", f.Source},
+		{"domain", f.Domain},
+		{"severity", f.Severity},
+		{"status", f.Status},
+	} {
+		if field.value != "" {
+			conditions = append(conditions, field.column+" = ?")
+			args = append(args, field.value)
+		}
+	}
+	if !f.StartTime.IsZero() {
+		conditions = append(conditions, "alert_time >= ?")
+		args = append(args, f.StartTime)
+	}
+	if !f.EndTime.IsZero() {
+		conditions = append(conditions, "alert_time <= ?")
+		args = append(args, f.EndTime)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// CountAlerts 统计符合条件的告警数量
+func CountAlerts(filter AlertFilter) (int, error) {
+	where, args := filter.where()
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM alerts`+where, args...).Scan(&total); err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+		return 0, fmt.Errorf("统计告警信息失败: %v", err)
+	}
+	return total, nil
+}
+
+// GetAlerts 按条件分页查询告警信息，返回当前页和符合条件的总数
+func GetAlerts(filter AlertFilter, limit, offset int) ([]Alert, int, error) {
+	total, err := CountAlerts(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, args := filter.where()
+
+	query := `SELECT ` + alertColumns + ` FROM alerts` + where + ` ORDER BY alert_time DESC, id DESC LIMIT ? OFFSET ?`
+	rows, err := db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+		return nil, 0, fmt.Errorf("查询告警信息失败: %v", err)
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+			return nil, 0, fmt.Errorf("扫描告警信息失败: %v", err)
+		}
+		alerts = append(alerts, alert)
+	}
+	if err := rows.Err(); err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+		return nil, 0, fmt.Errorf("查询告警信息失败: %v", err)
+	}
+
+	LogDatabase("SELECT", "alerts", true, "", int64(len(alerts)))
+	return alerts, total, nil
+}
+
+// StreamAlerts 按条件逐行读取告警并交给 fn 处理，不在内存中保留结果集，用于大批量导出。
+// fn 返回错误或 ctx 取消时停止读取，返回已处理的行数
+func StreamAlerts(ctx context.Context, filter AlertFilter, fn func(alert Alert) error) (int64, error) {
+	where, args := filter.where()
+	query := `SELECT ` + alertColumns + ` FROM alerts` + where + ` ORDER BY alert_time DESC, id DESC`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+		return 0, fmt.Errorf("查询告警信息失败: %v", err)
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			LogDatabase("SELECT", "alerts", false, err.Error(), count)
+			return count, fmt.Errorf("扫描告警信息失败: %v", err)
+		}
+		if err := fn(alert); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), count)
+		return count, fmt.Errorf("读取告警信息失败: %v", err)
+	}
+
+	LogDatabase("SELECT", "alerts", true, "", count)
+	return count, nil
+}
+
+// alertStatsDimensions 告警统计支持的分组维度及对应的 SQL 表达式，时间粒度按数据库连接时区（即服务器时区）计算，周从周一开始
+var alertStatsDimensions = map[string]string{
+	"recipient": "recipient",
+	"source":    "source",
+	"domain":    "domain",
+	"severity":  "severity",
+	"hour":      "DATE_FORMAT(alert_time, '%Y-%m-%d %H:00')",
+	"day":       "DATE_FORMAT(alert_time, '%Y-%m-%d')",
+	"week":      "DATE_FORMAT(DATE_SUB(DATE(alert_time), INTERVAL WEEKDAY(alert_time) DAY), '%Y-%m-%d')",
+}
+
+// isTimeBucket 判断分组维度是否为时间粒度
+func isTimeBucket(dimension string) bool {
+	return dimension == "hour" || dimension == "day" || dimension == "week"
+}
+
+// GetAlertStats 按维度分组统计告警数量。包含时间粒度时按时间倒序排列，超出 limit 时截掉的是最早的分组；
+// 否则按数量降序。最多返回 limit 个分组，超出时 truncated 为 true；没有告警的时间段不返回分组
+func GetAlertStats(filter AlertFilter, groupBy []string, limit int) ([]AlertStatsBucket, bool, error) {
+	where, args := filter.where()
+
+	var columns, order []string
+	for _, dimension := range groupBy {
+		expr, ok := alertStatsDimensions[dimension]
+		if !ok {
+			return nil, false, fmt.Errorf("不支持的统计维度: %s", dimension)
+		}
+		columns = append(columns, expr)
+		if isTimeBucket(dimension) {
+			order = append(order, expr+" DESC")
+		}
+	}
+	order = append(order, "cnt DESC")
+	for _, expr := range columns {
+		order = append(order, expr)
+	}
+
+	query := `SELECT ` + strings.Join(columns, ", ") + `, COUNT(*) AS cnt FROM alerts` + where +
+		` GROUP BY ` + strings.Join(columns, ", ") + ` ORDER BY ` + strings.Join(order, ", ") + ` LIMIT ?`
+	rows, err := db.Query(query, append(args, limit+1)...)
+	if err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+		return nil, false, fmt.Errorf("统计告警信息失败: %v", err)
+	}
+	defer rows.Close()
+
+	buckets := []AlertStatsBucket{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(groupBy))
+		dest := make([]interface{}, 0, len(groupBy)+1)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		var count int
+		dest = append(dest, &count)
+		if err := rows.Scan(dest...); err != nil {
+			LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+			return nil, false, fmt.Errorf("扫描告警统计失败: %v", err)
+		}
+		bucket := AlertStatsBucket{Key: make(map[string]string, len(groupBy)), Count: count}
+		for i, dimension := range groupBy {
+			bucket.Key[dimension] = values[i].String
+		}
+		buckets = append(buckets, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		LogDatabase("SELECT", "alerts", false, err.Error(), 0)
+		return nil, false, fmt.Errorf("统计告警信息失败: %v", err)
+	}
+
+	truncated := len(buckets) > limit
+	if truncated {
+		buckets = buckets[:limit]
+	}
+	LogDatabase("SELECT", "alerts", true, "", int64(len(buckets)))
+	return buckets, truncated, nil
+}
+
 // GetAlertsByTimeRange 根据时间范围获取告警信息
 func GetAlertsByTimeRange(startTime, endTime time.Time) ([]Alert, error) {
 	LogSystem(logrus.InfoLevel, "database", "查询时间段告警信息", map[string]interface{}{
@@ -167,7 +748,7 @@ func GetAlertsByTimeRange(startTime, endTime time.Time) ([]Alert, error) {
 	})
 	
 	query := `
-	SELECT id, message, recipient, alert_time, created_at, updated_at 
+	SELECT ` + alertColumns + ` 
 	FROM alerts 
 	WHERE alert_time BETWEEN ? AND ? 
 	ORDER BY alert_time DESC
@@ -182,9 +763,7 @@ func GetAlertsByTimeRange(startTime, endTime time.Time) ([]Alert, error) {
 	
 	var alerts []Alert
 	for rows.Next() {
-		var alert Alert
-		err := rows.Scan(&alert.ID, &alert.Message, &alert.Recipient, 
-			&alert.AlertTime, &alert.CreatedAt, &alert.UpdatedAt)
+		alert, err := scanAlert(rows)
 		if err != nil {
 			LogDatabase("SELECT", "alerts", false, err.Error(), 0)
 			return nil, fmt.Errorf("扫描时间段告警信息失败: %v", err)
@@ -199,7 +778,7 @@ func GetAlertsByTimeRange(startTime, endTime time.Time) ([]Alert, error) {
 // GetAlertsByRecipient 根据收件人获取告警信息
 func GetAlertsByRecipient(recipient string) ([]Alert, error) {
 	query := `
-	SELECT id, message, recipient, alert_time, created_at, updated_at 
+	SELECT ` + alertColumns + ` 
 	FROM alerts 
 	WHERE recipient = ? 
 	ORDER BY alert_time DESC
@@ -213,8 +792,7 @@ func GetAlertsByRecipient(recipient string) ([]Alert, error) {
 	
 	var alerts []Alert
 	for rows.Next() {
-		var alert Alert
-		err := rows.Scan(&alert.ID, &alert.Message, &alert.Recipient, &alert.AlertTime, &alert.CreatedAt, &alert.UpdatedAt)
+		alert, err := scanAlert(rows)
 		if err != nil {
 			return nil, fmt.Errorf("扫描告警信息失败: %v", err)
 		}
@@ -227,7 +805,7 @@ func GetAlertsByRecipient(recipient string) ([]Alert, error) {
 // GetAlertsByTimeRangeAndRecipient 根据时间范围和收件人获取告警信息
 func GetAlertsByTimeRangeAndRecipient(startTime, endTime time.Time, recipient string) ([]Alert, error) {
 	query := `
-	SELECT id, message, recipient, alert_time, created_at, updated_at 
+	SELECT ` + alertColumns + ` 
 	FROM alerts 
 	WHERE alert_time BETWEEN ? AND ? AND recipient = ?
 	ORDER BY alert_time DESC
@@ -241,8 +819,7 @@ func GetAlertsByTimeRangeAndRecipient(startTime, endTime time.Time, recipient st
 	
 	var alerts []Alert
 	for rows.Next() {
-		var alert Alert
-		err := rows.Scan(&alert.ID, &alert.Message, &alert.Recipient, &alert.AlertTime, &alert.CreatedAt, &alert.UpdatedAt)
+		alert, err := scanAlert(rows)
 		if err != nil {
 			return nil, fmt.Errorf("扫描告警信息失败: %v", err)
 		}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// gatewayLimiter 按网关地址（邮件API、IM机器人）限制发送速率，邮件配置变化时重建
+var (
+	gatewayLimiterMu   sync.Mutex
+	gatewayLimiter     *RateLimiter
+	gatewayLimiterRate int
+)
+
+// currentGatewayLimiter 获取与当前配置一致的网关限流器，未启用限流时返回 nil
+func currentGatewayLimiter() *RateLimiter {
+	rate := currentEmailConfig().GatewayRatePerMinute
+	if rate <= 0 {
+		return nil
+	}
+
+	gatewayLimiterMu.Lock()
+	defer gatewayLimiterMu.Unlock()
+	if gatewayLimiter == nil || gatewayLimiterRate != rate {
+		// 桶容量取每秒速率，避免启动时瞬间打满网关
+		gatewayLimiter = NewRateLimiter(rate, rate/60)
+		gatewayLimiterRate = rate
+	}
+	return gatewayLimiter
+}
+
+// waitGateway 等待网关的发送令牌
+func waitGateway(ctx context.Context, gateway string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("发送已中止: %v", err)
+	}
+	limiter := currentGatewayLimiter()
+	if limiter == nil {
+		return nil
+	}
+	if err := limiter.Wait(ctx, gateway); err != nil {
+		return fmt.Errorf("等待网关限流令牌时中止: %v", err)
+	}
+	return nil
+}
+
+// withSendDeadline 为一次批量发送设置整体截止时间，未配置时只继承 ctx
+func withSendDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline := currentEmailConfig().SendDeadline; deadline > 0 {
+		return context.WithTimeout(ctx, time.Duration(deadline)*time.Second)
+	}
+	return context.WithCancel(ctx)
+}
+
+// sendWorkers 当前配置的并发发送数，至少为 1
+func sendWorkers() int {
+	if workers := currentEmailConfig().SendWorkers; workers > 0 {
+		return workers
+	}
+	return 1
+}
+
+// gatewayRequestTimeout 单次网关请求超时，未配置时为 30 秒
+func gatewayRequestTimeout() time.Duration {
+	if timeout := currentEmailConfig().RequestTimeout; timeout > 0 {
+		return time.Duration(timeout) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// runParallel 用最多 workers 个协程执行 fn(0..n-1)。每个下标都会执行一次，
+// ctx 结束后 fn 应立即返回错误结果，由调用方按下标写入结果保证汇总顺序与输入一致
+func runParallel(ctx context.Context, n, workers int, fn func(ctx context.Context, i int)) {
+	if workers > n {
+		workers = n
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(ctx, i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -1,16 +1,16 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"html/template"
+	"html"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -25,6 +25,26 @@ type EmailConfig struct {
 	To          []string `json:"to"`
 	DebugMode   bool     `json:"debug_mode"`
 	DebugAPIUrl string   `json:"debug_api_url"`
+	TemplateDir string   `json:"template_dir"` // 邮件模板目录，其中的模板覆盖内置模板
+
+	SendWorkers          int `json:"send_workers"`            // 并发发送的协程数
+	GatewayRatePerMinute int `json:"gateway_rate_per_minute"` // 每个网关（邮件API、IM机器人）每分钟最多请求数，0 表示不限制
+	RequestTimeout       int `json:"request_timeout"`         // 单次网关请求超时（秒）
+	SendDeadline         int `json:"send_deadline"`           // 一次批量发送的整体截止时间（秒），0 表示不限制
+
+	Multipart bool `json:"multipart"`  // 网关支持时以 multipart 同时发送 HTML 和纯文本正文
+	PlainText bool `json:"plain_text"` // 网关支持 mimetype=plain 时按纯文本发送纯文本邮件，否则包在 <pre> 中按 HTML 发送
+
+	Attachments bool `json:"attachments"` // 网关支持 attachments 字段时随邮件发送告警列表附件，否则改为下载链接
+
+	AttachmentFormat    string `json:"attachment_format"`     // 告警列表附件默认格式：none / csv / xlsx，用户偏好优先
+	AttachmentMinAlerts int    `json:"attachment_min_alerts"` // 邮件中告警数达到该值时才附带告警列表
+	AttachmentMaxKB     int    `json:"attachment_max_kb"`     // 附件大小上限（KB），超过时改为下载链接
+	LinkBaseURL         string `json:"link_base_url"`         // 邮件中下载链接使用的服务地址，如 http://10.5.122.114:8080
+
+	TestAllowedDomains []string `json:"test_allowed_domains"` // 测试通知接口允许直接填写的邮箱域名，其余收件人必须能在用户目录中解析
+	TestMaxRecipients  int      `json:"test_max_recipients"`  // 测试通知一次最多的收件人数
+	TestMaxMessages    int      `json:"test_max_messages"`    // 测试通知一次最多的告警条数
 }
 
 // UserInfo 用户信息结构
@@ -41,7 +61,45 @@ type EmailAPIRequest struct {
 	ToList       string `json:"to_list"`
 	Subject      string `json:"subject"`
 	Body         string `json:"body"`
-	Mimetype     string `json:"mimetype"`
+	TextBody     string `json:"text_body"`   // multipart 时的纯文本正文
+	Mimetype     string `json:"mimetype"`    // html / plain / multipart
+	Attachments  string `json:"attachments"` // 附件 JSON 数组 [{"filename","content_type","content"(base64)}]，无附件时不传
+}
+
+// 邮件网关支持的正文类型
+const (
+	mimetypeHTML      = "html"
+	mimetypePlain     = "plain"
+	mimetypeMultipart = "multipart"
+)
+
+// emailBody 邮件正文，HTML 为空且网关支持时按纯文本发送，两者都有且网关支持时按 multipart 发送
+type emailBody struct {
+	HTML        string
+	Text        string
+	Attachments []emailAttachment
+}
+
+// mimetype 按正文内容和网关能力确定发送的正文类型
+func (b emailBody) mimetype(cfg EmailConfig) string {
+	switch {
+	case b.HTML == "" && cfg.PlainText:
+		return mimetypePlain
+	case b.HTML == "":
+		return mimetypeHTML
+	case cfg.Multipart && b.Text != "":
+		return mimetypeMultipart
+	default:
+		return mimetypeHTML
+	}
+}
+
+// htmlBody 按 HTML 发送时的正文，只有纯文本时包在 <pre> 中，保留换行和对齐
+func (b emailBody) htmlBody() string {
+	if b.HTML != "" {
+		return b.HTML
+	}
+	return `<pre style="font-family: Consolas, Menlo, monospace; white-space: pre-wrap;">` + html.EscapeString(b.Text) + `</pre>`
 }
 
 // EmailAPIResponse 邮件API响应结构
@@ -57,69 +115,122 @@ type RecipientInfo struct {
 	Found bool
 }
 
-var emailConfig EmailConfig
-var userList []UserInfo
+// GatewayResponse 邮件网关或IM机器人的响应
+type GatewayResponse struct {
+	Status  int    `json:"status"`  // HTTP状态码
+	Code    int    `json:"code"`    // 网关返回的业务码（邮件API的 code、机器人的 errcode）
+	Message string `json:"message"` // 网关返回的消息
+}
 
-// InitEmailConfig 初始化邮件配置
-func InitEmailConfig() {
-	emailConfig = config.Email
+// RecipientSendResult 单个收件人的发送结果
+type RecipientSendResult struct {
+	Recipient  string           `json:"recipient"`
+	Email      string           `json:"email"`    // 实际发送的邮箱，兜底时为兜底收件人
+	Found      bool             `json:"found"`    // 是否解析到收件人邮箱
+	Fallback   bool             `json:"fallback"` // 是否转发给兜底收件人
+	Channel    string           `json:"channel"`  // email / im
+	AlertCount int              `json:"alert_count"`
+	Success    bool             `json:"success"`
+	Gateway    *GatewayResponse `json:"gateway,omitempty"` // 未请求到网关（如渲染失败）时为空
+	Error      string           `json:"error,omitempty"`
+}
 
-	if err := loadUserList(); err != nil {
-		LogSystem(logrus.ErrorLevel, "email", "加载用户列表失败", map[string]interface{}{
-			"error": err.Error(),
-		})
-		log.Printf("加载用户列表失败: %v", err)
-	}
+// SendResult 一次通知发送的结果，按收件人和渠道列出
+type SendResult struct {
+	Recipients []RecipientSendResult `json:"recipients"`
 }
 
-// loadUserList 加载用户列表
-func loadUserList() error {
-	file, err := os.Open("userlist.json")
+// add 追加一个收件人的发送结果
+func (r *SendResult) add(item RecipientSendResult, gateway *GatewayResponse, err error) {
+	item.Gateway = gateway
+	item.Success = err == nil
 	if err != nil {
-		return fmt.Errorf("打开用户列表文件失败: %v", err)
+		item.Error = err.Error()
 	}
-	defer file.Close()
+	r.Recipients = append(r.Recipients, item)
+}
 
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&userList); err != nil {
-		return fmt.Errorf("解析用户列表JSON失败: %v", err)
+// merge 合并另一次发送的结果
+func (r *SendResult) merge(other *SendResult) {
+	if other != nil {
+		r.Recipients = append(r.Recipients, other.Recipients...)
 	}
+}
 
-	LogSystem(logrus.InfoLevel, "email", "用户列表加载成功", map[string]interface{}{
-		"user_count": len(userList),
-	})
-	log.Printf("用户列表加载成功，共 %d 个用户", len(userList))
-
-	return nil
+// SuccessCount 返回发送成功的结果数
+func (r *SendResult) SuccessCount() int {
+	return len(r.Recipients) - len(r.Failed())
 }
 
-// findUserByEName 根据英文名查找用户邮箱
-func findUserByEName(eName string) (string, bool) {
-	for _, user := range userList {
-		if user.EName == eName && user.Email != "" {
-			return user.Email, true
+// Failed 返回发送失败的收件人
+func (r *SendResult) Failed() []RecipientSendResult {
+	var failed []RecipientSendResult
+	for _, item := range r.Recipients {
+		if !item.Success {
+			failed = append(failed, item)
 		}
 	}
-	return "", false
+	return failed
+}
+
+// emailConfig 当前生效的邮件配置，重新加载配置时整体替换
+var (
+	emailConfigMu sync.RWMutex
+	emailConfig   EmailConfig
+)
+
+// InitEmailConfig 初始化邮件配置
+func InitEmailConfig() {
+	setEmailConfig(config.Email)
+}
+
+// setEmailConfig 替换邮件配置
+func setEmailConfig(cfg EmailConfig) {
+	emailConfigMu.Lock()
+	emailConfig = cfg
+	emailConfigMu.Unlock()
+}
+
+// currentEmailConfig 获取当前邮件配置的副本
+func currentEmailConfig() EmailConfig {
+	emailConfigMu.RLock()
+	defer emailConfigMu.RUnlock()
+	return emailConfig
 }
 
-// SendAlertEmail 发送预警通知邮件（按用户分组）
-func SendAlertEmail(userAlertsList []UserAlerts) error {
+// SendAlertEmail 发送预警通知邮件（按用户分组），返回每个收件人的发送结果。
+// 用户邮件由有限的协程并发发送，受网关限流和整体截止时间约束，结果顺序与输入一致
+func SendAlertEmail(ctx context.Context, userAlertsList []UserAlerts) (*SendResult, error) {
+	result := &SendResult{}
 	if len(userAlertsList) == 0 {
 		LogSystem(logrus.WarnLevel, "email", "没有预警信息需要发送", nil)
-		return fmt.Errorf("没有预警信息需要发送")
+		return result, fmt.Errorf("没有预警信息需要发送")
 	}
 
+	// 团队收件人（team:/oncall:/lead:）在发送时解析为当前的具体人员
+	userAlertsList = expandTeamRecipients(userAlertsList)
+
 	LogSystem(logrus.InfoLevel, "email", "开始发送邮件", map[string]interface{}{
 		"user_count": len(userAlertsList),
 	})
 
+	ctx, cancel := withSendDeadline(ctx)
+	defer cancel()
+
 	var successCount, failCount int
 	var successRecipients, failRecipients []string
 	var notFoundUsers []string
 	var fallbackAlerts []UserAlerts
 	var fallbackEmailSent bool
 
+	// 先按顺序解析收件人，找到邮箱的用户进入并发发送
+	type userEmail struct {
+		userAlerts    UserAlerts
+		recipientInfo RecipientInfo
+		gateway       *GatewayResponse
+		err           error
+	}
+	var userEmails []*userEmail
 	for _, userAlerts := range userAlertsList {
 		recipientInfo := generateRecipientEmail(userAlerts.Recipient)
 
@@ -128,15 +239,32 @@ func SendAlertEmail(userAlertsList []UserAlerts) error {
 			fallbackAlerts = append(fallbackAlerts, userAlerts)
 			continue
 		}
+		userEmails = append(userEmails, &userEmail{userAlerts: userAlerts, recipientInfo: recipientInfo})
+	}
 
+	runParallel(ctx, len(userEmails), sendWorkers(), func(ctx context.Context, i int) {
+		task := userEmails[i]
 		LogSystem(logrus.InfoLevel, "email", "准备发送用户邮件", map[string]interface{}{
-			"recipient": userAlerts.Recipient,
-			"email": recipientInfo.Email,
-			"found": recipientInfo.Found,
-			"alert_count": len(userAlerts.Alerts),
+			"recipient": task.userAlerts.Recipient,
+			"email": task.recipientInfo.Email,
+			"found": task.recipientInfo.Found,
+			"alert_count": len(task.userAlerts.Alerts),
 		})
+		task.gateway, task.err = sendEmailToUser(ctx, task.userAlerts, task.recipientInfo)
+	})
 
-		if err := sendEmailToUser(userAlerts, recipientInfo); err != nil {
+	// 按输入顺序汇总结果
+	for _, task := range userEmails {
+		userAlerts, recipientInfo, err := task.userAlerts, task.recipientInfo, task.err
+		item := RecipientSendResult{
+			Recipient:  userAlerts.Recipient,
+			Email:      recipientInfo.Email,
+			Found:      true,
+			Channel:    ChannelEmail,
+			AlertCount: len(userAlerts.Alerts),
+		}
+		result.add(item, task.gateway, err)
+		if err != nil {
 			LogEmail(recipientInfo.Email, "预警通知", false, err.Error())
 			log.Printf("发送邮件给用户 %s (%s) 失败: %v", 
 				userAlerts.Recipient, recipientInfo.Email, err)
@@ -153,25 +281,78 @@ func SendAlertEmail(userAlertsList []UserAlerts) error {
 	}
 
 	if len(fallbackAlerts) > 0 {
-		fallbackEmail := "liyongchang@kugou.net"
-		LogSystem(logrus.InfoLevel, "email", "发送合并管理员邮件", map[string]interface{}{
-			"fallback_email": fallbackEmail,
-			"not_found_users": notFoundUsers,
-			"alert_count": len(fallbackAlerts),
+		// 按来源/团队的兜底规则分组，分别发送给对应的兜底收件人；群消息和兜底邮件与用户邮件同样并发发送
+		type fallbackSend struct {
+			group   *fallbackGroup
+			channel string
+			gateway *GatewayResponse
+			err     error
+		}
+		var fallbackSends []*fallbackSend
+		for _, group := range groupFallbackAlerts(fallbackAlerts) {
+			LogSystem(logrus.InfoLevel, "email", "发送合并管理员邮件", map[string]interface{}{
+				"fallback_rule": group.Rule,
+				"fallback_email": strings.Join(group.Recipients, ","),
+				"fallback_webhook": group.Webhook != "",
+				"not_found_users": group.NotFoundUsers,
+				"alert_count": len(group.Alerts),
+			})
+			if group.Webhook != "" {
+				fallbackSends = append(fallbackSends, &fallbackSend{group: group, channel: ChannelIM})
+			}
+			if len(group.Recipients) > 0 {
+				fallbackSends = append(fallbackSends, &fallbackSend{group: group, channel: ChannelEmail})
+			}
+		}
+
+		runParallel(ctx, len(fallbackSends), sendWorkers(), func(ctx context.Context, i int) {
+			task := fallbackSends[i]
+			if task.channel == ChannelIM {
+				task.gateway, task.err = sendFallbackWebhook(ctx, task.group.Webhook, task.group)
+			} else {
+				task.gateway, task.err = sendFallbackEmail(ctx, task.group.Alerts, task.group.NotFoundUsers, task.group.Recipients)
+			}
 		})
 
-		if err := sendFallbackEmail(fallbackAlerts, notFoundUsers); err != nil {
-			LogEmail(fallbackEmail, "管理员预警通知", false, err.Error())
-			log.Printf("发送管理员邮件失败: %v", err)
-			failCount++
-			failRecipients = append(failRecipients, fallbackEmail)
-		} else {
-			LogEmail(fallbackEmail, "管理员预警通知", true, "")
-			log.Printf("成功发送管理员邮件给: %s，包含 %d 个未找到用户的预警信息", 
-				fallbackEmail, len(fallbackAlerts))
-			successCount++
-			successRecipients = append(successRecipients, fallbackEmail+"(管理员)")
-			fallbackEmailSent = true
+		for _, task := range fallbackSends {
+			group, err := task.group, task.err
+			if task.channel == ChannelIM {
+				result.addFallback(group, ChannelIM, "", task.gateway, err)
+				if err != nil {
+					LogSystem(logrus.ErrorLevel, "email", "兜底群消息发送失败", map[string]interface{}{
+						"fallback_rule": group.Rule,
+						"error": err.Error(),
+					})
+					failCount++
+					failRecipients = append(failRecipients, "webhook:"+group.Rule)
+				}
+				continue
+			}
+
+			fallbackEmail := strings.Join(group.Recipients, ",")
+			result.addFallback(group, ChannelEmail, fallbackEmail, task.gateway, err)
+			if err != nil {
+				LogEmail(fallbackEmail, "管理员预警通知", false, err.Error())
+				log.Printf("发送管理员邮件失败: %v", err)
+				failCount++
+				failRecipients = append(failRecipients, fallbackEmail)
+			} else {
+				LogEmail(fallbackEmail, "管理员预警通知", true, "")
+				log.Printf("成功发送管理员邮件给: %s，包含 %d 个未找到用户的预警信息", 
+					fallbackEmail, len(group.Alerts))
+				successCount++
+				successRecipients = append(successRecipients, fallbackEmail+"(管理员)")
+				fallbackEmailSent = true
+			}
+		}
+
+		// 为未找到的收件人创建待处理任务
+		if currentFallbackConfig().CreateTask {
+			counts := make(map[string]int, len(fallbackAlerts))
+			for _, userAlerts := range fallbackAlerts {
+				counts[userAlerts.Recipient] += len(userAlerts.Alerts)
+			}
+			recordUnresolvedRecipients(counts)
 		}
 	}
 
@@ -184,6 +365,7 @@ func SendAlertEmail(userAlertsList []UserAlerts) error {
 		"fail_recipients": failRecipients,
 		"not_found_users": notFoundUsers,
 		"fallback_email_sent": fallbackEmailSent,
+		"results": result.Recipients,
 	})
 
 	log.Printf("邮件发送总结")
@@ -200,25 +382,73 @@ func SendAlertEmail(userAlertsList []UserAlerts) error {
 		log.Printf("  未找到用户: %v", notFoundUsers)
 	}
 
-	if failCount > 0 {
-		return fmt.Errorf("部分邮件发送失败，成功: %d，失败: %d", successCount, failCount)
+	// 以每个收件人的结果为准，兜底群消息失败同样计为失败
+	if failed := result.Failed(); len(failed) > 0 {
+		return result, fmt.Errorf("部分邮件发送失败，成功: %d，失败: %d", result.SuccessCount(), len(failed))
 	}
 
-	return nil
+	return result, nil
+}
+
+// addFallback 记录兜底分组中每个未找到用户的结果，同一分组共用一次网关响应
+func (r *SendResult) addFallback(group *fallbackGroup, channel, fallbackEmail string, gateway *GatewayResponse, err error) {
+	for _, userAlerts := range group.Alerts {
+		r.add(RecipientSendResult{
+			Recipient:  userAlerts.Recipient,
+			Email:      fallbackEmail,
+			Fallback:   true,
+			Channel:    channel,
+			AlertCount: len(userAlerts.Alerts),
+		}, gateway, err)
+	}
 }
 
 // sendEmailToUser 发送邮件给特定用户
-func sendEmailToUser(userAlerts UserAlerts, recipientInfo RecipientInfo) error {
-	subject, body, err := generateEmailContentForUser(userAlerts, recipientInfo)
+func sendEmailToUser(ctx context.Context, userAlerts UserAlerts, recipientInfo RecipientInfo) (*GatewayResponse, error) {
+	subject, body, err := generateEmailBodyForUser(userAlerts, recipientInfo)
 	if err != nil {
-		return fmt.Errorf("生成邮件内容失败: %v", err)
+		return nil, err
 	}
 
-	if err := sendEmailViaAPI([]string{recipientInfo.Email}, subject, body); err != nil {
-		return fmt.Errorf("发送邮件失败: %v", err)
+	gateway, err := sendEmailViaAPI(ctx, []string{recipientInfo.Email}, subject, body)
+	if err != nil {
+		return gateway, fmt.Errorf("发送邮件失败: %v", err)
 	}
 
-	return nil
+	return gateway, nil
+}
+
+// generateEmailBodyForUser 生成用户邮件的主题、正文和告警列表附件，用户选择纯文本邮件时不包含HTML正文
+func generateEmailBodyForUser(userAlerts UserAlerts, recipientInfo RecipientInfo) (string, emailBody, error) {
+	data := alertEmailDataFor(userAlerts, recipientInfo)
+	attachment, downloadURL := digestAttachmentFor(userAlerts)
+	if attachment != nil {
+		data.AttachmentName = attachment.Filename
+	}
+	data.DownloadURL = downloadURL
+
+	subject, html, err := generateEmailContentForUser(userAlerts, recipientInfo, data)
+	if err != nil {
+		return "", emailBody{}, fmt.Errorf("生成邮件内容失败: %v", err)
+	}
+	text, err := generatePlainTextForUser(userAlerts, data)
+
+	body := emailBody{HTML: html, Text: text}
+	if attachment != nil {
+		body.Attachments = []emailAttachment{*attachment}
+	}
+	if pref := preferencesFor(userAlerts.Recipient); pref != nil && pref.EmailFormat == EmailFormatText {
+		if err != nil {
+			return "", emailBody{}, fmt.Errorf("生成纯文本邮件内容失败: %v", err)
+		}
+		body.HTML = ""
+	} else if err != nil {
+		LogSystem(logrus.WarnLevel, "email", "生成纯文本邮件内容失败，只发送HTML", map[string]interface{}{
+			"recipient": userAlerts.Recipient,
+			"error": err.Error(),
+		})
+	}
+	return subject, body, nil
 }
 
 // generateRecipientEmail 生成收件人信息，包括邮箱地址
@@ -230,205 +460,61 @@ func generateRecipientEmail(recipient string) RecipientInfo {
 		}
 	}
 
-	if email, found := findUserByEName(recipient); found {
+	if resolution := resolveRecipient(recipient); resolution.Found && resolution.Email != "" {
 		LogSystem(logrus.InfoLevel, "email", "找到用户邮箱", map[string]interface{}{
 			"e_name": recipient,
-			"email": email,
+			"email": resolution.Email,
+			"matched_by": resolution.MatchedBy,
+			"resolver": resolution.Resolver,
 		})
 		return RecipientInfo{
-			Email: email,
+			Email: resolution.Email,
 			Found: true,
 		}
 	}
 
-	fallbackEmail := "liyongchang@kugou.net"
-	LogSystem(logrus.WarnLevel, "email", "未找到用户，使用管理员邮箱", map[string]interface{}{
+	LogSystem(logrus.WarnLevel, "email", "未找到用户，转给兜底收件人", map[string]interface{}{
 		"e_name": recipient,
-		"fallback_email": fallbackEmail,
 	})
-	log.Printf("未找到用户 %s 的邮箱，使用管理员邮箱: %s", recipient, fallbackEmail)
+	log.Printf("未找到用户 %s 的邮箱，转给兜底收件人", recipient)
 
 	return RecipientInfo{
-		Email: fallbackEmail,
 		Found: false,
 	}
 }
 
-// generateEmailContentForUser 为用户生成邮件内容
-func generateEmailContentForUser(userAlerts UserAlerts, recipientInfo RecipientInfo) (string, string, error) {
-	subject := fmt.Sprintf("预警通知 - %s - %s", userAlerts.Recipient, time.Now().Format("2006-01-02"))
+// generateEmailContentForUser 为用户生成邮件主题和HTML内容
+func generateEmailContentForUser(userAlerts UserAlerts, recipientInfo RecipientInfo, data alertEmailData) (string, string, error) {
+	subject := T(data.Lang, "email.alert.subject", userAlerts.Recipient, time.Now().Format("2006-01-02"))
 
 	if !recipientInfo.Found {
-		subject = fmt.Sprintf("【管理员】预警通知 - %s (未找到用户 - %s)", userAlerts.Recipient, time.Now().Format("2006-01-02"))
-	}
-
-	const emailTemplate = `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>预警通知</title>
-    <style>
-        body { 
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; 
-            margin: 0; 
-            padding: 20px; 
-            background-color: #f5f5f5; 
-            line-height: 1.6;
-        }
-        .container {
-            max-width: 800px;
-            margin: 0 auto;
-            background-color: #ffffff;
-            border-radius: 8px;
-            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
-            overflow: hidden;
-        }
-        .header {
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: white;
-            padding: 30px;
-            text-align: center;
-        }
-        .header h1 {
-            margin: 0;
-            font-size: 24px;
-            font-weight: 600;
-        }
-        .header p {
-            margin: 10px 0 0 0;
-            opacity: 0.9;
-            font-size: 14px;
-        }
-        .content {
-            padding: 30px;
-        }
-        .summary {
-            background-color: #f8f9fa;
-            border-left: 4px solid #007bff;
-            padding: 20px;
-            margin-bottom: 30px;
-            border-radius: 0 8px 8px 0;
-        }
-        .summary h3 {
-            margin: 0 0 15px 0;
-            color: #007bff;
-            font-size: 18px;
-        }
-        .summary p {
-            margin: 5px 0;
-            color: #6c757d;
-        }
-        .user-not-found {
-            background-color: #f8d7da;
-            border: 1px solid #f5c6cb;
-            border-radius: 8px;
-            padding: 15px;
-            margin-bottom: 20px;
-            color: #721c24;
-        }
-        .user-not-found h4 {
-            margin: 0 0 10px 0;
-            font-size: 16px;
-        }
-        .user-not-found ul {
-            margin: 10px 0 0 0;
-            padding-left: 20px;
-        }
-        .user-not-found li {
-            margin-bottom: 5px;
-        }
-        .alert-item {
-            background-color: #ffffff;
-            border: 1px solid #e9ecef;
-            border-radius: 8px;
-            padding: 20px;
-            margin-bottom: 15px;
-            box-shadow: 0 1px 3px rgba(0,0,0,0.1);
-        }
-        .alert-item h4 {
-            margin: 0 0 10px 0;
-            color: #dc3545;
-            font-size: 16px;
-        }
-        .alert-meta {
-            display: flex;
-            justify-content: space-between;
-            align-items: center;
-            margin-top: 15px;
-            padding-top: 15px;
-            border-top: 1px solid #e9ecef;
-            font-size: 12px;
-            color: #6c757d;
-        }
-        .alert-time {
-            font-weight: 600;
-        }
-        .footer {
-            background-color: #f8f9fa;
-            padding: 20px 30px;
-            text-align: center;
-            color: #6c757d;
-            font-size: 12px;
-            border-top: 1px solid #e9ecef;
-        }
-        .footer p {
-            margin: 5px 0;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>预警通知</h1>
-            <p>生成时间: {{.GenerateTime}}</p>
-        </div>
-    
-        <div class="content">
-            {{if not .UserFound}}
-            <div class="user-not-found">
-                <h4>用户信息提示</h4>
-                <p>用户 <strong>{{.Recipient}}</strong> 在系统中未找到对应的邮箱地址，邮件已发送给系统管理员。</p>
-                <p>请检查以下事项：</p>
-                <ul>
-                    <li>确认用户英文名拼写是否正确</li>
-                    <li>检查用户列表是否包含该用户</li>
-                    <li>确认用户邮箱地址是否有效</li>
-                    <li>如需添加，请更新用户列表文件</li>
-                </ul>
-            </div>
-            {{end}}
-            
-            <div class="summary">
-                <h3>预警信息概览</h3>
-                <p><strong>收件人:</strong> {{.Recipient}}</p>
-                <p><strong>时间范围:</strong> {{.StartTime}} 至 {{.EndTime}}</p>
-                <p><strong>预警数量:</strong> {{.TotalCount}} 条</p>
-            </div>
-
-            <h3 style="color: #dc3545; margin-bottom: 20px; font-size: 18px;">详细预警信息</h3>
-            
-            {{range $index, $alert := .Alerts}}
-            <div class="alert-item">
-                <h4>预警 #{{add $index 1}}</h4>
-                <p style="margin: 10px 0; line-height: 1.6;">{{$alert.Message}}</p>
-                <div class="alert-meta">
-                    <span class="alert-time">时间: {{$alert.AlertTime.Format "2006-01-02 15:04:05"}}</span>
-                </div>
-            </div>
-            {{end}}
-        </div>
-    
-        <div class="footer">
-            <p><strong>系统自动发送</strong> | 请及时处理相关预警信息</p>
-            <p>如有疑问，请联系系统管理员</p>
-        </div>
-    </div>
-</body>
-</html>`
-
-	now := time.Now()
-	startTime := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	endTime := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
+		subject = T(data.Lang, "email.alert.subject_not_found", userAlerts.Recipient, time.Now().Format("2006-01-02"))
+	}
+
+	body, err := renderEmailTemplate(templateAlertEmail, firstAlert(userAlerts.Alerts), data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject, body, nil
+}
+
+// generatePlainTextForUser 为用户生成纯文本邮件内容
+func generatePlainTextForUser(userAlerts UserAlerts, data alertEmailData) (string, error) {
+	return renderTextTemplate(templateAlertEmail, firstAlert(userAlerts.Alerts), data)
+}
+
+// alertEmailDataFor 生成用户邮件模板数据，时间范围取告警的最早和最晚时间。
+// 邮件语言取收件人的语言偏好，未找到用户时邮件发给管理员，使用默认语言
+func alertEmailDataFor(userAlerts UserAlerts, recipientInfo RecipientInfo) alertEmailData {
+	lang := defaultLocale
+	if recipientInfo.Found {
+		lang = preferencesFor(userAlerts.Recipient).locale()
+	}
+
+	now := time.Now().In(serverLocation)
+	startTime := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, serverLocation)
+	endTime := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, serverLocation)
 
 	if len(userAlerts.Alerts) > 0 {
 		startTime = userAlerts.Alerts[0].AlertTime
@@ -443,43 +529,23 @@ func generateEmailContentForUser(userAlerts UserAlerts, recipientInfo RecipientI
 		}
 	}
 
-	type TemplateData struct {
-		GenerateTime string
-		StartTime    string
-		EndTime      string
-		TotalCount   int
-		Recipient    string
-		UserFound    bool
-		Alerts       []Alert
-	}
-
-	data := TemplateData{
-		GenerateTime: time.Now().Format("2006-01-02 15:04:05"),
-		StartTime:    startTime.Format("2006-01-02 15:04:05"),
-		EndTime:      endTime.Format("2006-01-02 15:04:05"),
+	return alertEmailData{
+		GenerateTime: formatDisplayTime(time.Now()),
+		StartTime:    formatDisplayTime(startTime),
+		EndTime:      formatDisplayTime(endTime),
 		TotalCount:   len(userAlerts.Alerts),
 		Recipient:    userAlerts.Recipient,
 		UserFound:    recipientInfo.Found,
 		Alerts:       userAlerts.Alerts,
+		Inhibited:    userAlerts.Inhibited,
+		Lang:         lang,
 	}
-
-	tmpl, err := template.New("email").Funcs(template.FuncMap{
-		"add": func(a, b int) int { return a + b },
-	}).Parse(emailTemplate)
-	if err != nil {
-		return "", "", fmt.Errorf("解析邮件模板失败: %v", err)
-	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", "", fmt.Errorf("执行邮件模板失败: %v", err)
-	}
-
-	return subject, buf.String(), nil
 }
 
-// sendEmailViaAPI 通过HTTP API发送邮件
-func sendEmailViaAPI(toUsers []string, subject, content string) error {
+// sendEmailViaAPI 通过HTTP API发送邮件，返回网关响应（请求未到达网关时为空）。
+// 发送前等待该网关的限流令牌，ctx 取消或超时时中止请求
+func sendEmailViaAPI(ctx context.Context, toUsers []string, subject string, body emailBody) (*GatewayResponse, error) {
+	emailConfig := currentEmailConfig()
 	apiURL := emailConfig.APIUrl
 	if emailConfig.DebugMode && emailConfig.DebugAPIUrl != "" {
 		apiURL = emailConfig.DebugAPIUrl
@@ -493,14 +559,43 @@ func sendEmailViaAPI(toUsers []string, subject, content string) error {
 	formData.Set("opdAppsecret", emailConfig.AppSecret)
 	formData.Set("to_list", toList)
 	formData.Set("subject", subject)
-	formData.Set("body", content)
-	formData.Set("mimetype", "html")
+	mimetype := body.mimetype(emailConfig)
+	formData.Set("mimetype", mimetype)
+	switch mimetype {
+	case mimetypePlain:
+		formData.Set("body", body.Text)
+	case mimetypeMultipart:
+		formData.Set("body", body.HTML)
+		formData.Set("text_body", body.Text)
+	default:
+		formData.Set("body", body.htmlBody())
+	}
+	var attachmentNames []string
+	if len(body.Attachments) > 0 && !emailConfig.Attachments {
+		LogSystem(logrus.WarnLevel, "email", "邮件网关未开启附件支持，不发送附件", map[string]interface{}{
+			"recipients":  toList,
+			"attachments": len(body.Attachments),
+		})
+	} else if len(body.Attachments) > 0 {
+		attachments, err := json.Marshal(body.Attachments)
+		if err != nil {
+			return nil, fmt.Errorf("序列化邮件附件失败: %v", err)
+		}
+		formData.Set("attachments", string(attachments))
+		for _, attachment := range body.Attachments {
+			attachmentNames = append(attachmentNames, attachment.Filename)
+		}
+	}
 
 	postData := formData.Encode()
 
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(postData))
+	if err := waitGateway(ctx, apiURL); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(postData))
 	if err != nil {
-		return fmt.Errorf("创建HTTP请求失败: %v", err)
+		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
@@ -510,21 +605,25 @@ func sendEmailViaAPI(toUsers []string, subject, content string) error {
 	log.Printf("发送邮件信息")
 	log.Printf("   收件人: %s", toList)
 	log.Printf("   主题: %s", subject)
+	if len(attachmentNames) > 0 {
+		log.Printf("   附件: %s", strings.Join(attachmentNames, ", "))
+	}
 	log.Printf("   API地址: %s", apiURL)
 
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout: gatewayRequestTimeout(),
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("发送HTTP请求失败: %v", err)
+		return nil, fmt.Errorf("发送HTTP请求失败: %v", err)
 	}
 	defer resp.Body.Close()
 
+	gateway := &GatewayResponse{Status: resp.StatusCode}
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("读取响应失败: %v", err)
+		return gateway, fmt.Errorf("读取响应失败: %v", err)
 	}
 
 	log.Printf("邮件API响应:")
@@ -534,225 +633,89 @@ func sendEmailViaAPI(toUsers []string, subject, content string) error {
 	var apiResp EmailAPIResponse
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
 		log.Printf("邮件API响应解析失败，原始响应: %s", string(respBody))
-		return fmt.Errorf("解析API响应失败: %v", err)
+		gateway.Message = truncateGatewayMessage(string(respBody))
+		return gateway, fmt.Errorf("解析API响应失败: %v", err)
 	}
+	gateway.Code = apiResp.Code
+	gateway.Message = apiResp.Message
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("邮件API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return gateway, fmt.Errorf("邮件API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
 	}
 
 	if apiResp.Code != 0 && apiResp.Code != 200 {
-		return fmt.Errorf("邮件发送失败, code=%d, message=%s", apiResp.Code, apiResp.Message)
+		return gateway, fmt.Errorf("邮件发送失败, code=%d, message=%s", apiResp.Code, apiResp.Message)
 	}
 
 	log.Printf("邮件发送成功: %s", apiResp.Message)
-	return nil
+	return gateway, nil
+}
+
+// truncateGatewayMessage 截断无法解析的网关响应，避免结果和日志过大
+func truncateGatewayMessage(body string) string {
+	if len(body) > 512 {
+		return body[:512] + "..."
+	}
+	return body
 }
 
 // sendFallbackEmail 发送合并的管理员邮件
-func sendFallbackEmail(fallbackAlerts []UserAlerts, notFoundUsers []string) error {
-	fallbackRecipientInfo := RecipientInfo{
-		Email: "liyongchang@kugou.net",
-		Found: false,
+func sendFallbackEmail(ctx context.Context, fallbackAlerts []UserAlerts, notFoundUsers []string, recipients []string) (*GatewayResponse, error) {
+	subject, html, err := generateFallbackEmailContent(fallbackAlerts, notFoundUsers)
+	if err != nil {
+		return nil, fmt.Errorf("生成管理员邮件内容失败: %v", err)
 	}
+	// 纯文本正文只在 multipart 时使用，生成失败不影响发送
+	text, _ := generateFallbackPlainText(fallbackAlerts, notFoundUsers)
 
-	subject, body, err := generateFallbackEmailContent(fallbackAlerts, notFoundUsers)
+	gateway, err := sendEmailViaAPI(ctx, recipients, subject, emailBody{HTML: html, Text: text})
 	if err != nil {
-		return fmt.Errorf("生成管理员邮件内容失败: %v", err)
+		return gateway, fmt.Errorf("发送管理员邮件失败: %v", err)
 	}
 
-	if err := sendEmailViaAPI([]string{fallbackRecipientInfo.Email}, subject, body); err != nil {
-		return fmt.Errorf("发送管理员邮件失败: %v", err)
+	return gateway, nil
+}
+
+// sendAdminEmail 发送系统通知邮件给管理员
+func sendAdminEmail(subject, body string) error {
+	adminEmail := strings.Join(adminRecipients(), ",")
+	if _, err := sendEmailViaAPI(context.Background(), adminRecipients(), subject, emailBody{HTML: body}); err != nil {
+		LogEmail(adminEmail, subject, false, err.Error())
+		return fmt.Errorf("发送管理员通知失败: %v", err)
 	}
 
+	LogEmail(adminEmail, subject, true, "")
 	return nil
 }
 
 // generateFallbackEmailContent 生成管理员邮件内容（包含用户分组）
 func generateFallbackEmailContent(fallbackAlerts []UserAlerts, notFoundUsers []string) (string, string, error) {
-	subject := fmt.Sprintf("【管理员】预警通知 - %s (未找到用户) - %s", 
+	subject := T(defaultLocale, "email.fallback.subject",
 		strings.Join(notFoundUsers, ", "), time.Now().Format("2006-01-02"))
 
-	const fallbackEmailTemplate = `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>管理员预警通知</title>
-    <style>
-        body { 
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; 
-            margin: 0; 
-            padding: 20px; 
-            background-color: #f5f5f5; 
-            line-height: 1.6;
-        }
-        .container {
-            max-width: 900px;
-            margin: 0 auto;
-            background-color: #ffffff;
-            border-radius: 8px;
-            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
-            overflow: hidden;
-        }
-        .header {
-            background: linear-gradient(135deg, #dc3545 0%, #c82333 100%);
-            color: white;
-            padding: 30px;
-            text-align: center;
-        }
-        .header h1 {
-            margin: 0;
-            font-size: 24px;
-            font-weight: 600;
-        }
-        .header p {
-            margin: 10px 0 0 0;
-            opacity: 0.9;
-            font-size: 14px;
-        }
-        .content {
-            padding: 30px;
-        }
-        .warning-box {
-            background-color: #f8d7da;
-            border: 1px solid #f5c6cb;
-            border-radius: 8px;
-            padding: 20px;
-            margin-bottom: 30px;
-            color: #721c24;
-        }
-        .warning-box h3 {
-            margin: 0 0 15px 0;
-            font-size: 18px;
-        }
-        .summary {
-            background-color: #f8f9fa;
-            border-left: 4px solid #007bff;
-            padding: 20px;
-            margin-bottom: 30px;
-            border-radius: 0 8px 8px 0;
-        }
-        .summary h3 {
-            margin: 0 0 15px 0;
-            color: #007bff;
-            font-size: 18px;
-        }
-        .summary p {
-            margin: 5px 0;
-            color: #6c757d;
-        }
-        .user-section {
-            background-color: #f8f9fa;
-            border: 1px solid #e9ecef;
-            border-radius: 8px;
-            padding: 20px;
-            margin-bottom: 20px;
-        }
-        .user-header {
-            background-color: #007bff;
-            color: white;
-            padding: 15px;
-            margin: -20px -20px 20px -20px;
-            border-radius: 8px 8px 0 0;
-        }
-        .user-header h3 {
-            margin: 0;
-            font-size: 16px;
-        }
-        .alert-item {
-            background-color: #ffffff;
-            border: 1px solid #e9ecef;
-            border-radius: 6px;
-            padding: 15px;
-            margin-bottom: 10px;
-            box-shadow: 0 1px 3px rgba(0,0,0,0.1);
-        }
-        .alert-item h4 {
-            margin: 0 0 10px 0;
-            color: #dc3545;
-            font-size: 14px;
-        }
-        .alert-message {
-            margin: 10px 0;
-            line-height: 1.6;
-        }
-        .alert-meta {
-            display: flex;
-            justify-content: space-between;
-            align-items: center;
-            margin-top: 10px;
-            padding-top: 10px;
-            border-top: 1px solid #e9ecef;
-            font-size: 12px;
-            color: #6c757d;
-        }
-        .alert-time {
-            font-weight: 600;
-        }
-        .footer {
-            background-color: #f8f9fa;
-            padding: 20px 30px;
-            text-align: center;
-            color: #6c757d;
-            font-size: 12px;
-            border-top: 1px solid #e9ecef;
-        }
-        .footer p {
-            margin: 5px 0;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>【管理员】预警通知</h1>
-            <p>未找到用户转发通知 | 生成时间: {{.GenerateTime}}</p>
-        </div>
-        
-        <div class="content">
-            <div class="warning-box">
-                <h3>重要通知</h3>
-                <p>系统在处理预警信息时，发现以下用户英文名在用户列表中未找到对应的邮箱地址。</p>
-                <p><strong>未找到用户：</strong>{{.NotFoundUsers}}</p>
-                <p>该邮件已发送给系统管理员，请及时处理相关用户信息。</p>
-            </div>
-            
-            <div class="summary">
-                <h3>预警信息概览</h3>
-                <p><strong>未找到用户数量：</strong>{{.UserCount}} 个</p>
-                <p><strong>总预警数量：</strong>{{.TotalAlerts}} 条</p>
-                <p><strong>时间范围：</strong>{{.StartTime}} 至 {{.EndTime}}</p>
-            </div>
-            
-            <h3 style="color: #dc3545; margin-bottom: 20px; font-size: 18px;">各用户详细预警信息</h3>
-            
-            {{range $userIndex, $userAlerts := .UserAlertsList}}
-            <div class="user-section">
-                <div class="user-header">
-                    <h3>用户：{{$userAlerts.Recipient}} (未找到邮箱)</h3>
-                </div>
-                
-                {{range $alertIndex, $alert := $userAlerts.Alerts}}
-                <div class="alert-item">
-                    <h4>预警 #{{add $alertIndex 1}}</h4>
-                    <div class="alert-message">{{$alert.Message}}</div>
-                    <div class="alert-meta">
-                        <span class="alert-time">{{$alert.AlertTime.Format "2006-01-02 15:04:05"}}</span>
-                    </div>
-                </div>
-                {{end}}
-            </div>
-            {{end}}
-        </div>
-        
-        <div class="footer">
-            <p><strong>系统自动发送给管理员</strong> | 请及时处理相关用户信息和预警</p>
-            <p>建议：更新用户列表文件，添加缺失用户的邮箱地址</p>
-        </div>
-    </div>
-</body>
-</html>`
+	body, err := renderEmailTemplate(templateFallbackEmail, fallbackSampleAlert(fallbackAlerts), fallbackEmailDataFor(fallbackAlerts, notFoundUsers))
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject, body, nil
+}
+
+// generateFallbackPlainText 生成管理员邮件的纯文本内容
+func generateFallbackPlainText(fallbackAlerts []UserAlerts, notFoundUsers []string) (string, error) {
+	return renderTextTemplate(templateFallbackEmail, fallbackSampleAlert(fallbackAlerts), fallbackEmailDataFor(fallbackAlerts, notFoundUsers))
+}
+
+// fallbackSampleAlert 管理员邮件用于选择模板的代表告警
+func fallbackSampleAlert(fallbackAlerts []UserAlerts) *Alert {
+	if len(fallbackAlerts) == 0 {
+		return nil
+	}
+	return firstAlert(fallbackAlerts[0].Alerts)
+}
 
+// fallbackEmailDataFor 生成管理员邮件模板数据
+func fallbackEmailDataFor(fallbackAlerts []UserAlerts, notFoundUsers []string) fallbackEmailData {
 	var startTime, endTime time.Time
 	totalAlerts := 0
 
@@ -773,37 +736,14 @@ func generateFallbackEmailContent(fallbackAlerts []UserAlerts, notFoundUsers []s
 		}
 	}
 
-	type FallbackTemplateData struct {
-		GenerateTime   string
-		NotFoundUsers  string
-		UserCount      int
-		TotalAlerts    int
-		StartTime      string
-		EndTime        string
-		UserAlertsList []UserAlerts
-	}
-
-	data := FallbackTemplateData{
-		GenerateTime:   time.Now().Format("2006-01-02 15:04:05"),
+	return fallbackEmailData{
+		GenerateTime:   formatDisplayTime(time.Now()),
 		NotFoundUsers:  strings.Join(notFoundUsers, ", "),
 		UserCount:      len(notFoundUsers),
 		TotalAlerts:    totalAlerts,
-		StartTime:      startTime.Format("2006-01-02 15:04:05"),
-		EndTime:        endTime.Format("2006-01-02 15:04:05"),
+		StartTime:      formatDisplayTime(startTime),
+		EndTime:        formatDisplayTime(endTime),
 		UserAlertsList: fallbackAlerts,
+		Lang:           defaultLocale,
 	}
-
-	tmpl, err := template.New("fallback_email").Funcs(template.FuncMap{
-		"add": func(a, b int) int { return a + b },
-	}).Parse(fallbackEmailTemplate)
-	if err != nil {
-		return "", "", fmt.Errorf("解析管理员邮件模板失败: %v", err)
-	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", "", fmt.Errorf("执行管理员邮件模板失败: %v", err)
-	}
-
-	return subject, buf.String(), nil
 } 
\ No newline at end of file
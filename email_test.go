@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// TestEmailBodyMimetype 正文类型取决于正文内容和网关的 PlainText、Multipart 能力
+func TestEmailBodyMimetype(t *testing.T) {
+	cases := []struct {
+		name      string
+		body      emailBody
+		plainText bool
+		multipart bool
+		want      string
+	}{
+		{"html only", emailBody{HTML: "<p>a</p>"}, false, false, mimetypeHTML},
+		{"html only, plain text", emailBody{HTML: "<p>a</p>"}, true, false, mimetypeHTML},
+		{"html only, multipart", emailBody{HTML: "<p>a</p>"}, false, true, mimetypeHTML},
+		{"html only, both", emailBody{HTML: "<p>a</p>"}, true, true, mimetypeHTML},
+		{"text only", emailBody{Text: "a"}, false, false, mimetypeHTML},
+		{"text only, plain text", emailBody{Text: "a"}, true, false, mimetypePlain},
+		{"text only, multipart", emailBody{Text: "a"}, false, true, mimetypeHTML},
+		{"text only, both", emailBody{Text: "a"}, true, true, mimetypePlain},
+		{"html and text", emailBody{HTML: "<p>a</p>", Text: "a"}, false, false, mimetypeHTML},
+		{"html and text, plain text", emailBody{HTML: "<p>a</p>", Text: "a"}, true, false, mimetypeHTML},
+		{"html and text, multipart", emailBody{HTML: "<p>a</p>", Text: "a"}, false, true, mimetypeMultipart},
+		{"html and text, both", emailBody{HTML: "<p>a</p>", Text: "a"}, true, true, mimetypeMultipart},
+	}
+	for _, tc := range cases {
+		cfg := EmailConfig{PlainText: tc.plainText, Multipart: tc.multipart}
+		if got := tc.body.mimetype(cfg); got != tc.want {
+			t.Errorf("%s: mimetype = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
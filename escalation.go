@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// 升级步骤中的特殊通知对象
+const (
+	EscalationTargetRecipient = "$recipient" // 告警的收件人（主值班人）
+	EscalationTargetAdmin     = "$admin"     // 系统管理员
+)
+
+// EscalationStep 升级步骤：告警创建 after_minutes 分钟后仍未确认则通知 targets
+type EscalationStep struct {
+	Name         string   `json:"name"`          // 步骤名称，如 primary、secondary、team_lead、admin
+	AfterMinutes int      `json:"after_minutes"` // 相对告警创建时间的延迟（分钟），后续步骤在上一步骤执行后按差值延迟
+	Targets      []string `json:"targets"`       // 通知对象：英文名、邮箱、$recipient 或 $admin
+}
+
+// EscalationPolicy 升级策略，告警命中第一个匹配的策略
+type EscalationPolicy struct {
+	Name  string           `json:"name"`
+	Match RouteMatch       `json:"match"`
+	Steps []EscalationStep `json:"steps"`
+}
+
+var (
+	escalationMu       sync.RWMutex
+	escalationPolicies []EscalationPolicy
+
+	// escalationRunMu 保证同一进程内升级任务串行执行
+	escalationRunMu sync.Mutex
+
+	// escalationCron 升级检查任务，重新加载配置后新增策略时才启动
+	escalationCron *cron.Cron
+)
+
+// InitEscalationPolicies 加载升级策略
+func InitEscalationPolicies() error {
+	policies, err := loadEscalationFile(config.Routing.EscalationFile)
+	if err != nil {
+		return err
+	}
+
+	escalationMu.Lock()
+	escalationPolicies = policies
+	escalationMu.Unlock()
+	return nil
+}
+
+// loadEscalationFile 读取并校验升级策略文件，文件不存在时不启用升级
+func loadEscalationFile(path string) ([]EscalationPolicy, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		LogSystem(logrus.InfoLevel, "escalation", "未找到升级策略文件，不启用告警升级", map[string]interface{}{
+			"file": path,
+		})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取升级策略文件失败: %v", err)
+	}
+
+	var policies []EscalationPolicy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("解析升级策略文件失败: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range policies {
+		policy := &policies[i]
+		if policy.Name == "" {
+			return nil, fmt.Errorf("第 %d 个升级策略缺少 name", i+1)
+		}
+		if names[policy.Name] {
+			return nil, fmt.Errorf("升级策略名称重复: %s", policy.Name)
+		}
+		names[policy.Name] = true
+		if len(policy.Steps) == 0 {
+			return nil, fmt.Errorf("升级策略 %s 未配置步骤", policy.Name)
+		}
+		for j := range policy.Steps {
+			step := &policy.Steps[j]
+			if step.Name == "" {
+				step.Name = fmt.Sprintf("step-%d", j+1)
+			}
+			if len(step.Targets) == 0 {
+				return nil, fmt.Errorf("升级策略 %s 的步骤 %s 未配置通知对象", policy.Name, step.Name)
+			}
+			if step.AfterMinutes < 0 || (j > 0 && step.AfterMinutes < policy.Steps[j-1].AfterMinutes) {
+				return nil, fmt.Errorf("升级策略 %s 的步骤 %s 延迟时间必须非负且不小于上一步骤", policy.Name, step.Name)
+			}
+		}
+	}
+
+	LogSystem(logrus.InfoLevel, "escalation", "升级策略加载成功", map[string]interface{}{
+		"file":         path,
+		"policy_count": len(policies),
+	})
+	log.Printf("升级策略加载成功，共 %d 条", len(policies))
+	return policies, nil
+}
+
+// currentEscalationPolicies 获取当前升级策略
+func currentEscalationPolicies() []EscalationPolicy {
+	escalationMu.RLock()
+	defer escalationMu.RUnlock()
+	return escalationPolicies
+}
+
+// findEscalationPolicy 查找告警匹配的升级策略
+func findEscalationPolicy(alert *Alert) *EscalationPolicy {
+	policies := currentEscalationPolicies()
+	for i := range policies {
+		if policies[i].Match.Matches(alert) {
+			return &policies[i]
+		}
+	}
+	return nil
+}
+
+// escalationPolicyByName 根据名称查找升级策略
+func escalationPolicyByName(name string) *EscalationPolicy {
+	policies := currentEscalationPolicies()
+	for i := range policies {
+		if policies[i].Name == name {
+			return &policies[i]
+		}
+	}
+	return nil
+}
+
+// startEscalations 为新写入的告警绑定升级策略，并立即执行到期的第一步
+func startEscalations(alerts []Alert) {
+	if len(currentEscalationPolicies()) == 0 {
+		return
+	}
+
+	started := 0
+	for i := range alerts {
+		alert := &alerts[i]
+		if alert.ID == 0 || alert.Silenced {
+			continue
+		}
+		policy := findEscalationPolicy(alert)
+		if policy == nil {
+			continue
+		}
+
+		nextAt := alert.CreatedAt
+		if nextAt.IsZero() {
+			nextAt = time.Now()
+		}
+		nextAt = nextAt.Add(time.Duration(policy.Steps[0].AfterMinutes) * time.Minute)
+		ok, err := StartAlertEscalation(alert.ID, policy.Name, nextAt)
+		if err != nil {
+			LogSystem(logrus.WarnLevel, "escalation", "启动告警升级失败", map[string]interface{}{
+				"alert_id": alert.ID,
+				"error":    err.Error(),
+			})
+			continue
+		}
+		if ok {
+			started++
+		}
+	}
+
+	if started > 0 {
+		go runEscalations()
+	}
+}
+
+// runEscalations 执行所有到期的升级步骤
+func runEscalations() {
+	escalationRunMu.Lock()
+	defer escalationRunMu.Unlock()
+
+	now := time.Now()
+	alerts, err := GetDueEscalations(now)
+	if err != nil {
+		LogSystem(logrus.ErrorLevel, "escalation", "查询待升级告警失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+
+	silences, err := loadActiveSilences()
+	if err != nil {
+		LogSystem(logrus.WarnLevel, "escalation", "加载静默规则失败，告警按未静默处理", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	for i := range alerts {
+		escalateAlert(&alerts[i], silences)
+	}
+}
+
+// escalateAlert 执行告警的当前升级步骤并安排下一步
+func escalateAlert(alert *Alert, silences []Silence) {
+	policy := escalationPolicyByName(alert.EscalationPolicy)
+	if policy == nil || alert.EscalationStep >= len(policy.Steps) {
+		stopEscalation(alert, "升级策略已不存在或步骤已执行完毕")
+		return
+	}
+	if silence := findMatchingSilence(silences, alert); silence != nil {
+		stopEscalation(alert, fmt.Sprintf("告警命中静默规则 #%d", silence.ID))
+		return
+	}
+	if alert.InhibitedBy != 0 {
+		stopEscalation(alert, fmt.Sprintf("告警已被告警 #%d 抑制", alert.InhibitedBy))
+		return
+	}
+
+	// 下一步骤按与当前步骤 after_minutes 的差值，从本步骤执行时起算
+	step := policy.Steps[alert.EscalationStep]
+	var nextAt *time.Time
+	if alert.EscalationStep+1 < len(policy.Steps) {
+		delay := policy.Steps[alert.EscalationStep+1].AfterMinutes - step.AfterMinutes
+		next := time.Now().Add(time.Duration(delay) * time.Minute)
+		nextAt = &next
+	}
+
+	// 先推进步骤再发送，避免并发执行时重复通知
+	claimed, err := AdvanceAlertEscalation(alert.ID, alert.EscalationStep, nextAt)
+	if err != nil {
+		LogSystem(logrus.ErrorLevel, "escalation", "推进告警升级失败", map[string]interface{}{
+			"alert_id": alert.ID,
+			"error":    err.Error(),
+		})
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	targets := escalationTargets(alert, step)
+	var userAlertsList []UserAlerts
+	for _, target := range targets {
+		userAlertsList = append(userAlertsList, UserAlerts{Recipient: target, Alerts: []Alert{*alert}})
+	}
+
+	detail := fmt.Sprintf("策略 %s 第 %d 步（%s）通知 %s", policy.Name, alert.EscalationStep+1, step.Name, strings.Join(targets, ", "))
+	if _, err := SendAlertEmail(context.Background(), userAlertsList); err != nil {
+		detail += "，发送失败: " + err.Error()
+		LogSystem(logrus.ErrorLevel, "escalation", "升级通知发送失败", map[string]interface{}{
+			"alert_id": alert.ID,
+			"step":     step.Name,
+			"error":    err.Error(),
+		})
+	}
+	recordAlertEvent(alert.ID, AlertEventEscalated, "system", detail)
+
+	LogSystem(logrus.InfoLevel, "escalation", "告警已升级", map[string]interface{}{
+		"alert_id": alert.ID,
+		"policy":   policy.Name,
+		"step":     step.Name,
+		"targets":  targets,
+	})
+}
+
+// escalationTargets 解析升级步骤的通知对象
+func escalationTargets(alert *Alert, step EscalationStep) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	for _, target := range step.Targets {
+		expanded := []string{target}
+		switch target {
+		case EscalationTargetRecipient:
+			expanded = []string{alert.Recipient}
+		case EscalationTargetAdmin:
+			expanded = adminRecipients()
+		}
+		for _, t := range expanded {
+			if t == "" || seen[t] {
+				continue
+			}
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// stopEscalation 结束告警升级并记录原因
+func stopEscalation(alert *Alert, reason string) {
+	if err := StopAlertEscalation(alert.ID); err != nil {
+		LogSystem(logrus.ErrorLevel, "escalation", "结束告警升级失败", map[string]interface{}{
+			"alert_id": alert.ID,
+			"error":    err.Error(),
+		})
+		return
+	}
+	recordAlertEvent(alert.ID, AlertEventEscalationStopped, "system", reason)
+}
+
+// startEscalationScheduler 启动升级检查任务，与汇总邮件定时任务相互独立。已启动时不重复启动
+func startEscalationScheduler() {
+	if escalationCron != nil || len(currentEscalationPolicies()) == 0 {
+		return
+	}
+
+	interval := config.Routing.EscalationInterval
+	if interval <= 0 {
+		interval = 60
+	}
+
+	c := cron.New(cron.WithLocation(serverLocation))
+	spec := fmt.Sprintf("@every %ds", interval)
+	if _, err := c.AddFunc(spec, runEscalations); err != nil {
+		LogSystem(logrus.FatalLevel, "escalation", "添加升级检查任务失败", map[string]interface{}{
+			"error": err.Error(),
+			"spec":  spec,
+		})
+		log.Fatal("添加升级检查任务失败:", err)
+	}
+
+	c.Start()
+	escalationCron = c
+	LogSystem(logrus.InfoLevel, "escalation", "升级检查任务已启动", map[string]interface{}{
+		"interval_seconds": interval,
+		"policy_count":     len(currentEscalationPolicies()),
+	})
+	log.Printf("升级检查任务已启动，检查间隔: %d 秒", interval)
+}
+
+// GetEscalationPoliciesHandler 查看当前升级策略
+func GetEscalationPoliciesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_escalation_policies_ok"),
+		"data":    currentEscalationPolicies(),
+		"file":    config.Routing.EscalationFile,
+	})
+}
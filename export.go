@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 告警导出格式
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+	ExportFormatXLSX  = "xlsx"
+)
+
+// exportColumns CSV 和 XLSX 导出的列，JSON Lines 每行为完整的告警对象
+var exportColumns = []string{
+	"id", "alert_time", "recipient", "source", "domain", "severity", "status", "route", "message",
+	"acknowledged_by", "acknowledged_at", "resolved_by", "resolved_at", "created_at",
+}
+
+// spreadsheetFormulaPrefixes 表格软件会当作公式解析的开头字符
+const spreadsheetFormulaPrefixes = "=+-@\t\r"
+
+// spreadsheetSafe 以公式字符开头的单元格前加 '，防止 CSV 中的告警内容在 Excel 中被当作公式执行。
+// XLSX 的单元格按 inlineStr 写入，Excel 不会当作公式，不需要处理
+func spreadsheetSafe(value string) string {
+	if value != "" && strings.ContainsRune(spreadsheetFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// spreadsheetSafeRow 对 CSV 一行中的每个单元格应用 spreadsheetSafe
+func spreadsheetSafeRow(cells []string) []string {
+	for i, cell := range cells {
+		cells[i] = spreadsheetSafe(cell)
+	}
+	return cells
+}
+
+// exportRow 告警在 CSV 和 XLSX 中的一行，时间按服务器时区显示
+func exportRow(alert Alert) []string {
+	optionalTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return formatDisplayTime(*t)
+	}
+	return []string{
+		strconv.Itoa(alert.ID),
+		formatDisplayTime(alert.AlertTime),
+		alert.Recipient,
+		alert.Source,
+		alert.Domain,
+		alert.Severity,
+		alert.Status,
+		alert.Route,
+		alert.Message,
+		alert.AcknowledgedBy,
+		optionalTime(alert.AcknowledgedAt),
+		alert.ResolvedBy,
+		optionalTime(alert.ResolvedAt),
+		formatDisplayTime(alert.CreatedAt),
+	}
+}
+
+// alertExporter 逐条写入导出文件
+type alertExporter interface {
+	write(alert Alert) error
+	close() error
+}
+
+// csvExporter 带 BOM 的 UTF-8 CSV
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) (*csvExporter, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return nil, fmt.Errorf("写入CSV失败: %v", err)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return nil, fmt.Errorf("写入CSV失败: %v", err)
+	}
+	return &csvExporter{w: cw}, nil
+}
+
+func (e *csvExporter) write(alert Alert) error {
+	return e.w.Write(spreadsheetSafeRow(exportRow(alert)))
+}
+
+func (e *csvExporter) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonlExporter 每行一个 JSON 对象
+type jsonlExporter struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlExporter) write(alert Alert) error {
+	return e.enc.Encode(alert)
+}
+
+func (e *jsonlExporter) close() error { return nil }
+
+// xlsxExporter 单个工作表的 XLSX
+type xlsxExporter struct {
+	w *xlsxWriter
+}
+
+func newXLSXExporter(w io.Writer) (*xlsxExporter, error) {
+	xw, err := newXLSXWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	if err := xw.WriteRow(exportColumns); err != nil {
+		return nil, err
+	}
+	return &xlsxExporter{w: xw}, nil
+}
+
+func (e *xlsxExporter) write(alert Alert) error {
+	return e.w.WriteRow(exportRow(alert))
+}
+
+func (e *xlsxExporter) close() error {
+	return e.w.Close()
+}
+
+// newAlertExporter 按格式创建导出器，返回导出器、Content-Type 和文件扩展名
+func newAlertExporter(format string, w io.Writer) (alertExporter, string, error) {
+	switch format {
+	case ExportFormatCSV:
+		e, err := newCSVExporter(w)
+		return e, csvContentType, err
+	case ExportFormatJSONL:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return &jsonlExporter{enc: enc}, "application/x-ndjson; charset=utf-8", nil
+	case ExportFormatXLSX:
+		e, err := newXLSXExporter(w)
+		return e, xlsxContentType, err
+	}
+	return nil, "", fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// ExportAlertsHandler 按与列表接口相同的过滤条件导出告警（csv、jsonl、xlsx）。
+// 数据从数据库游标逐行写入响应，不在内存中保留整个结果集；开始输出后出错只能中断响应并记录日志
+func ExportAlertsHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	if format != ExportFormatCSV && format != ExportFormatJSONL && format != ExportFormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.export_format_invalid"),
+		})
+		return
+	}
+	filter, ok := bindAlertFilter(c)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("alerts-%s.%s", time.Now().In(serverLocation).Format("20060102-150405"), format)
+	out := bufio.NewWriterSize(c.Writer, 64*1024)
+	exporter, contentType, err := newAlertExporter(format, out)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.export_failed", err),
+		})
+		return
+	}
+	// 读到第一行（或查询完成）后才发送文件响应头，查询失败时仍可返回 JSON 错误
+	committed := false
+	commit := func() {
+		if committed {
+			return
+		}
+		committed = true
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+	}
+
+	start := time.Now()
+	count, err := StreamAlerts(c.Request.Context(), filter, func(alert Alert) error {
+		commit()
+		if err := exporter.write(alert); err != nil {
+			return fmt.Errorf("写入导出文件失败: %v", err)
+		}
+		return nil
+	})
+	if err == nil {
+		commit()
+		if err = exporter.close(); err == nil {
+			err = out.Flush()
+		}
+	}
+
+	fields := map[string]interface{}{
+		"format":      format,
+		"filter":      filter,
+		"row_count":   count,
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	if err != nil && !committed {
+		fields["error"] = err.Error()
+		LogSystem(logrus.ErrorLevel, "export", "导出告警失败", fields)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.export_failed", err),
+		})
+		return
+	}
+	if err != nil {
+		// 响应头已发送，无法再返回错误码，客户端会收到不完整的文件
+		fields["error"] = err.Error()
+		LogSystem(logrus.ErrorLevel, "export", "导出告警中断", fields)
+		c.Abort()
+		return
+	}
+	LogSystem(logrus.InfoLevel, "export", "导出告警完成", fields)
+}
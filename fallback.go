@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 未知收件人处理策略
+const (
+	UnknownRecipientFallback = "fallback" // 写入告警，发送时转给兜底收件人
+	UnknownRecipientReject   = "reject"   // 写入时直接拒绝，返回 422
+)
+
+// 未解析收件人任务状态
+const (
+	UnresolvedStatusOpen     = "open"
+	UnresolvedStatusResolved = "resolved"
+)
+
+// FallbackRule 兜底规则：收件人未找到时，按告警来源或团队决定转给谁
+type FallbackRule struct {
+	Name       string     `json:"name"`
+	Match      RouteMatch `json:"match"`
+	Team       string     `json:"team,omitempty"` // 告警命中路由的团队
+	Recipients []string   `json:"recipients,omitempty"`
+	Webhook    string     `json:"webhook,omitempty"` // IM群机器人地址，与邮件同时发送
+}
+
+// fallbackGroup 使用同一兜底规则的未找到收件人的告警
+type fallbackGroup struct {
+	Rule          string
+	Recipients    []string
+	Webhook       string
+	Alerts        []UserAlerts
+	NotFoundUsers []string
+}
+
+// UnresolvedRecipient 未解析收件人任务
+type UnresolvedRecipient struct {
+	ID         int        `json:"id"`
+	Recipient  string     `json:"recipient"`
+	AlertCount int        `json:"alert_count"`
+	Status     string     `json:"status"`
+	FirstSeen  time.Time  `json:"first_seen"`
+	LastSeen   time.Time  `json:"last_seen"`
+	ResolvedBy string     `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+}
+
+var (
+	fallbackMu     sync.RWMutex
+	fallbackConfig FallbackConfig
+	fallbackRules  []FallbackRule
+)
+
+// InitFallback 加载兜底收件人配置和兜底规则，重新加载配置时同样调用
+func InitFallback(cfg FallbackConfig) error {
+	if cfg.UnknownPolicy != UnknownRecipientFallback && cfg.UnknownPolicy != UnknownRecipientReject {
+		return fmt.Errorf("UNKNOWN_RECIPIENT_POLICY 必须为 fallback 或 reject")
+	}
+	if len(cfg.Recipients) == 0 && cfg.Webhook == "" {
+		return fmt.Errorf("FALLBACK_RECIPIENTS 和 FALLBACK_WEBHOOK 不能同时为空")
+	}
+	if len(cfg.AdminRecipients) == 0 {
+		cfg.AdminRecipients = cfg.Recipients
+	}
+
+	rules, err := loadFallbackRulesFile(cfg.RulesFile)
+	if err != nil {
+		return err
+	}
+
+	fallbackMu.Lock()
+	fallbackConfig = cfg
+	fallbackRules = rules
+	fallbackMu.Unlock()
+	return nil
+}
+
+// loadFallbackRulesFile 读取兜底规则文件，文件不存在时全部使用默认兜底收件人
+func loadFallbackRulesFile(path string) ([]FallbackRule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取兜底规则文件失败: %v", err)
+	}
+
+	var rules []FallbackRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("解析兜底规则文件失败: %v", err)
+	}
+	for i := range rules {
+		if rules[i].Name == "" {
+			rules[i].Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(rules[i].Recipients) == 0 && rules[i].Webhook == "" {
+			return nil, fmt.Errorf("兜底规则 %s 未配置 recipients 或 webhook", rules[i].Name)
+		}
+	}
+
+	LogSystem(logrus.InfoLevel, "fallback", "兜底规则加载成功", map[string]interface{}{
+		"file":       path,
+		"rule_count": len(rules),
+	})
+	log.Printf("兜底规则加载成功，共 %d 条", len(rules))
+	return rules, nil
+}
+
+// currentFallbackConfig 获取当前兜底配置
+func currentFallbackConfig() FallbackConfig {
+	fallbackMu.RLock()
+	defer fallbackMu.RUnlock()
+	return fallbackConfig
+}
+
+// adminRecipients 系统通知（告警风暴、升级到管理员等）的收件人
+func adminRecipients() []string {
+	return currentFallbackConfig().AdminRecipients
+}
+
+// isRecipientResolvable 判断收件人能否解析到邮箱：邮箱地址、团队收件人或唯一匹配到有邮箱的用户
+func isRecipientResolvable(recipient string) bool {
+	if isTeamRecipient(recipient) {
+		return true
+	}
+	return resolveRecipient(recipient).Found
+}
+
+// rejectUnknownRecipients 按 reject 策略校验收件人，返回无法解析的收件人
+func rejectUnknownRecipients(recipients []string) []string {
+	cfg := currentFallbackConfig()
+	if cfg.UnknownPolicy != UnknownRecipientReject {
+		return nil
+	}
+
+	var unknown []string
+	for _, recipient := range recipients {
+		if !isRecipientResolvable(recipient) {
+			unknown = append(unknown, recipient)
+		}
+	}
+	if len(unknown) > 0 && cfg.CreateTask {
+		counts := make(map[string]int, len(unknown))
+		for _, recipient := range unknown {
+			counts[recipient] = 1
+		}
+		recordUnresolvedRecipients(counts)
+	}
+	return unknown
+}
+
+// findFallbackRule 查找告警匹配的兜底规则
+func findFallbackRule(rules []FallbackRule, alert *Alert) *FallbackRule {
+	var team string
+	for i := range rules {
+		if !rules[i].Match.Matches(alert) {
+			continue
+		}
+		if rules[i].Team != "" {
+			if team == "" {
+				team = RouteAlert(alert)[0].Team
+			}
+			if rules[i].Team != team {
+				continue
+			}
+		}
+		return &rules[i]
+	}
+	return nil
+}
+
+// groupFallbackAlerts 按兜底规则对未找到收件人的告警分组，规则按每个收件人的第一条告警匹配
+func groupFallbackAlerts(fallbackAlerts []UserAlerts) []*fallbackGroup {
+	fallbackMu.RLock()
+	cfg := fallbackConfig
+	rules := fallbackRules
+	fallbackMu.RUnlock()
+
+	groups := make(map[string]*fallbackGroup)
+	var ordered []*fallbackGroup
+	for _, userAlerts := range fallbackAlerts {
+		group := &fallbackGroup{Rule: "default", Recipients: cfg.Recipients, Webhook: cfg.Webhook}
+		if len(userAlerts.Alerts) > 0 {
+			if rule := findFallbackRule(rules, &userAlerts.Alerts[0]); rule != nil {
+				group = &fallbackGroup{Rule: rule.Name, Recipients: rule.Recipients, Webhook: rule.Webhook}
+			}
+		}
+
+		if existing, ok := groups[group.Rule]; ok {
+			group = existing
+		} else {
+			groups[group.Rule] = group
+			ordered = append(ordered, group)
+		}
+		group.Alerts = append(group.Alerts, userAlerts)
+		group.NotFoundUsers = append(group.NotFoundUsers, userAlerts.Recipient)
+	}
+	return ordered
+}
+
+// sendFallbackWebhook 将未找到收件人的告警摘要发送到IM群机器人
+func sendFallbackWebhook(ctx context.Context, webhook string, group *fallbackGroup) (*GatewayResponse, error) {
+	var b strings.Builder
+	fmt.Fprintln(&b, T(defaultLocale, "im.fallback.header", strings.Join(group.NotFoundUsers, ", ")))
+	count := 0
+	for _, userAlerts := range group.Alerts {
+		for _, alert := range userAlerts.Alerts {
+			count++
+			if count <= 20 {
+				fmt.Fprintf(&b, "%d. [%s] %s %s\n", count, userAlerts.Recipient, formatDisplayTime(alert.AlertTime), alert.Message)
+			}
+		}
+	}
+	if count > 20 {
+		fmt.Fprintln(&b, T(defaultLocale, "im.fallback.total", count))
+	}
+
+	return postIMText(ctx, webhook, b.String())
+}
+
+// postIMText 以文本消息格式推送到IM机器人，兼容企业微信、钉钉等群机器人，返回机器人的响应
+func postIMText(ctx context.Context, webhook, content string) (*GatewayResponse, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"msgtype": "text",
+		"text":    map[string]string{"content": content},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化群消息失败: %v", err)
+	}
+
+	if err := waitGateway(ctx, webhook); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("创建群消息请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: gatewayRequestTimeout()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送群消息失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	gateway := &GatewayResponse{Status: resp.StatusCode, Message: string(body)}
+	if resp.StatusCode != http.StatusOK {
+		return gateway, fmt.Errorf("群机器人返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
+	}
+
+	// 企业微信、钉钉机器人在HTTP 200时通过 errcode 表示失败
+	var robotResp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if json.Unmarshal(body, &robotResp) == nil {
+		gateway.Code = robotResp.ErrCode
+		gateway.Message = robotResp.ErrMsg
+		if robotResp.ErrCode != 0 {
+			return gateway, fmt.Errorf("群机器人返回错误, errcode=%d, errmsg=%s", robotResp.ErrCode, robotResp.ErrMsg)
+		}
+	}
+	return gateway, nil
+}
+
+// createUnresolvedRecipientTable 创建未解析收件人任务表
+func createUnresolvedRecipientTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS unresolved_recipients (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		recipient VARCHAR(255) NOT NULL,
+		alert_count INT NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL DEFAULT 'open',
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL,
+		resolved_by VARCHAR(100) NOT NULL DEFAULT '',
+		resolved_at DATETIME NULL,
+		comment TEXT,
+		UNIQUE KEY uk_recipient (recipient),
+		INDEX idx_status (status)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// UpsertUnresolvedRecipient 记录未解析收件人，已处理的任务再次出现时重新打开并清空上次的处理人、处理时间和备注
+func UpsertUnresolvedRecipient(recipient string, alertCount int) error {
+	now := time.Now()
+	_, err := db.Exec(`INSERT INTO unresolved_recipients (recipient, alert_count, status, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE alert_count = alert_count + VALUES(alert_count), last_seen = VALUES(last_seen),
+			status = VALUES(status), resolved_by = '', resolved_at = NULL, comment = NULL`,
+		recipient, alertCount, UnresolvedStatusOpen, now, now)
+	if err != nil {
+		LogDatabase("INSERT", "unresolved_recipients", false, err.Error(), 0)
+		return fmt.Errorf("记录未解析收件人失败: %v", err)
+	}
+
+	LogDatabase("INSERT", "unresolved_recipients", true, "", 1)
+	return nil
+}
+
+// GetUnresolvedRecipients 查询未解析收件人任务，status 为空时返回全部
+func GetUnresolvedRecipients(status string) ([]UnresolvedRecipient, error) {
+	query := `SELECT id, recipient, alert_count, status, first_seen, last_seen, resolved_by, resolved_at, comment
+		FROM unresolved_recipients`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY last_seen DESC`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		LogDatabase("SELECT", "unresolved_recipients", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询未解析收件人失败: %v", err)
+	}
+	defer rows.Close()
+
+	tasks := []UnresolvedRecipient{}
+	for rows.Next() {
+		var task UnresolvedRecipient
+		var resolvedAt sql.NullTime
+		var comment sql.NullString
+		if err := rows.Scan(&task.ID, &task.Recipient, &task.AlertCount, &task.Status, &task.FirstSeen,
+			&task.LastSeen, &task.ResolvedBy, &resolvedAt, &comment); err != nil {
+			LogDatabase("SELECT", "unresolved_recipients", false, err.Error(), 0)
+			return nil, fmt.Errorf("扫描未解析收件人失败: %v", err)
+		}
+		if resolvedAt.Valid {
+			task.ResolvedAt = &resolvedAt.Time
+		}
+		task.Comment = comment.String
+		tasks = append(tasks, task)
+	}
+
+	LogDatabase("SELECT", "unresolved_recipients", true, "", int64(len(tasks)))
+	return tasks, nil
+}
+
+// ResolveUnresolvedRecipient 将未解析收件人任务标记为已处理，返回是否更新成功
+func ResolveUnresolvedRecipient(id int, by, comment string) (bool, error) {
+	result, err := db.Exec(`UPDATE unresolved_recipients SET status = ?, resolved_by = ?, resolved_at = ?, comment = ?
+		WHERE id = ? AND status = ?`, UnresolvedStatusResolved, by, time.Now(), comment, id, UnresolvedStatusOpen)
+	if err != nil {
+		LogDatabase("UPDATE", "unresolved_recipients", false, err.Error(), 0)
+		return false, fmt.Errorf("处理未解析收件人失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "unresolved_recipients", true, "", affected)
+	return affected > 0, nil
+}
+
+// recordUnresolvedRecipients 为未解析收件人创建或更新任务，失败时仅记录日志
+func recordUnresolvedRecipients(alertCounts map[string]int) {
+	for recipient, count := range alertCounts {
+		if err := UpsertUnresolvedRecipient(recipient, count); err != nil {
+			LogSystem(logrus.WarnLevel, "fallback", "记录未解析收件人失败", map[string]interface{}{
+				"recipient": recipient,
+				"error":     err.Error(),
+			})
+		}
+	}
+}
+
+// GetUnresolvedRecipientsHandler 查询未解析收件人任务，支持 status=open/resolved 过滤
+func GetUnresolvedRecipientsHandler(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != UnresolvedStatusOpen && status != UnresolvedStatusResolved {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.unresolved_status_invalid"),
+		})
+		return
+	}
+
+	tasks, err := GetUnresolvedRecipients(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_tasks_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_tasks_ok"),
+		"data":    tasks,
+		"total":   len(tasks),
+	})
+}
+
+// ResolveUnresolvedRecipientHandler 标记未解析收件人任务已处理
+func ResolveUnresolvedRecipientHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.task_id_invalid"),
+		})
+		return
+	}
+
+	var req AlertActionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_request", err),
+			})
+			return
+		}
+	}
+
+	updated, err := ResolveUnresolvedRecipient(id, req.By, req.Comment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.resolve_task_failed", err),
+		})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": apiMessage(c, "api.task_not_found"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.resolve_task_ok"),
+	})
+}
@@ -1,7 +1,10 @@
 ﻿package main
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -10,6 +13,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// maxBatchAlerts 批量创建接口单次允许提交的最大条目数
+const maxBatchAlerts = 100
+
 // CreateAlert 创建预警信息
 func CreateAlert(c *gin.Context) {
 	var req CreateAlertRequest
@@ -20,7 +26,7 @@ func CreateAlert(c *gin.Context) {
 		})
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
-			"message": "请求参数错误: " + err.Error(),
+			"message": apiMessage(c, "api.invalid_request", err),
 		})
 		return
 	}
@@ -28,80 +34,354 @@ func CreateAlert(c *gin.Context) {
 	LogSystem(logrus.InfoLevel, "handler", "收到创建告警请求", map[string]interface{}{
 		"message": req.Message,
 		"recipient": req.Recipient,
+		"source": req.Source,
 		"alert_time": req.AlertTime,
 		"client_ip": c.ClientIP(),
 	})
 
+	// 幂等处理：相同Idempotency-Key的重试直接返回首次响应
+	idem, done := beginIdempotentRequest(c, "alerts", req.RequestID, req)
+	if done {
+		return
+	}
+	defer idem.release()
+
+	if !checkAlertRateLimit(c, req.Source, 1) {
+		return
+	}
+
+	alerts, reqErr := buildAlerts(req)
+	if reqErr != nil {
+		c.JSON(reqErr.Status, gin.H{
+			"code":    reqErr.Status,
+			"message": apiMessage(c, reqErr.Key, reqErr.Args...),
+		})
+		return
+	}
+
+	createdAlerts, newAlerts, collapsedCount, err := saveAlerts(alerts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.store_alert_failed", err),
+		})
+		return
+	}
+
+	notifyImmediateAlerts(newAlerts)
+	startEscalations(newAlerts)
+
+	LogSystem(logrus.InfoLevel, "handler", "告警创建成功", map[string]interface{}{
+		"alert_count": len(createdAlerts),
+		"collapsed_count": collapsedCount,
+		"recipient": req.Recipient,
+	})
+
+	idem.respond(c, http.StatusOK, createdAlerts, gin.H{
+		"code":      200,
+		"message":   apiMessage(c, "api.create_alert_ok"),
+		"data":      createdAlerts,
+		"count":     len(createdAlerts),
+		"collapsed": collapsedCount,
+	})
+}
+
+// CreateAlertsBatch 批量创建预警信息
+func CreateAlertsBatch(c *gin.Context) {
+	var req BatchCreateAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		LogSystem(logrus.WarnLevel, "handler", "批量创建告警请求参数错误", map[string]interface{}{
+			"error": err.Error(),
+			"client_ip": c.ClientIP(),
+		})
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+
+	if len(req.Alerts) > maxBatchAlerts {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.batch_too_large", maxBatchAlerts),
+		})
+		return
+	}
+
+	LogSystem(logrus.InfoLevel, "handler", "收到批量创建告警请求", map[string]interface{}{
+		"item_count": len(req.Alerts),
+		"client_ip": c.ClientIP(),
+	})
+
+	idem, done := beginIdempotentRequest(c, "alerts_batch", req.RequestID, req)
+	if done {
+		return
+	}
+	defer idem.release()
+
+	source := ""
+	if len(req.Alerts) > 0 {
+		source = req.Alerts[0].Source
+	}
+	if !checkAlertRateLimit(c, source, len(req.Alerts)) {
+		return
+	}
+
+	// 先校验全部条目，避免部分写入
+	var alerts []*Alert
+	for i, item := range req.Alerts {
+		itemAlerts, reqErr := buildAlerts(item)
+		if reqErr != nil {
+			c.JSON(reqErr.Status, gin.H{
+				"code":    reqErr.Status,
+				"message": apiMessage(c, "api.batch_item_error", i+1, apiMessage(c, reqErr.Key, reqErr.Args...)),
+				"index":   i,
+			})
+			return
+		}
+		alerts = append(alerts, itemAlerts...)
+	}
+
+	createdAlerts, newAlerts, collapsedCount, err := saveAlerts(alerts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.store_alert_failed", err),
+		})
+		return
+	}
+
+	notifyImmediateAlerts(newAlerts)
+	startEscalations(newAlerts)
+
+	LogSystem(logrus.InfoLevel, "handler", "批量告警创建成功", map[string]interface{}{
+		"item_count": len(req.Alerts),
+		"alert_count": len(createdAlerts),
+		"collapsed_count": collapsedCount,
+	})
+
+	idem.respond(c, http.StatusOK, createdAlerts, gin.H{
+		"code":      200,
+		"message":   apiMessage(c, "api.create_alerts_ok"),
+		"data":      createdAlerts,
+		"count":     len(createdAlerts),
+		"collapsed": collapsedCount,
+	})
+}
+
+// alertRequestError 告警请求校验错误，携带HTTP状态码，消息按请求语言从消息目录生成
+type alertRequestError struct {
+	Status int
+	Key    string
+	Args   []interface{}
+}
+
+// checkAlertRateLimit 按API Key/来源/IP限流，每条告警消耗一个令牌，被限流时写入429响应并返回false。
+// 条数超过桶容量（RATE_LIMIT_BURST）的请求无论等待多久都无法放行，直接返回413
+func checkAlertRateLimit(c *gin.Context, source string, count int) bool {
+	if alertRateLimiter == nil {
+		return true
+	}
+
+	limitKey := rateLimitKey(c, source)
+	if burst := alertRateLimiter.Burst(); count > burst {
+		LogSystem(logrus.WarnLevel, "handler", "告警写入条数超过限流容量", map[string]interface{}{
+			"limit_key": limitKey,
+			"alert_count": count,
+			"burst": burst,
+			"client_ip": c.ClientIP(),
+		})
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"code":    413,
+			"message": apiMessage(c, "api.rate_limit_burst_exceeded", count, burst),
+		})
+		return false
+	}
+	allowed, wait := alertRateLimiter.AllowN(limitKey, count)
+	if allowed {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	LogSystem(logrus.WarnLevel, "handler", "告警写入触发限流", map[string]interface{}{
+		"limit_key": limitKey,
+		"alert_count": count,
+		"retry_after": retryAfter,
+		"client_ip": c.ClientIP(),
+	})
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"code":    429,
+		"message": apiMessage(c, "api.rate_limited", retryAfter),
+	})
+	return false
+}
+
+// buildAlerts 校验请求并为每个收件人生成待存储的告警记录
+func buildAlerts(req CreateAlertRequest) ([]*Alert, *alertRequestError) {
 	// 解析预警时间
 	var alertTime time.Time
 	var err error
 	if req.AlertTime != "" {
-		alertTime, err = time.Parse("2006-01-02 15:04:05", req.AlertTime)
+		alertTime, err = parseFlexibleTime(req.AlertTime)
 		if err != nil {
 			LogSystem(logrus.WarnLevel, "handler", "告警时间格式错误", map[string]interface{}{
 				"alert_time": req.AlertTime,
 				"error": err.Error(),
 			})
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code":    400,
-				"message": "预警时间格式错误，请使用 YYYY-MM-DD HH:mm:ss 格式",
-			})
-			return
+			return nil, &alertRequestError{
+				Status: http.StatusBadRequest,
+				Key:    "api.invalid_alert_time",
+			}
 		}
 	} else {
-		alertTime = time.Now()
+		alertTime = time.Now().In(serverLocation)
+	}
+
+	domain := req.Domain
+	if domain == "" {
+		domain = extractDomain(req.Message)
 	}
+	severity := req.Severity
+	if severity == "" {
+		severity = "warning"
+	}
+
+	base := Alert{
+		Message:   req.Message,
+		Source:    req.Source,
+		Domain:    domain,
+		Severity:  severity,
+		Labels:    req.Labels,
+		AlertTime: alertTime,
+	}
+
+	// 路由规则：记录命中的路由，未指定收件人时由路由决定
+	routes := RouteAlert(&base)
+	base.Route = routes[0].Route
 
 	// 解析收件人列表（支持逗号分隔）
 	recipients := parseRecipients(req.Recipient)
+	if len(recipients) == 0 {
+		recipients = routeRecipients(routes)
+	}
 	if len(recipients) == 0 {
 		LogSystem(logrus.WarnLevel, "handler", "收件人为空", map[string]interface{}{
 			"recipient_input": req.Recipient,
+			"route": base.Route,
 		})
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "收件人不能为空",
-		})
-		return
+		return nil, &alertRequestError{
+			Status: http.StatusBadRequest,
+			Key:    "api.recipient_missing",
+		}
 	}
 
-	// 为每个收件人创建告警记录
-	var createdAlerts []Alert
 	for _, recipient := range recipients {
-	alert := &Alert{
-		Message:   req.Message,
-			Recipient: recipient,
-		AlertTime: alertTime,
+		if err := validateTeamRecipient(recipient); err != nil {
+			return nil, &alertRequestError{
+				Status: http.StatusBadRequest,
+				Key:    "api.recipient_invalid",
+				Args:   []interface{}{err},
+			}
+		}
+	}
+	if unknown := rejectUnknownRecipients(recipients); len(unknown) > 0 {
+		return nil, &alertRequestError{
+			Status: http.StatusUnprocessableEntity,
+			Key:    "api.recipient_unresolvable",
+			Args:   []interface{}{strings.Join(unknown, ", ")},
+		}
 	}
 
-	// 插入数据库
-	if err := InsertAlert(alert); err != nil {
-			LogAlert("create", 0, recipient, req.Message, false, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "存储预警信息失败: " + err.Error(),
-		})
-		return
+	var alerts []*Alert
+	for _, recipient := range recipients {
+		alert := base
+		alert.Recipient = recipient
+		alerts = append(alerts, &alert)
 	}
+	return alerts, nil
+}
 
-		LogAlert("create", int64(alert.ID), recipient, req.Message, true, "")
-		createdAlerts = append(createdAlerts, *alert)
+// domainPattern 匹配消息中【】包裹的域名
+var domainPattern = regexp.MustCompile(`【([^】]+)】`)
+
+// extractDomain 从告警消息中提取域名，如 "检测到域名【search.suggest.kgidc.cn】..."
+func extractDomain(message string) string {
+	if match := domainPattern.FindStringSubmatch(message); match != nil {
+		return strings.TrimSpace(match[1])
 	}
+	return ""
+}
 
-	LogSystem(logrus.InfoLevel, "handler", "告警创建成功", map[string]interface{}{
-		"alert_count": len(createdAlerts),
-		"recipients": recipients,
-	})
+// saveAlerts 存储告警记录，告警风暴时合并到汇总告警。
+// 返回存储后的告警、需要通知和启动升级的新告警（合并到已有汇总的告警不包含在内）以及被合并的数量
+func saveAlerts(alerts []*Alert) ([]Alert, []Alert, int, error) {
+	// 命中静默规则的告警照常存储，但标记为已静默
+	applyIngestSilences(alerts)
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    200,
-		"message": "预警信息创建成功",
-		"data":    createdAlerts,
-		"count":   len(createdAlerts),
-	})
+	// 同一请求的告警（包括风暴汇总告警）在一个事务中写入，中途失败时全部回滚，客户端用同一幂等键重试不会产生重复记录。
+	// 风暴检测器的计数和汇总记录在事务提交后才生效，回滚时不会计入
+	tx, err := db.Begin()
+	if err != nil {
+		LogDatabase("BEGIN", "alerts", false, err.Error(), 0)
+		return nil, nil, 0, fmt.Errorf("开启事务失败: %v", err)
+	}
+
+	var storms *stormBatch
+	if alertStormDetector != nil {
+		storms = alertStormDetector.Begin()
+	}
+
+	var createdAlerts, newAlerts []Alert
+	var inserted []*Alert
+	collapsedCount := 0
+	for _, alert := range alerts {
+		// 告警风暴时合并到汇总告警，不再单独存储
+		if storms != nil {
+			collapsed, created, err := storms.Collapse(tx, alert)
+			if err != nil {
+				tx.Rollback()
+				LogAlert("create", 0, alert.Recipient, alert.Message, false, err.Error())
+				return nil, nil, 0, err
+			}
+			if collapsed {
+				LogAlert("collapse", int64(alert.ID), alert.Recipient, alert.Message, true, "")
+				collapsedCount++
+				createdAlerts = append(createdAlerts, *alert)
+				if created {
+					newAlerts = append(newAlerts, *alert)
+				}
+				continue
+			}
+		}
+
+		// 插入数据库
+		if err := insertAlert(tx, alert); err != nil {
+			tx.Rollback()
+			LogAlert("create", 0, alert.Recipient, alert.Message, false, err.Error())
+			return nil, nil, 0, err
+		}
+		inserted = append(inserted, alert)
+		createdAlerts = append(createdAlerts, *alert)
+	}
+
+	if err := tx.Commit(); err != nil {
+		LogDatabase("COMMIT", "alerts", false, err.Error(), 0)
+		return nil, nil, 0, fmt.Errorf("提交告警信息失败: %v", err)
+	}
+	if storms != nil {
+		storms.Commit()
+	}
+	for _, alert := range inserted {
+		LogAlert("create", int64(alert.ID), alert.Recipient, alert.Message, true, "")
+		newAlerts = append(newAlerts, *alert)
+	}
+	return createdAlerts, newAlerts, collapsedCount, nil
 }
 
-// parseRecipients 解析收件人字符串，支持逗号分隔
+// parseRecipients 解析收件人字符串，支持逗号分隔。
+// 通过别名、姓名等唯一匹配到用户的收件人统一为英文名，并去重
 func parseRecipients(recipientStr string) []string {
 	// 支持中文逗号和英文逗号
 	recipientStr = strings.ReplaceAll(recipientStr, "，", ",")
@@ -109,18 +389,24 @@ func parseRecipients(recipientStr string) []string {
 	// 按逗号分割
 	parts := strings.Split(recipientStr, ",")
 	var recipients []string
+	seen := make(map[string]bool)
 	
 	for _, part := range parts {
 		trimmed := strings.TrimSpace(part)
-		if trimmed != "" {
-			recipients = append(recipients, trimmed)
+		if trimmed == "" {
+			continue
+		}
+		recipient := canonicalRecipient(trimmed)
+		if !seen[recipient] {
+			seen[recipient] = true
+			recipients = append(recipients, recipient)
 		}
 	}
 	
 	return recipients
 }
 
-// GetAlertsHandler 获取所有预警信息
+// GetAlertsHandler 按条件分页获取预警信息
 func GetAlertsHandler(c *gin.Context) {
 	// 获取分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -133,43 +419,148 @@ func GetAlertsHandler(c *gin.Context) {
 		pageSize = 20
 	}
 
-	alerts, err := GetAlerts()
+	filter, ok := bindAlertFilter(c)
+	if !ok {
+		return
+	}
+
+	alerts, total, err := GetAlerts(filter, pageSize, (page-1)*pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "获取预警信息失败: " + err.Error(),
+			"message": apiMessage(c, "api.get_alerts_failed", err),
 		})
 		return
 	}
 
-	// 简单的分页处理
-	start := (page - 1) * pageSize
-	end := start + pageSize
-	if start >= len(alerts) {
-		alerts = []Alert{}
-	} else if end > len(alerts) {
-		alerts = alerts[start:]
-	} else {
-		alerts = alerts[start:end]
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "获取预警信息成功",
+		"message": apiMessage(c, "api.get_alerts_ok"),
 		"data":    alerts,
-		"total":   len(alerts),
+		"total":   total,
 		"page":    page,
 		"size":    pageSize,
 	})
 }
 
+// bindAlertFilter 解析列表和导出接口共用的过滤参数：recipient、source、domain、severity、status、start_time、end_time，
+// 时间格式错误时直接写入响应
+func bindAlertFilter(c *gin.Context) (AlertFilter, bool) {
+	filter := AlertFilter{
+		Recipient: strings.TrimSpace(c.Query("recipient")),
+		Source:    strings.TrimSpace(c.Query("source")),
+		Domain:    strings.TrimSpace(c.Query("domain")),
+		Severity:  strings.TrimSpace(c.Query("severity")),
+		Status:    strings.TrimSpace(c.Query("status")),
+	}
+	if value := c.Query("start_time"); value != "" {
+		startTime, err := parseFlexibleTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_start_time"),
+			})
+			return filter, false
+		}
+		filter.StartTime = startTime
+	}
+	if value := c.Query("end_time"); value != "" {
+		endTime, err := parseFlexibleTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_end_time"),
+			})
+			return filter, false
+		}
+		filter.EndTime = endTime
+	}
+	return filter, true
+}
+
+// GetAlertStatsHandler 按 recipient、source、domain、severity 或时间粒度（hour、day、week）分组统计告警数量，
+// group_by 可用逗号组合多个维度（最多一个时间粒度），过滤条件与列表接口相同，未指定 start_time 时统计最近 7 天
+func GetAlertStatsHandler(c *gin.Context) {
+	var groupBy []string
+	seen := map[string]bool{}
+	timeBuckets := 0
+	for _, dimension := range strings.Split(c.DefaultQuery("group_by", "domain"), ",") {
+		dimension = strings.TrimSpace(dimension)
+		if _, ok := alertStatsDimensions[dimension]; !ok || seen[dimension] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.stats_group_by_invalid"),
+			})
+			return
+		}
+		seen[dimension] = true
+		if isTimeBucket(dimension) {
+			timeBuckets++
+		}
+		groupBy = append(groupBy, dimension)
+	}
+	if timeBuckets > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.stats_group_by_invalid"),
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	filter, ok := bindAlertFilter(c)
+	if !ok {
+		return
+	}
+	if filter.EndTime.IsZero() {
+		filter.EndTime = time.Now()
+	}
+	if filter.StartTime.IsZero() {
+		filter.StartTime = filter.EndTime.AddDate(0, 0, -7)
+	}
+
+	total, err := CountAlerts(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_alert_stats_failed", err),
+		})
+		return
+	}
+	buckets, truncated, err := GetAlertStats(filter, groupBy, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_alert_stats_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.alert_stats_ok"),
+		"data": gin.H{
+			"group_by":   groupBy,
+			"start_time": formatDisplayTime(filter.StartTime),
+			"end_time":   formatDisplayTime(filter.EndTime),
+			"total":      total,
+			"buckets":    buckets,
+			"truncated":  truncated,
+		},
+	})
+}
+
 // GetAlertsByPeriod 根据时间段获取预警信息
 func GetAlertsByPeriod(c *gin.Context) {
 	var req PeriodRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
-			"message": "请求参数错误: " + err.Error(),
+			"message": apiMessage(c, "api.invalid_request", err),
 		})
 		return
 	}
@@ -179,50 +570,50 @@ func GetAlertsByPeriod(c *gin.Context) {
 	var err error
 
 	if req.StartTime != "" {
-		startTime, err = time.Parse("2006-01-02 15:04:05", req.StartTime)
+		startTime, err = parseFlexibleTime(req.StartTime)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"code":    400,
-				"message": "开始时间格式错误，请使用 YYYY-MM-DD HH:mm:ss 格式",
+				"message": apiMessage(c, "api.invalid_start_time"),
 			})
 			return
 		}
 	} else {
 		// 默认查询今天的数据
-		now := time.Now()
-		startTime = time.Date(now.Year(), now.Month(), now.Day(), 19, 0, 0, 0, now.Location())
+		now := time.Now().In(serverLocation)
+		startTime = time.Date(now.Year(), now.Month(), now.Day(), 19, 0, 0, 0, serverLocation)
 	}
 
 	if req.EndTime != "" {
-		endTime, err = time.Parse("2006-01-02 15:04:05", req.EndTime)
+		endTime, err = parseFlexibleTime(req.EndTime)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"code":    400,
-				"message": "结束时间格式错误，请使用 YYYY-MM-DD HH:mm:ss 格式",
+				"message": apiMessage(c, "api.invalid_end_time"),
 			})
 			return
 		}
 	} else {
 		// 默认查询到今天结束
-		now := time.Now()
-		endTime = time.Date(now.Year(), now.Month(), now.Day(), 22, 59, 59, 999999999, now.Location())
+		now := time.Now().In(serverLocation)
+		endTime = time.Date(now.Year(), now.Month(), now.Day(), 22, 59, 59, 999999999, serverLocation)
 	}
 
 	alerts, err := GetAlertsByTimeRange(startTime, endTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "获取预警信息失败: " + err.Error(),
+			"message": apiMessage(c, "api.get_alerts_failed", err),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":      200,
-		"message":   "获取预警信息成功",
+		"message":   apiMessage(c, "api.get_alerts_ok"),
 		"data":      alerts,
-		"start_time": startTime.Format("2006-01-02 15:04:05"),
-		"end_time":   endTime.Format("2006-01-02 15:04:05"),
+		"start_time": formatAPITime(startTime),
+		"end_time":   formatAPITime(endTime),
 		"total":     len(alerts),
 	})
 } 
@@ -233,7 +624,7 @@ func GetAlertsByRecipientHandler(c *gin.Context) {
 	if recipient == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
-			"message": "收件人参数不能为空",
+			"message": apiMessage(c, "api.recipient_param_required"),
 		})
 		return
 	}
@@ -242,14 +633,14 @@ func GetAlertsByRecipientHandler(c *gin.Context) {
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "获取预警信息失败: " + err.Error(),
+			"message": apiMessage(c, "api.get_alerts_failed", err),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":      200,
-		"message":   "获取预警信息成功",
+		"message":   apiMessage(c, "api.get_alerts_ok"),
 		"data":      alerts,
 		"recipient": recipient,
 		"total":     len(alerts),
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的语言，与 locales 目录下的文件名对应
+const (
+	LocaleZhCN = "zh-CN"
+	LocaleEnUS = "en-US"
+)
+
+// supportedLocales 消息目录支持的语言，第一个为目录校验的基准
+var supportedLocales = []string{LocaleZhCN, LocaleEnUS}
+
+// embeddedLocales 内置的消息目录，每种语言一个 key → 格式化字符串的 JSON 文件
+//
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+var (
+	messageCatalog = map[string]map[string]string{}
+	defaultLocale  = LocaleZhCN
+)
+
+// formatVerbPattern 匹配格式化字符串中的占位符，用于校验各语言的参数一致
+var formatVerbPattern = regexp.MustCompile(`%[-+# 0]*[0-9]*(?:\.[0-9]+)?[a-zA-Z%]`)
+
+// InitI18n 加载消息目录并设置默认语言。各语言的 key 集合与占位符必须完全一致，
+// 缺少翻译或参数不匹配时启动失败，避免发送出缺字或格式错乱的通知
+func InitI18n(locale string) error {
+	catalog := make(map[string]map[string]string, len(supportedLocales))
+	for _, name := range supportedLocales {
+		data, err := embeddedLocales.ReadFile(path.Join("locales", name+".json"))
+		if err != nil {
+			return fmt.Errorf("读取消息目录 %s 失败: %v", name, err)
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("解析消息目录 %s 失败: %v", name, err)
+		}
+		catalog[name] = messages
+	}
+	if err := validateCatalog(catalog); err != nil {
+		return err
+	}
+
+	normalized, ok := normalizeLocale(locale)
+	if !ok {
+		return fmt.Errorf("默认语言无效: %s，支持 %s", locale, strings.Join(supportedLocales, "、"))
+	}
+	messageCatalog = catalog
+	defaultLocale = normalized
+	return nil
+}
+
+// validateCatalog 校验每种语言包含基准语言的全部 key，且同一 key 的占位符顺序一致
+func validateCatalog(catalog map[string]map[string]string) error {
+	base := catalog[supportedLocales[0]]
+	var problems []string
+	for _, locale := range supportedLocales[1:] {
+		messages := catalog[locale]
+		for key, text := range base {
+			translated, ok := messages[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s 缺少 %s", locale, key))
+				continue
+			}
+			if want, got := formatVerbs(text), formatVerbs(translated); want != got {
+				problems = append(problems, fmt.Sprintf("%s 的 %s 占位符为 [%s]，应为 [%s]", locale, key, got, want))
+			}
+		}
+		for key := range messages {
+			if _, ok := base[key]; !ok {
+				problems = append(problems, fmt.Sprintf("%s 多出 %s", locale, key))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("消息目录不一致: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// formatVerbs 提取格式化字符串中的占位符（不含 %%）
+func formatVerbs(text string) string {
+	var verbs []string
+	for _, verb := range formatVerbPattern.FindAllString(text, -1) {
+		if verb != "%%" {
+			verbs = append(verbs, verb)
+		}
+	}
+	return strings.Join(verbs, " ")
+}
+
+// normalizeLocale 将 zh、zh_CN、zh-Hans、en、en-GB 等语言标签归一为支持的语言
+func normalizeLocale(tag string) (string, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	switch {
+	case tag == "zh" || strings.HasPrefix(tag, "zh-"):
+		return LocaleZhCN, true
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return LocaleEnUS, true
+	}
+	return "", false
+}
+
+// localeOrDefault 归一语言标签，为空或不支持时使用默认语言
+func localeOrDefault(tag string) string {
+	if locale, ok := normalizeLocale(tag); ok {
+		return locale
+	}
+	return defaultLocale
+}
+
+// lookupMessage 查找消息，当前语言缺少时使用默认语言
+func lookupMessage(locale, key string) (string, bool) {
+	if text, ok := messageCatalog[locale][key]; ok {
+		return text, true
+	}
+	text, ok := messageCatalog[defaultLocale][key]
+	return text, ok
+}
+
+// T 按语言格式化消息，key 不存在时原样返回 key
+func T(locale, key string, args ...interface{}) string {
+	text, ok := lookupMessage(locale, key)
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// acceptLanguage 按 Accept-Language 的 q 值选出第一个支持的语言，没有时返回默认语言
+func acceptLanguage(header string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if locale, ok := normalizeLocale(c.tag); ok {
+			return locale
+		}
+	}
+	return defaultLocale
+}
+
+// requestLocale 请求使用的语言，取自 Accept-Language 请求头
+func requestLocale(c *gin.Context) string {
+	return acceptLanguage(c.GetHeader("Accept-Language"))
+}
+
+// apiMessage 按请求语言格式化接口错误消息
+func apiMessage(c *gin.Context, key string, args ...interface{}) string {
+	return T(requestLocale(c), key, args...)
+}
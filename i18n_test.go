@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestEmbeddedCatalogValid 内置消息目录各语言的 key 和占位符一致
+func TestEmbeddedCatalogValid(t *testing.T) {
+	if err := InitI18n(LocaleZhCN); err != nil {
+		t.Fatalf("InitI18n: %v", err)
+	}
+	if err := validateCatalog(messageCatalog); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestValidateCatalogDetectsProblems 缺少、多出的 key 和占位符不一致都会被发现
+func TestValidateCatalogDetectsProblems(t *testing.T) {
+	cases := []struct {
+		name    string
+		catalog map[string]map[string]string
+		want    string
+	}{
+		{
+			name: "missing",
+			catalog: map[string]map[string]string{
+				LocaleZhCN: {"a": "甲", "b": "乙"},
+				LocaleEnUS: {"a": "A"},
+			},
+			want: "缺少 b",
+		},
+		{
+			name: "extra",
+			catalog: map[string]map[string]string{
+				LocaleZhCN: {"a": "甲"},
+				LocaleEnUS: {"a": "A", "c": "C"},
+			},
+			want: "多出 c",
+		},
+		{
+			name: "verbs",
+			catalog: map[string]map[string]string{
+				LocaleZhCN: {"a": "%s 共 %d 条"},
+				LocaleEnUS: {"a": "%d items for %s"},
+			},
+			want: "占位符",
+		},
+	}
+	for _, tc := range cases {
+		err := validateCatalog(tc.catalog)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: validateCatalog = %v, want error containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+// messageKeyPattern 代码中引用的消息 key（T、apiMessage 以及各类错误结构中的 key 字面量）
+var messageKeyPattern = regexp.MustCompile(`"((?:api|email|im)\.[a-z0-9_.]+)"`)
+
+// templateKeyPattern 模板中 {{.T "key"}} 引用的消息 key
+var templateKeyPattern = regexp.MustCompile(`\.T\s+"([^"]+)"`)
+
+// TestMessageKeysExist 代码和内置模板引用的 key 都存在于消息目录中
+func TestMessageKeysExist(t *testing.T) {
+	if err := InitI18n(LocaleZhCN); err != nil {
+		t.Fatalf("InitI18n: %v", err)
+	}
+
+	used := make(map[string][]string)
+	scan := func(pattern string, re *regexp.Regexp) {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			if strings.HasSuffix(file, "_test.go") {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, match := range re.FindAllStringSubmatch(string(data), -1) {
+				used[match[1]] = append(used[match[1]], file)
+			}
+		}
+	}
+	scan("*.go", messageKeyPattern)
+	scan("*.go", templateKeyPattern)
+	scan("templates/*", templateKeyPattern)
+	if len(used) == 0 {
+		t.Fatal("未找到任何消息 key 引用")
+	}
+
+	var missing []string
+	for key, files := range used {
+		for _, locale := range supportedLocales {
+			if _, ok := messageCatalog[locale][key]; !ok {
+				missing = append(missing, locale+" "+key+" ("+files[0]+")")
+			}
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("消息目录缺少以下 key:\n%s", strings.Join(missing, "\n"))
+	}
+}
+
+// TestAcceptLanguage 按 q 值从高到低选出第一个支持的语言
+func TestAcceptLanguage(t *testing.T) {
+	saved := defaultLocale
+	defaultLocale = LocaleZhCN
+	defer func() { defaultLocale = saved }()
+
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", LocaleZhCN},
+		{"en-US", LocaleEnUS},
+		{"en-GB,en;q=0.9", LocaleEnUS},
+		{"zh-CN;q=0.5, en;q=0.9", LocaleEnUS},
+		{"fr, en;q=0.8, zh;q=0.9", LocaleZhCN},
+		{"en;q=0, zh-TW;q=0.3", LocaleZhCN},
+		{"en;q=0", LocaleZhCN},
+		{"fr-FR, de;q=0.9", LocaleZhCN},
+		{"en;q=0.5, zh;q=0.5", LocaleEnUS},
+		{"en;q=abc, zh;q=0.9", LocaleEnUS},
+	}
+	for _, tc := range cases {
+		if got := acceptLanguage(tc.header); got != tc.want {
+			t.Errorf("acceptLanguage(%q) = %s, want %s", tc.header, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// idempotencyPendingTTL 处理中的幂等键保留时间，进程异常退出时避免键被长期占用
+const idempotencyPendingTTL = 5 * time.Minute
+
+// IdempotencyRecord 幂等键记录
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	StatusCode  int // 0 表示请求仍在处理中
+	Response    []byte
+}
+
+// createIdempotencyTable 创建幂等键表
+func createIdempotencyTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		idem_key VARCHAR(255) NOT NULL PRIMARY KEY,
+		request_hash CHAR(64) NOT NULL,
+		status_code INT NOT NULL DEFAULT 0,
+		alert_ids TEXT,
+		response MEDIUMTEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		INDEX idx_expires_at (expires_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// ReserveIdempotencyKey 占用幂等键；键已存在时返回已有记录
+func ReserveIdempotencyKey(key, requestHash string) (*IdempotencyRecord, bool, error) {
+	now := time.Now()
+
+	// 顺带清理过期的幂等键
+	if _, err := db.Exec(`DELETE FROM idempotency_keys WHERE expires_at < ?`, now); err != nil {
+		LogDatabase("DELETE", "idempotency_keys", false, err.Error(), 0)
+	}
+
+	result, err := db.Exec(`INSERT IGNORE INTO idempotency_keys (idem_key, request_hash, expires_at) VALUES (?, ?, ?)`,
+		key, requestHash, now.Add(idempotencyPendingTTL))
+	if err != nil {
+		LogDatabase("INSERT", "idempotency_keys", false, err.Error(), 0)
+		return nil, false, fmt.Errorf("占用幂等键失败: %v", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 1 {
+		LogDatabase("INSERT", "idempotency_keys", true, "", 1)
+		return nil, true, nil
+	}
+
+	record := &IdempotencyRecord{Key: key}
+	var response sql.NullString
+	err = db.QueryRow(`SELECT request_hash, status_code, response FROM idempotency_keys WHERE idem_key = ?`, key).
+		Scan(&record.RequestHash, &record.StatusCode, &response)
+	if err != nil {
+		LogDatabase("SELECT", "idempotency_keys", false, err.Error(), 0)
+		return nil, false, fmt.Errorf("查询幂等键失败: %v", err)
+	}
+	record.Response = []byte(response.String)
+	return record, false, nil
+}
+
+// CompleteIdempotencyKey 保存幂等键对应的响应，在TTL内重放
+func CompleteIdempotencyKey(key string, statusCode int, alertIDs []int, response []byte) error {
+	ids, _ := json.Marshal(alertIDs)
+	ttl := time.Duration(config.Ingest.IdempotencyTTL) * time.Hour
+
+	_, err := db.Exec(`UPDATE idempotency_keys SET status_code = ?, alert_ids = ?, response = ?, expires_at = ? WHERE idem_key = ?`,
+		statusCode, string(ids), string(response), time.Now().Add(ttl), key)
+	if err != nil {
+		LogDatabase("UPDATE", "idempotency_keys", false, err.Error(), 0)
+		return fmt.Errorf("保存幂等响应失败: %v", err)
+	}
+	LogDatabase("UPDATE", "idempotency_keys", true, "", 1)
+	return nil
+}
+
+// ReleaseIdempotencyKey 释放未完成的幂等键，允许调用方重试
+func ReleaseIdempotencyKey(key string) {
+	if _, err := db.Exec(`DELETE FROM idempotency_keys WHERE idem_key = ? AND status_code = 0`, key); err != nil {
+		LogDatabase("DELETE", "idempotency_keys", false, err.Error(), 0)
+	}
+}
+
+// idempotentRequest 一次带幂等键的请求，key 为空时所有操作均为空操作
+type idempotentRequest struct {
+	key       string
+	completed bool
+}
+
+// beginIdempotentRequest 处理幂等键：重放或冲突时直接写入响应并返回 done=true
+func beginIdempotentRequest(c *gin.Context, scope, requestID string, payload interface{}) (*idempotentRequest, bool) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		key = requestID
+	}
+	if key == "" {
+		return &idempotentRequest{}, false
+	}
+	if len(key) > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.idempotency_key_too_long"),
+		})
+		return nil, true
+	}
+
+	body, _ := json.Marshal(payload)
+	sum := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(sum[:])
+	storageKey := scope + ":" + key
+
+	record, reserved, err := ReserveIdempotencyKey(storageKey, requestHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.idempotency_failed", err),
+		})
+		return nil, true
+	}
+	if reserved {
+		return &idempotentRequest{key: storageKey}, false
+	}
+
+	LogSystem(logrus.InfoLevel, "idempotency", "收到重复请求", map[string]interface{}{
+		"idempotency_key": storageKey,
+		"status_code":     record.StatusCode,
+		"client_ip":       c.ClientIP(),
+	})
+
+	switch {
+	case record.RequestHash != requestHash:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    422,
+			"message": apiMessage(c, "api.idempotency_mismatch"),
+		})
+	case record.StatusCode == 0:
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": apiMessage(c, "api.idempotency_in_progress"),
+		})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.StatusCode, "application/json; charset=utf-8", record.Response)
+	}
+	return nil, true
+}
+
+// respond 写入响应，并保存下来供重试时重放
+func (r *idempotentRequest) respond(c *gin.Context, statusCode int, alerts []Alert, body gin.H) {
+	if r.key == "" {
+		c.JSON(statusCode, body)
+		return
+	}
+
+	response, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(statusCode, body)
+		return
+	}
+
+	alertIDs := make([]int, 0, len(alerts))
+	for _, alert := range alerts {
+		alertIDs = append(alertIDs, alert.ID)
+	}
+	if err := CompleteIdempotencyKey(r.key, statusCode, alertIDs, response); err != nil {
+		LogSystem(logrus.WarnLevel, "idempotency", "保存幂等响应失败", map[string]interface{}{
+			"idempotency_key": r.key,
+			"error":           err.Error(),
+		})
+	} else {
+		r.completed = true
+	}
+
+	c.Data(statusCode, "application/json; charset=utf-8", response)
+}
+
+// release 请求未成功完成时释放幂等键
+func (r *idempotentRequest) release() {
+	if r.key != "" && !r.completed {
+		ReleaseIdempotencyKey(r.key)
+	}
+}
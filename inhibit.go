@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// InhibitRule 抑制规则：存在匹配 source_match 的未恢复告警时，
+// 抑制匹配 target_match 且 equal 字段取值相同的告警
+type InhibitRule struct {
+	Name        string     `json:"name"`
+	SourceMatch RouteMatch `json:"source_match"`
+	TargetMatch RouteMatch `json:"target_match"`
+	Equal       []string   `json:"equal"` // 需要取值相同的字段：source、domain、severity、recipient 或标签名
+}
+
+var (
+	inhibitMu    sync.RWMutex
+	inhibitRules []InhibitRule
+)
+
+// InitInhibitRules 加载抑制规则
+func InitInhibitRules() error {
+	rules, err := loadInhibitRulesFile(config.Routing.InhibitFile)
+	if err != nil {
+		return err
+	}
+
+	inhibitMu.Lock()
+	inhibitRules = rules
+	inhibitMu.Unlock()
+	return nil
+}
+
+// loadInhibitRulesFile 读取抑制规则文件，文件不存在时不启用抑制
+func loadInhibitRulesFile(path string) ([]InhibitRule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		LogSystem(logrus.InfoLevel, "inhibit", "未找到抑制规则文件，不启用告警抑制", map[string]interface{}{
+			"file": path,
+		})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取抑制规则文件失败: %v", err)
+	}
+
+	var rules []InhibitRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("解析抑制规则文件失败: %v", err)
+	}
+	for i := range rules {
+		if rules[i].Name == "" {
+			rules[i].Name = fmt.Sprintf("rule-%d", i+1)
+		}
+	}
+
+	LogSystem(logrus.InfoLevel, "inhibit", "抑制规则加载成功", map[string]interface{}{
+		"file":       path,
+		"rule_count": len(rules),
+	})
+	log.Printf("抑制规则加载成功，共 %d 条", len(rules))
+	return rules, nil
+}
+
+// currentInhibitRules 获取当前抑制规则
+func currentInhibitRules() []InhibitRule {
+	inhibitMu.RLock()
+	defer inhibitMu.RUnlock()
+	return inhibitRules
+}
+
+// equalFieldsMatch 判断两条告警在 equal 字段上取值是否相同
+func (r *InhibitRule) equalFieldsMatch(source, target *Alert) bool {
+	for _, field := range r.Equal {
+		if groupValue(source, field) != groupValue(target, field) {
+			return false
+		}
+	}
+	return true
+}
+
+// findInhibitingAlert 查找抑制目标告警的源告警。
+// 与 Alertmanager 一致，同时满足规则源条件的告警不会被该规则抑制，避免两条源告警互相抑制
+func findInhibitingAlert(rules []InhibitRule, alerts []*Alert, target *Alert) (*Alert, string) {
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.TargetMatch.Matches(target) || rule.SourceMatch.Matches(target) {
+			continue
+		}
+		for _, source := range alerts {
+			if source.ID == target.ID || source.Status == AlertStatusResolved {
+				continue
+			}
+			if rule.SourceMatch.Matches(source) && rule.equalFieldsMatch(source, target) {
+				return source, rule.Name
+			}
+		}
+	}
+	return nil, ""
+}
+
+// applyInhibition 在汇总邮件发送前应用抑制规则，被抑制的告警移入 Inhibited 并在数据库中标记
+func applyInhibition(userAlertsList []UserAlerts) []UserAlerts {
+	rules := currentInhibitRules()
+	if len(rules) == 0 {
+		return userAlertsList
+	}
+
+	// 时间段内的所有告警（包括已静默的）都可以作为源告警
+	var all []*Alert
+	for i := range userAlertsList {
+		for j := range userAlertsList[i].Alerts {
+			all = append(all, &userAlertsList[i].Alerts[j])
+		}
+	}
+
+	inhibitedBySource := make(map[int][]int)
+	inhibitedCount := 0
+	result := make([]UserAlerts, 0, len(userAlertsList))
+
+	for _, userAlerts := range userAlertsList {
+		grouped := UserAlerts{Recipient: userAlerts.Recipient, Inhibited: userAlerts.Inhibited}
+		for i := range userAlerts.Alerts {
+			alert := userAlerts.Alerts[i]
+			source, ruleName := findInhibitingAlert(rules, all, &alert)
+			if source == nil {
+				grouped.Alerts = append(grouped.Alerts, alert)
+				continue
+			}
+
+			alert.InhibitedBy = source.ID
+			grouped.Inhibited = append(grouped.Inhibited, alert)
+			inhibitedBySource[source.ID] = append(inhibitedBySource[source.ID], alert.ID)
+			inhibitedCount++
+
+			LogSystem(logrus.DebugLevel, "inhibit", "告警已被抑制", map[string]interface{}{
+				"alert_id":  alert.ID,
+				"source_id": source.ID,
+				"rule":      ruleName,
+			})
+		}
+		result = append(result, grouped)
+	}
+
+	for sourceID, alertIDs := range inhibitedBySource {
+		if err := MarkAlertsInhibited(sourceID, alertIDs); err != nil {
+			LogSystem(logrus.WarnLevel, "inhibit", "标记抑制告警失败", map[string]interface{}{
+				"source_id": sourceID,
+				"error":     err.Error(),
+			})
+		}
+	}
+
+	if inhibitedCount > 0 {
+		LogSystem(logrus.InfoLevel, "inhibit", "已抑制依赖告警", map[string]interface{}{
+			"inhibited_count": inhibitedCount,
+			"source_count":    len(inhibitedBySource),
+		})
+	}
+	return result
+}
+
+// GetInhibitedAlertsHandler 查询被抑制的告警
+func GetInhibitedAlertsHandler(c *gin.Context) {
+	var req PeriodRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-24 * time.Hour)
+	var err error
+	if req.StartTime != "" {
+		if startTime, err = parseFlexibleTime(req.StartTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_start_time"),
+			})
+			return
+		}
+	}
+	if req.EndTime != "" {
+		if endTime, err = parseFlexibleTime(req.EndTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_end_time"),
+			})
+			return
+		}
+	}
+
+	alerts, err := GetInhibitedAlerts(startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_inhibited_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":       200,
+		"message":    apiMessage(c, "api.get_inhibited_ok"),
+		"data":       alerts,
+		"start_time": formatAPITime(startTime),
+		"end_time":   formatAPITime(endTime),
+		"total":      len(alerts),
+		"rules":      currentInhibitRules(),
+	})
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// userResolver 收件人解析器：根据英文名查找用户信息
+type userResolver interface {
+	Name() string
+	Lookup(eName string) (UserInfo, bool)
+	Search(field, key string) []UserInfo // 按归一化后的英文名、姓名或邮箱前缀模糊查找
+}
+
+// localResolver 本地用户目录（users 表缓存）
+type localResolver struct{}
+
+// Name 解析器名称
+func (localResolver) Name() string { return "local" }
+
+// Lookup 在本地用户目录中查找
+func (localResolver) Lookup(eName string) (UserInfo, bool) {
+	directory.mu.RLock()
+	defer directory.mu.RUnlock()
+	user, ok := directory.byEName[eName]
+	return user, ok
+}
+
+// Search 在本地用户目录中模糊查找
+func (localResolver) Search(field, key string) []UserInfo {
+	directory.mu.RLock()
+	defer directory.mu.RUnlock()
+	return directory.index.lookup(field, key)
+}
+
+// ldapConn 同步用户时用到的 LDAP 连接操作，*ldap.Conn 实现该接口，测试中可替换为模拟目录
+type ldapConn interface {
+	Bind(username, password string) error
+	SearchWithPaging(request *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// ldapDialer 建立到 LDAP 服务器的连接（包括 StartTLS），不做绑定
+type ldapDialer func(cfg LDAPConfig) (ldapConn, error)
+
+// ldapResolver LDAP 用户目录，定期全量同步到内存缓存，同步失败时保留上一次的结果
+type ldapResolver struct {
+	cfg  LDAPConfig
+	dial ldapDialer
+
+	mu       sync.RWMutex
+	byEName  map[string]UserInfo
+	index    *identityIndex
+	lastSync time.Time
+	lastErr  string
+}
+
+// LDAPStatus LDAP 同步状态
+type LDAPStatus struct {
+	Enabled   bool   `json:"enabled"`
+	URL       string `json:"url,omitempty"`
+	BaseDN    string `json:"base_dn,omitempty"`
+	UserCount int    `json:"user_count"`
+	LastSync  string `json:"last_sync,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = []userResolver{localResolver{}}
+	ldapDir     *ldapResolver
+)
+
+// Name 解析器名称
+func (r *ldapResolver) Name() string { return "ldap" }
+
+// Lookup 在 LDAP 缓存中查找，未配置邮箱的条目视为未找到以便回退到本地目录
+func (r *ldapResolver) Lookup(eName string) (UserInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.byEName[strings.ToLower(eName)]
+	if !ok || user.Email == "" {
+		return UserInfo{}, false
+	}
+	return user, true
+}
+
+// Search 在 LDAP 缓存中模糊查找
+func (r *ldapResolver) Search(field, key string) []UserInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.index.lookup(field, key)
+}
+
+// dialLDAP 按配置连接 LDAP 服务器，需要时执行 StartTLS
+func dialLDAP(cfg LDAPConfig) (ldapConn, error) {
+	dialer := &net.Dialer{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second}
+	conn, err := ldap.DialURL(cfg.URL, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(&tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("连接LDAP服务器失败: %v", err)
+	}
+	conn.SetTimeout(time.Duration(cfg.TimeoutSeconds) * time.Second)
+
+	if cfg.StartTLS {
+		if err := conn.StartTLS(&tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS失败: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// newLDAPResolver 创建 LDAP 解析器，缓存为空直到首次同步成功
+func newLDAPResolver(cfg LDAPConfig, dial ldapDialer) *ldapResolver {
+	return &ldapResolver{cfg: cfg, dial: dial, byEName: map[string]UserInfo{}, index: newIdentityIndex(nil)}
+}
+
+// connect 连接并绑定 LDAP 服务器
+func (r *ldapResolver) connect() (ldapConn, error) {
+	conn, err := r.dial(r.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cfg.BindDN != "" {
+		if err := conn.Bind(r.cfg.BindDN, r.cfg.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP绑定失败: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// Sync 从 LDAP 全量同步用户到缓存
+func (r *ldapResolver) Sync() (int, error) {
+	start := time.Now()
+	users, err := r.fetchUsers()
+	if err != nil {
+		r.mu.Lock()
+		r.lastErr = err.Error()
+		r.mu.Unlock()
+		LogSystem(logrus.ErrorLevel, "ldap", "LDAP同步失败，继续使用缓存和本地用户目录", map[string]interface{}{
+			"url":   r.cfg.URL,
+			"error": err.Error(),
+		})
+		return 0, err
+	}
+
+	infos := make([]UserInfo, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, user)
+	}
+	index := newIdentityIndex(infos)
+
+	r.mu.Lock()
+	r.byEName = users
+	r.index = index
+	r.lastSync = time.Now()
+	r.lastErr = ""
+	r.mu.Unlock()
+
+	LogSystem(logrus.InfoLevel, "ldap", "LDAP同步完成", map[string]interface{}{
+		"url":        r.cfg.URL,
+		"user_count": len(users),
+		"duration":   time.Since(start).String(),
+	})
+	log.Printf("LDAP同步完成，共 %d 个用户", len(users))
+	return len(users), nil
+}
+
+// fetchUsers 分页查询 LDAP 用户并按属性映射为 UserInfo
+func (r *ldapResolver) fetchUsers() (map[string]UserInfo, error) {
+	conn, err := r.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request := ldap.NewSearchRequest(
+		r.cfg.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, r.cfg.TimeoutSeconds, false,
+		r.cfg.Filter,
+		[]string{r.cfg.ENameAttr, r.cfg.NameAttr, r.cfg.EmailAttr},
+		nil,
+	)
+	result, err := conn.SearchWithPaging(request, 500)
+	if err != nil {
+		return nil, fmt.Errorf("LDAP查询失败: %v", err)
+	}
+
+	users := make(map[string]UserInfo, len(result.Entries))
+	for _, entry := range result.Entries {
+		eName := strings.TrimSpace(entry.GetAttributeValue(r.cfg.ENameAttr))
+		if eName == "" {
+			continue
+		}
+		users[strings.ToLower(eName)] = UserInfo{
+			EName: eName,
+			Name:  strings.TrimSpace(entry.GetAttributeValue(r.cfg.NameAttr)),
+			Email: strings.TrimSpace(entry.GetAttributeValue(r.cfg.EmailAttr)),
+		}
+	}
+	return users, nil
+}
+
+// Status 获取同步状态
+func (r *ldapResolver) Status() LDAPStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	status := LDAPStatus{
+		Enabled:   true,
+		URL:       r.cfg.URL,
+		BaseDN:    r.cfg.BaseDN,
+		UserCount: len(r.byEName),
+		LastError: r.lastErr,
+	}
+	if !r.lastSync.IsZero() {
+		status.LastSync = formatAPITime(r.lastSync)
+	}
+	return status
+}
+
+// InitLDAP 启用 LDAP 时执行首次同步并启动定期同步任务。首次同步失败不影响启动，查找回退到本地用户目录
+func InitLDAP() error {
+	cfg := config.LDAP
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.URL == "" || cfg.BaseDN == "" {
+		return fmt.Errorf("启用LDAP时必须配置 LDAP_URL 和 LDAP_BASE_DN")
+	}
+	if cfg.TimeoutSeconds <= 0 {
+		cfg.TimeoutSeconds = 10
+	}
+
+	_, err := enableLDAP(cfg, dialLDAP)
+	return err
+}
+
+// enableLDAP 执行首次同步，把 LDAP 解析器放在本地用户目录之前，并按 SyncMinutes 启动定期同步
+func enableLDAP(cfg LDAPConfig, dial ldapDialer) (*ldapResolver, error) {
+	resolver := newLDAPResolver(cfg, dial)
+	resolver.Sync()
+
+	resolversMu.Lock()
+	ldapDir = resolver
+	resolvers = []userResolver{resolver, localResolver{}}
+	resolversMu.Unlock()
+
+	if cfg.SyncMinutes > 0 {
+		if _, err := startLDAPSync(resolver, time.Duration(cfg.SyncMinutes)*time.Minute); err != nil {
+			return nil, err
+		}
+		LogSystem(logrus.InfoLevel, "ldap", "LDAP定期同步已启动", map[string]interface{}{
+			"interval_minutes": cfg.SyncMinutes,
+		})
+	}
+	return resolver, nil
+}
+
+// startLDAPSync 按固定间隔定期同步 LDAP 用户，返回的 cron 用于停止同步
+func startLDAPSync(resolver *ldapResolver, interval time.Duration) (*cron.Cron, error) {
+	c := cron.New(cron.WithLocation(serverLocation))
+	if _, err := c.AddFunc("@every "+interval.String(), func() { resolver.Sync() }); err != nil {
+		return nil, fmt.Errorf("添加LDAP同步任务失败: %v", err)
+	}
+	c.Start()
+	return c, nil
+}
+
+// resolveUser 依次通过各解析器查找用户，返回用户信息和命中的解析器名称
+func resolveUser(eName string) (UserInfo, string, bool) {
+	resolversMu.RLock()
+	chain := resolvers
+	resolversMu.RUnlock()
+
+	for _, resolver := range chain {
+		if user, ok := resolver.Lookup(eName); ok {
+			return user, resolver.Name(), true
+		}
+	}
+	return UserInfo{}, "", false
+}
+
+// GetLDAPStatusHandler 查看 LDAP 同步状态
+func GetLDAPStatusHandler(c *gin.Context) {
+	status := LDAPStatus{}
+	if ldapDir != nil {
+		status = ldapDir.Status()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.ldap_status_ok"),
+		"data":    status,
+	})
+}
+
+// SyncLDAPHandler 立即从 LDAP 同步用户
+func SyncLDAPHandler(c *gin.Context) {
+	if ldapDir == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.ldap_disabled"),
+		})
+		return
+	}
+
+	count, err := ldapDir.Sync()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"code":    502,
+			"message": apiMessage(c, "api.ldap_sync_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.ldap_sync_ok"),
+		"data":    ldapDir.Status(),
+		"count":   count,
+	})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeDirectory 模拟 LDAP 服务器，记录绑定和查询请求
+type fakeDirectory struct {
+	mu        sync.Mutex
+	entries   []*ldap.Entry
+	bindErr   error
+	searchErr error
+	binds     []string
+	requests  []*ldap.SearchRequest
+	closed    int
+}
+
+func (f *fakeDirectory) dial(cfg LDAPConfig) (ldapConn, error) { return f, nil }
+
+func (f *fakeDirectory) Bind(username, password string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.binds = append(f.binds, username+":"+password)
+	return f.bindErr
+}
+
+func (f *fakeDirectory) SearchWithPaging(request *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request)
+	if f.searchErr != nil {
+		return nil, f.searchErr
+	}
+	return &ldap.SearchResult{Entries: f.entries}, nil
+}
+
+func (f *fakeDirectory) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed++
+	return nil
+}
+
+func (f *fakeDirectory) set(entries []*ldap.Entry, bindErr, searchErr error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries, f.bindErr, f.searchErr = entries, bindErr, searchErr
+}
+
+func (f *fakeDirectory) searchCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+// ldapEntry 按 uid、cn、mail 属性生成目录条目
+func ldapEntry(uid, cn, mail string) *ldap.Entry {
+	attrs := map[string][]string{"cn": {cn}, "mail": {mail}}
+	if uid != "" {
+		attrs["uid"] = []string{uid}
+	}
+	return ldap.NewEntry("uid="+uid+",ou=people,dc=example,dc=com", attrs)
+}
+
+func testLDAPConfig() LDAPConfig {
+	return LDAPConfig{
+		URL:            "ldap://fake",
+		BindDN:         "cn=reader,dc=example,dc=com",
+		BindPassword:   "secret",
+		BaseDN:         "ou=people,dc=example,dc=com",
+		Filter:         "(objectClass=inetOrgPerson)",
+		ENameAttr:      "uid",
+		NameAttr:       "cn",
+		EmailAttr:      "mail",
+		TimeoutSeconds: 5,
+	}
+}
+
+// quietLogger 测试中丢弃日志输出
+func quietLogger(t *testing.T) {
+	saved := Logger
+	Logger = logrus.New()
+	Logger.SetOutput(io.Discard)
+	t.Cleanup(func() { Logger = saved })
+}
+
+// TestLDAPAttributeMapping 按配置的属性映射用户，英文名不区分大小写，缺少英文名的条目被跳过
+func TestLDAPAttributeMapping(t *testing.T) {
+	quietLogger(t)
+	fake := &fakeDirectory{entries: []*ldap.Entry{
+		ldapEntry(" ZhangSan ", " 张三 ", "zhangsan@example.com"),
+		ldapEntry("lisi", "李四", ""),
+		ldapEntry("", "无名", "noname@example.com"),
+	}}
+	resolver := newLDAPResolver(testLDAPConfig(), fake.dial)
+
+	count, err := resolver.Sync()
+	if err != nil || count != 2 {
+		t.Fatalf("Sync = %d, %v, want 2, nil", count, err)
+	}
+	if len(fake.binds) != 1 || fake.binds[0] != "cn=reader,dc=example,dc=com:secret" {
+		t.Errorf("binds = %v", fake.binds)
+	}
+	request := fake.requests[0]
+	if request.BaseDN != "ou=people,dc=example,dc=com" || request.Filter != "(objectClass=inetOrgPerson)" {
+		t.Errorf("search base/filter = %q %q", request.BaseDN, request.Filter)
+	}
+	if want := []string{"uid", "cn", "mail"}; len(request.Attributes) != 3 || request.Attributes[0] != want[0] ||
+		request.Attributes[1] != want[1] || request.Attributes[2] != want[2] {
+		t.Errorf("search attributes = %v, want %v", request.Attributes, want)
+	}
+	if fake.closed != 1 {
+		t.Errorf("connection closed %d times, want 1", fake.closed)
+	}
+
+	user, ok := resolver.Lookup("zhangsan")
+	if !ok || user.EName != "ZhangSan" || user.Name != "张三" || user.Email != "zhangsan@example.com" {
+		t.Errorf("Lookup(zhangsan) = %+v, %v", user, ok)
+	}
+	if _, ok := resolver.Lookup("lisi"); ok {
+		t.Error("Lookup(lisi) found a user without email, want fallback")
+	}
+	if users := resolver.Search(identityFieldName, normalizeIdentity("张三")); len(users) != 1 {
+		t.Errorf("Search by name = %v", users)
+	}
+	if status := resolver.Status(); status.UserCount != 2 || status.LastSync == "" || status.LastError != "" {
+		t.Errorf("Status = %+v", status)
+	}
+}
+
+// TestLDAPSyncKeepsCache 同步失败时保留上一次的缓存，成功后整体替换
+func TestLDAPSyncKeepsCache(t *testing.T) {
+	quietLogger(t)
+	fake := &fakeDirectory{entries: []*ldap.Entry{ldapEntry("zhangsan", "张三", "zhangsan@example.com")}}
+	resolver := newLDAPResolver(testLDAPConfig(), fake.dial)
+	if _, err := resolver.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.set(nil, nil, errors.New("server busy"))
+	if _, err := resolver.Sync(); err == nil {
+		t.Fatal("Sync succeeded, want search error")
+	}
+	if _, ok := resolver.Lookup("zhangsan"); !ok {
+		t.Error("cached user lost after failed sync")
+	}
+	if status := resolver.Status(); status.UserCount != 1 || status.LastError == "" {
+		t.Errorf("Status after failure = %+v", status)
+	}
+
+	fake.set([]*ldap.Entry{ldapEntry("lisi", "李四", "lisi@example.com")}, nil, nil)
+	if _, err := resolver.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resolver.Lookup("zhangsan"); ok {
+		t.Error("user removed from LDAP still cached")
+	}
+	if _, ok := resolver.Lookup("lisi"); !ok {
+		t.Error("new LDAP user not cached")
+	}
+	if status := resolver.Status(); status.LastError != "" {
+		t.Errorf("LastError = %q after successful sync", status.LastError)
+	}
+}
+
+// TestLDAPPeriodicSync 定期同步会重新查询目录并更新缓存
+func TestLDAPPeriodicSync(t *testing.T) {
+	quietLogger(t)
+	fake := &fakeDirectory{}
+	resolver := newLDAPResolver(testLDAPConfig(), fake.dial)
+
+	c, err := startLDAPSync(resolver, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 等待正在执行的同步结束，再恢复日志等全局状态
+	defer func() { <-c.Stop().Done() }()
+	fake.set([]*ldap.Entry{ldapEntry("wangwu", "王五", "wangwu@example.com")}, nil, nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.searchCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("periodic sync ran %d times in 5s", fake.searchCount())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, ok := resolver.Lookup("wangwu"); !ok {
+		t.Error("periodic sync did not update the cache")
+	}
+}
+
+// TestLDAPFallbackToLocal 绑定或查询失败时收件人从本地用户目录解析
+func TestLDAPFallbackToLocal(t *testing.T) {
+	quietLogger(t)
+	savedResolvers, savedLDAP := resolvers, ldapDir
+	directory.mu.Lock()
+	savedByEName, savedIndex := directory.byEName, directory.index
+	local := UserInfo{EName: "zhangsan", Name: "张三", Email: "zhangsan@kugou.net"}
+	directory.byEName = map[string]UserInfo{"zhangsan": local}
+	directory.index = newIdentityIndex([]UserInfo{local})
+	directory.mu.Unlock()
+	t.Cleanup(func() {
+		resolversMu.Lock()
+		resolvers, ldapDir = savedResolvers, savedLDAP
+		resolversMu.Unlock()
+		directory.mu.Lock()
+		directory.byEName, directory.index = savedByEName, savedIndex
+		directory.mu.Unlock()
+	})
+
+	cases := []struct {
+		name      string
+		bindErr   error
+		searchErr error
+	}{
+		{"bind", errors.New("invalid credentials"), nil},
+		{"search", nil, errors.New("size limit exceeded")},
+	}
+	for _, tc := range cases {
+		fake := &fakeDirectory{entries: []*ldap.Entry{ldapEntry("zhangsan", "张三", "zhangsan@example.com")}}
+		fake.set(fake.entries, tc.bindErr, tc.searchErr)
+		resolver, err := enableLDAP(testLDAPConfig(), fake.dial)
+		if err != nil {
+			t.Fatalf("%s: enableLDAP: %v", tc.name, err)
+		}
+		if status := resolver.Status(); status.LastError == "" {
+			t.Errorf("%s: LastError empty after failed sync", tc.name)
+		}
+		if fake.closed != 1 {
+			t.Errorf("%s: connection closed %d times, want 1", tc.name, fake.closed)
+		}
+
+		user, source, ok := resolveUser("zhangsan")
+		if !ok || source != "local" || user.Email != "zhangsan@kugou.net" {
+			t.Errorf("%s: resolveUser = %+v, %s, %v, want local directory", tc.name, user, source, ok)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +18,11 @@ func main() {
 	// 加载配置
 	config = LoadConfig()
 
+	// 初始化服务器时区（数据库、定时任务、邮件统一使用）
+	if err := InitTimezone(config.Server.Timezone); err != nil {
+		log.Fatal("时区初始化失败:", err)
+	}
+
 	// 初始化日志系统
 	if err := InitLogger(config.Log); err != nil {
 		log.Fatal("日志系统初始化失败:", err)
@@ -25,6 +32,14 @@ func main() {
 		"version": "1.0.0",
 	})
 
+	// 加载消息目录（中英文），各语言缺少翻译时启动失败
+	if err := InitI18n(config.Server.Locale); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "消息目录加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("消息目录加载失败:", err)
+	}
+
 	// 初始化数据库连接
 	if err := InitDB(); err != nil {
 		LogSystem(logrus.FatalLevel, "main", "数据库初始化失败", map[string]interface{}{
@@ -39,6 +54,73 @@ func main() {
 	// 初始化邮件配置
 	InitEmailConfig()
 	LogSystem(logrus.InfoLevel, "main", "邮件配置初始化完成", nil)
+	if err := InitEmailTemplates(config.Email.TemplateDir); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "邮件模板加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("邮件模板加载失败:", err)
+	}
+
+	// 初始化用户目录（用户表为空时从用户列表文件导入）
+	if err := InitUserDirectory(); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "用户目录初始化失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("用户目录初始化失败:", err)
+	}
+	if err := InitAliases(); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "收件人别名加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("收件人别名加载失败:", err)
+	}
+	if err := InitUserPreferences(); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "用户通知偏好加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("用户通知偏好加载失败:", err)
+	}
+	if err := InitLDAP(); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "LDAP初始化失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("LDAP初始化失败:", err)
+	}
+	if err := InitFallback(config.Fallback); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "兜底收件人配置加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("兜底收件人配置加载失败:", err)
+	}
+
+	// 初始化告警写入限流与风暴检测
+	InitIngestProtection()
+
+	// 加载路由规则
+	if err := InitRouting(); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "路由规则加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("路由规则加载失败:", err)
+	}
+	if err := InitInhibitRules(); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "抑制规则加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("抑制规则加载失败:", err)
+	}
+	if err := InitTeams(); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "团队配置加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("团队配置加载失败:", err)
+	}
+	if err := InitEscalationPolicies(); err != nil {
+		LogSystem(logrus.FatalLevel, "main", "升级策略加载失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("升级策略加载失败:", err)
+	}
 
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
@@ -55,6 +137,12 @@ func main() {
 	// 启动定时任务
 	startCronJob()
 
+	// 启动告警升级检查任务
+	startEscalationScheduler()
+
+	// 监听 SIGHUP 和配置文件变化，自动重新加载配置
+	startReloadWatchers()
+
 	// 启动HTTP服务器
 	serverAddr := fmt.Sprintf("%s:%s", config.Server.Host, config.Server.Port)
 	LogSystem(logrus.InfoLevel, "main", "服务器启动", map[string]interface{}{
@@ -72,11 +160,14 @@ func main() {
 func setupRoutes(r *gin.Engine) {
 	// 健康检查接口
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok", "message": "服务正常运行"})
+		c.JSON(200, gin.H{"status": "ok", "message": apiMessage(c, "api.health_ok")})
 	})
 
 	// 配置检查接口
 	r.GET("/config", func(c *gin.Context) {
+		emailConfig := currentEmailConfig()
+		cronCfg := currentCronConfig()
+		reportCfg := currentReportConfig()
 		c.JSON(200, gin.H{
 			"status": "ok",
 			"email_config": gin.H{
@@ -86,239 +177,296 @@ func setupRoutes(r *gin.Engine) {
 				"from":           emailConfig.From,
 				"debug_mode":     emailConfig.DebugMode,
 				"debug_api_url":  emailConfig.DebugAPIUrl,
+				"send_workers":   emailConfig.SendWorkers,
+				"gateway_rate_per_minute": emailConfig.GatewayRatePerMinute,
+				"request_timeout": emailConfig.RequestTimeout,
+				"send_deadline":  emailConfig.SendDeadline,
+				"attachment_format": emailConfig.AttachmentFormat,
+				"attachment_min_alerts": emailConfig.AttachmentMinAlerts,
+				"attachment_max_kb": emailConfig.AttachmentMaxKB,
+				"link_base_url": emailConfig.LinkBaseURL,
 				"note":           "收件人现在根据告警信息动态生成",
 			},
 			"cron_config": gin.H{
-				"enabled":      config.Cron.Enabled,
-				"schedule":     config.Cron.Schedule,
-				"start_time":   fmt.Sprintf("%02d:%02d", config.Cron.StartHour, config.Cron.StartMinute),
-				"end_time":     fmt.Sprintf("%02d:%02d", config.Cron.EndHour, config.Cron.EndMinute),
+				"enabled":      cronCfg.Enabled,
+				"schedule":     cronCfg.Schedule,
+				"start_time":   fmt.Sprintf("%02d:%02d", cronCfg.StartHour, cronCfg.StartMinute),
+				"end_time":     fmt.Sprintf("%02d:%02d", cronCfg.EndHour, cronCfg.EndMinute),
+				"timezone":     serverLocation.String(),
 				"description":  "定时任务配置信息",
 			},
-		})
-	})
-
-	// 邮件测试接口
-	r.POST("/test-email", func(c *gin.Context) {
-		// 显示当前邮件配置信息
-		log.Printf("邮件配置信息:")
-		log.Printf("  API地址: %s", emailConfig.APIUrl)
-		log.Printf("  App ID: %s", emailConfig.AppID)
-		log.Printf("  调试模式: %v", emailConfig.DebugMode)
-		if emailConfig.DebugMode {
-			log.Printf("  调试API地址: %s", emailConfig.DebugAPIUrl)
-		}
-		log.Printf("  收件人: 根据告警信息动态生成")
-
-		// 创建测试预警数据（按用户分组），包含多个用户
-		testUserAlerts := []UserAlerts{
-			{
-				Recipient: "felixgao",
-				Alerts: []Alert{
-					{
-						ID:        1,
-						Message:   "检测到域名【search.suggest.kgidc.cn】北方已切量，但南方超过24小时未切量，请检查",
-						Recipient: "felixgao",
-						AlertTime: time.Now().Add(-30 * time.Minute),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-					},
-					{
-						ID:        2,
-						Message:   "检测到域名【api.example.com】服务响应时间超过阈值，当前响应时间2.5秒",
-						Recipient: "felixgao",
-						AlertTime: time.Now().Add(-15 * time.Minute),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-					},
-				},
-			},
-			{
-				Recipient: "hugoli",
-				Alerts: []Alert{
-					{
-						ID:        3,
-						Message:   "检测到域名【cdn.kugou.com】CDN节点异常，影响用户访问",
-						Recipient: "hugoli",
-						AlertTime: time.Now().Add(-20 * time.Minute),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-					},
-					{
-						ID:        4,
-						Message:   "检测到数据库连接池使用率超过80%，请检查数据库性能",
-						Recipient: "hugoli",
-						AlertTime: time.Now().Add(-10 * time.Minute),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-					},
-				},
-			},
-			{
-				Recipient: "zhangsan",
-				Alerts: []Alert{
-					{
-						ID:        5,
-						Message:   "检测到服务器CPU使用率超过90%，请检查系统负载",
-						Recipient: "zhangsan",
-						AlertTime: time.Now().Add(-25 * time.Minute),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-					},
-				},
-			},
-			{
-				Recipient: "lisi",
-				Alerts: []Alert{
-					{
-						ID:        6,
-						Message:   "检测到内存使用率超过85%，请检查内存泄漏",
-						Recipient: "lisi",
-						AlertTime: time.Now().Add(-5 * time.Minute),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-					},
-					{
-						ID:        7,
-						Message:   "检测到磁盘空间不足，剩余空间小于10%",
-						Recipient: "lisi",
-						AlertTime: time.Now().Add(-2 * time.Minute),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-					},
-				},
-			},
-		}
-
-		// 发送测试邮件
-		if err := SendAlertEmail(testUserAlerts); err != nil {
-			log.Printf("邮件发送失败: %v", err)
-			c.JSON(500, gin.H{
-				"code":    500,
-				"message": "邮件发送失败: " + err.Error(),
-			})
-			return
-		}
-
-		// 计算总告警数量
-		totalAlerts := 0
-		for _, userAlerts := range testUserAlerts {
-			totalAlerts += len(userAlerts.Alerts)
-		}
-
-		log.Printf("测试邮件发送成功，共发送给 %d 个用户，总计 %d 条告警信息", len(testUserAlerts), totalAlerts)
-		c.JSON(200, gin.H{
-			"code":    200,
-			"message": "测试邮件发送成功",
-			"data": gin.H{
-				"user_count":   len(testUserAlerts),
-				"total_alerts": totalAlerts,
-				"recipients":   []string{"felixgao", "hugoli", "zhangsan", "lisi"},
-				"details": []gin.H{
-					{"user": "felixgao", "alert_count": 2},
-					{"user": "hugoli", "alert_count": 2},
-					{"user": "zhangsan", "alert_count": 1},
-					{"user": "lisi", "alert_count": 2},
-				},
+			"report_config": gin.H{
+				"enabled":          reportCfg.Enabled,
+				"weekly_schedule":  reportCfg.WeeklySchedule,
+				"monthly_schedule": reportCfg.MonthlySchedule,
+				"recipients":       reportRecipients(reportCfg),
+				"top_n":            reportCfg.TopN,
 			},
 		})
 	})
 
+	// 测试通知接口，支持自定义收件人、告警内容、渠道和 dry_run
+	r.POST("/test-email", TestEmailHandler)
+
 	// API路由组
 	api := r.Group("/api/v1")
 	{
 		// 存储预警信息
 		api.POST("/alerts", CreateAlert)
 		
+		// 批量存储预警信息
+		api.POST("/alerts/batch", CreateAlertsBatch)
+		
 		// 获取预警信息
 		api.GET("/alerts", GetAlertsHandler)
 		
+		// 导出预警信息（csv / jsonl / xlsx），过滤条件与列表接口相同
+		api.GET("/alerts/export", ExportAlertsHandler)
+		
+		// 按收件人、来源、域名、级别或时间粒度分组统计预警数量
+		api.GET("/alerts/stats", GetAlertStatsHandler)
+		
 		// 获取指定时间段的预警信息
 		api.GET("/alerts/period", GetAlertsByPeriod)
 		
 		// 根据收件人获取预警信息
 		api.GET("/alerts/recipient", GetAlertsByRecipientHandler)
+		
+		// 获取被抑制的预警信息
+		api.GET("/alerts/inhibited", GetInhibitedAlertsHandler)
+		
+		// 单条告警查询、确认、恢复与时间线
+		api.GET("/alerts/:id", GetAlertHandler)
+		api.POST("/alerts/:id/ack", AcknowledgeAlertHandler)
+		api.POST("/alerts/:id/resolve", ResolveAlertHandler)
+		api.GET("/alerts/:id/timeline", GetAlertTimelineHandler)
+		
+		// 路由规则
+		api.GET("/routing", GetRoutingHandler)
+		api.POST("/routing/test", TestRoutingHandler)
+		
+		// 升级策略
+		api.GET("/escalation-policies", GetEscalationPoliciesHandler)
+		
+		// 用户目录
+		api.GET("/users", GetUsersHandler)
+		api.POST("/users", CreateUserHandler)
+		api.POST("/users/import", ImportUsersHandler)
+		api.GET("/users/export", ExportUsersHandler)
+		api.GET("/users/missing-contact", GetMissingContactUsersHandler)
+		api.GET("/users/:ename", GetUserHandler)
+		api.PUT("/users/:ename", UpdateUserHandler)
+		api.DELETE("/users/:ename", DeleteUserHandler)
+		api.GET("/users/:ename/preferences", GetUserPreferencesHandler)
+		api.PUT("/users/:ename/preferences", UpdateUserPreferencesHandler)
+		
+		// 收件人别名与解析预览
+		api.GET("/aliases", GetUserAliasesHandler)
+		api.POST("/aliases", CreateUserAliasHandler)
+		api.DELETE("/aliases/:alias", DeleteUserAliasHandler)
+		api.GET("/recipients/resolve", ResolveRecipientsHandler)
+		
+		// LDAP用户目录同步
+		api.GET("/ldap/status", GetLDAPStatusHandler)
+		api.POST("/ldap/sync", SyncLDAPHandler)
+		
+		// 未解析收件人任务
+		api.GET("/unresolved-recipients", GetUnresolvedRecipientsHandler)
+		api.POST("/unresolved-recipients/:id/resolve", ResolveUnresolvedRecipientHandler)
+
+		// 定时任务执行历史，包含每个收件人的发送结果
+		api.GET("/cron/runs", GetCronRunsHandler)
+		api.GET("/cron/runs/:id", GetCronRunHandler)
+		
+		// 周报/月报：预览内容、立即发送
+		api.GET("/reports/:period", GetAlertReportHandler)
+		api.POST("/reports/:period/send", SendAlertReportHandler)
+		
+		// 管理接口
+		api.POST("/admin/reload", ReloadHandler)
+		api.GET("/templates", GetEmailTemplatesHandler)
+		
+		// 通知预览（只渲染不发送）
+		api.POST("/notifications/preview", PreviewNotificationHandler)
+		api.GET("/notifications/preview.html", PreviewNotificationHTMLHandler)
+
+		// 汇总邮件告警列表下载（附件过大时邮件中的链接）
+		api.GET("/digests/attachment", DigestAttachmentHandler)
+		
+		// 团队与值班
+		api.GET("/teams", GetTeamsHandler)
+		api.GET("/oncall", GetOnCallHandler)
+		api.GET("/teams/:name/oncall.ics", ExportOnCallICSHandler)
+		
+		// 静默规则（维护窗口）管理
+		api.POST("/silences", CreateSilenceHandler)
+		api.GET("/silences", GetSilencesHandler)
+		api.GET("/silences/:id", GetSilenceHandler)
+		api.PUT("/silences/:id", UpdateSilenceHandler)
+		api.DELETE("/silences/:id", ExpireSilenceHandler)
 	}
 }
 
+// 汇总邮件定时任务，配置重新加载时重新注册
+var (
+	cronMu        sync.Mutex
+	digestCron    *cron.Cron
+	digestEntryID cron.EntryID
+	activeCron    CronConfig
+)
+
 func startCronJob() {
+	digestCron = cron.New(cron.WithLocation(serverLocation))
+	digestCron.Start()
+
+	if err := registerDigestJob(config.Cron); err != nil {
+		LogSystem(logrus.FatalLevel, "cron", "添加定时任务失败", map[string]interface{}{
+			"error": err.Error(),
+			"schedule": config.Cron.Schedule,
+		})
+		log.Fatal("添加定时任务失败:", err)
+	}
+
+	if err := registerReportJobs(config.Report); err != nil {
+		LogSystem(logrus.FatalLevel, "cron", "添加周报/月报任务失败", map[string]interface{}{
+			"error": err.Error(),
+			"weekly_schedule": config.Report.WeeklySchedule,
+			"monthly_schedule": config.Report.MonthlySchedule,
+		})
+		log.Fatal("添加周报/月报任务失败:", err)
+	}
+}
+
+// registerDigestJob 按配置（重新）注册汇总邮件定时任务，新的cron表达式无效时保留原任务
+func registerDigestJob(cronCfg CronConfig) error {
+	if cronCfg.Enabled {
+		if _, err := cron.ParseStandard(cronCfg.Schedule); err != nil {
+			return fmt.Errorf("cron表达式 %q 无效: %v", cronCfg.Schedule, err)
+		}
+	}
+
+	cronMu.Lock()
+	defer cronMu.Unlock()
+
+	if digestEntryID != 0 {
+		digestCron.Remove(digestEntryID)
+		digestEntryID = 0
+	}
+	activeCron = cronCfg
+
 	// 检查是否启用定时任务
-	if !config.Cron.Enabled {
+	if !cronCfg.Enabled {
 		LogSystem(logrus.InfoLevel, "cron", "定时任务已禁用", nil)
 		log.Println("定时任务已禁用")
-		return
+		return nil
 	}
 
-	c := cron.New(cron.WithLocation(time.Local))
-	
 	// 使用配置的cron表达式执行定时任务
-	_, err := c.AddFunc(config.Cron.Schedule, func() {
-		startTime := time.Now()
-		LogCronJob("alert_notification", true, "定时任务开始执行", "")
-		
-		// 使用配置的时间范围获取预警信息
-		now := time.Now()
-		alertStartTime := time.Date(now.Year(), now.Month(), now.Day(), 
-			config.Cron.StartHour, config.Cron.StartMinute, 0, 0, now.Location())
-		alertEndTime := time.Date(now.Year(), now.Month(), now.Day(), 
-			config.Cron.EndHour, config.Cron.EndMinute, 0, 0, now.Location())
-		
-		LogSystem(logrus.InfoLevel, "cron", "查询告警时间范围", map[string]interface{}{
-			"start_time": alertStartTime.Format("2006-01-02 15:04:05"),
-			"end_time": alertEndTime.Format("2006-01-02 15:04:05"),
-			"schedule": config.Cron.Schedule,
-		})
-		
-		// 按收件人分组获取告警信息
-		userAlertsList, err := GetAlertsGroupedByRecipient(alertStartTime, alertEndTime)
-		if err != nil {
-			duration := time.Since(startTime).String()
-			LogCronJob("alert_notification", false, "获取预警信息失败: "+err.Error(), duration)
-			log.Printf("获取预警信息失败: %v", err)
-			return
-		}
-		
-		if len(userAlertsList) == 0 {
-			duration := time.Since(startTime).String()
-			LogCronJob("alert_notification", true, "指定时间段内没有预警信息", duration)
-			log.Println("指定时间段内没有预警信息")
-			return
-		}
-		
-		LogSystem(logrus.InfoLevel, "cron", "准备发送邮件", map[string]interface{}{
-			"user_count": len(userAlertsList),
-		})
-		
-		// 按用户分组发送邮件
-		if err := SendAlertEmail(userAlertsList); err != nil {
-			duration := time.Since(startTime).String()
-			LogCronJob("alert_notification", false, "发送邮件失败: "+err.Error(), duration)
-			log.Printf("发送邮件失败: %v", err)
-		} else {
-			duration := time.Since(startTime).String()
-			LogCronJob("alert_notification", true, fmt.Sprintf("成功发送预警通知邮件，涉及 %d 个用户", len(userAlertsList)), duration)
-			log.Printf("成功发送预警通知邮件，涉及 %d 个用户", len(userAlertsList))
-		}
+	id, err := digestCron.AddFunc(cronCfg.Schedule, func() {
+		runDigestJob(cronCfg)
 	})
-	
 	if err != nil {
-		LogSystem(logrus.FatalLevel, "cron", "添加定时任务失败", map[string]interface{}{
-			"error": err.Error(),
-			"schedule": config.Cron.Schedule,
-		})
-		log.Fatal("添加定时任务失败:", err)
+		return err
 	}
-	
-	c.Start()
+	digestEntryID = id
+
 	LogSystem(logrus.InfoLevel, "cron", "定时任务已启动", map[string]interface{}{
-		"schedule": config.Cron.Schedule,
-		"start_time": fmt.Sprintf("%02d:%02d", config.Cron.StartHour, config.Cron.StartMinute),
-		"end_time": fmt.Sprintf("%02d:%02d", config.Cron.EndHour, config.Cron.EndMinute),
+		"schedule": cronCfg.Schedule,
+		"start_time": fmt.Sprintf("%02d:%02d", cronCfg.StartHour, cronCfg.StartMinute),
+		"end_time": fmt.Sprintf("%02d:%02d", cronCfg.EndHour, cronCfg.EndMinute),
 	})
 	log.Printf("定时任务已启动，执行时间: %s，查询范围: %02d:%02d - %02d:%02d", 
-		config.Cron.Schedule, 
-		config.Cron.StartHour, config.Cron.StartMinute,
-		config.Cron.EndHour, config.Cron.EndMinute)
+		cronCfg.Schedule, 
+		cronCfg.StartHour, cronCfg.StartMinute,
+		cronCfg.EndHour, cronCfg.EndMinute)
+	return nil
+}
+
+// currentCronConfig 获取当前生效的定时任务配置
+func currentCronConfig() CronConfig {
+	cronMu.Lock()
+	defer cronMu.Unlock()
+	return activeCron
+}
+
+// runDigestJob 汇总邮件定时任务：查询时间范围内的告警并按用户发送邮件
+func runDigestJob(cronCfg CronConfig) {
+	run := startCronRun(cronJobAlertNotification)
+	LogCronJob(cronJobAlertNotification, true, "定时任务开始执行", "")
+	
+	// 使用配置的时间范围获取预警信息
+	now := time.Now().In(serverLocation)
+	alertStartTime := time.Date(now.Year(), now.Month(), now.Day(), 
+		cronCfg.StartHour, cronCfg.StartMinute, 0, 0, serverLocation)
+	alertEndTime := time.Date(now.Year(), now.Month(), now.Day(), 
+		cronCfg.EndHour, cronCfg.EndMinute, 0, 0, serverLocation)
+	
+	LogSystem(logrus.InfoLevel, "cron", "查询告警时间范围", map[string]interface{}{
+		"start_time": alertStartTime.Format("2006-01-02 15:04:05"),
+		"end_time": alertEndTime.Format("2006-01-02 15:04:05"),
+		"schedule": cronCfg.Schedule,
+		"timezone": serverLocation.String(),
+	})
+	
+	// 按收件人分组获取告警信息
+	userAlertsList, err := GetAlertsGroupedByRecipient(alertStartTime, alertEndTime)
+	if err != nil {
+		run.finish(false, "获取预警信息失败: "+err.Error(), nil)
+		log.Printf("获取预警信息失败: %v", err)
+		return
+	}
+	
+	// 团队收件人先展开为具体人员，后续的汇总频率、免打扰和渠道按每个人员自己的偏好处理
+	userAlertsList = expandTeamRecipients(userAlertsList)
+	
+	// 按用户偏好的汇总频率过滤：off 只补发立即通知未成功的告警，weekly 每周一发送最近7天
+	userAlertsList, err = applyDigestFrequency(userAlertsList, alertStartTime, alertEndTime)
+	if err != nil {
+		run.finish(false, "获取每周汇总预警信息失败: "+err.Error(), nil)
+		log.Printf("获取每周汇总预警信息失败: %v", err)
+		return
+	}
+	
+	if len(userAlertsList) == 0 {
+		run.finish(true, "指定时间段内没有预警信息", nil)
+		log.Println("指定时间段内没有预警信息")
+		return
+	}
+	
+	// 应用抑制规则：根因告警存在时抑制依赖告警，在邮件中折叠展示
+	userAlertsList = applyInhibition(userAlertsList)
+	
+	// 过滤被静默的告警（告警仍保留在数据库中）
+	userAlertsList, silencedCount := filterSilencedAlerts(userAlertsList)
+	if len(userAlertsList) == 0 {
+		run.finish(true, fmt.Sprintf("指定时间段内的 %d 条预警信息均已静默", silencedCount), nil)
+		log.Printf("指定时间段内的 %d 条预警信息均已静默", silencedCount)
+		return
+	}
+	
+	// 应用路由规则：跳过已立即发送的告警，按路由分组拆分邮件
+	userAlertsList = applyDigestRouting(userAlertsList)
+	if len(userAlertsList) == 0 {
+		run.finish(true, "指定时间段内的预警信息均已立即发送", nil)
+		log.Println("指定时间段内的预警信息均已立即发送")
+		return
+	}
+	
+	LogSystem(logrus.InfoLevel, "cron", "准备发送邮件", map[string]interface{}{
+		"user_count": len(userAlertsList),
+		"silenced_count": silencedCount,
+	})
+	
+	// 按用户分组发送邮件，按用户偏好推送IM
+	// 每个收件人的发送结果保存在执行记录中
+	result, err := dispatchNotifications(context.Background(), userAlertsList)
+	if err != nil {
+		run.finish(false, "发送邮件失败: "+err.Error(), result)
+		log.Printf("发送邮件失败: %v", err)
+	} else {
+		run.finish(true, fmt.Sprintf("成功发送预警通知邮件，涉及 %d 个用户", len(userAlertsList)), result)
+		log.Printf("成功发送预警通知邮件，涉及 %d 个用户", len(userAlertsList))
+	}
 }
 
 // LoggerMiddleware Gin日志中间件
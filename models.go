@@ -4,11 +4,35 @@ import (
 	"time"
 )
 
+// 告警状态
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved"
+)
+
 // Alert 告警信息结构
 type Alert struct {
 	ID          int       `json:"id" db:"id"`
 	Message     string    `json:"message" db:"message"`
 	Recipient   string    `json:"recipient" db:"recipient"`
+	Source      string    `json:"source" db:"source"`
+	Domain      string    `json:"domain" db:"domain"`
+	Severity    string    `json:"severity" db:"severity"`
+	Labels      map[string]string `json:"labels,omitempty" db:"labels"`
+	Route       string    `json:"route" db:"route"`
+	SilenceID   int       `json:"silence_id,omitempty" db:"silence_id"`
+	Silenced    bool      `json:"silenced"`
+	InhibitedBy int       `json:"inhibited_by,omitempty" db:"inhibited_by"` // 抑制该告警的源告警ID
+	Status      string    `json:"status" db:"status"`                       // open / acknowledged / resolved
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty" db:"acknowledged_at"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty" db:"acknowledged_by"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+	ResolvedBy     string     `json:"resolved_by,omitempty" db:"resolved_by"`
+	EscalationPolicy string     `json:"escalation_policy,omitempty" db:"escalation_policy"`
+	EscalationStep   int        `json:"escalation_step,omitempty" db:"escalation_step"`     // 下一个待执行的升级步骤
+	EscalationNextAt *time.Time `json:"escalation_next_at,omitempty" db:"escalation_next_at"` // 为空表示升级已结束
+	NotifiedAt       *time.Time `json:"notified_at,omitempty" db:"notified_at"`               // 立即通知成功的时间，为空时由汇总邮件补发
 	AlertTime   time.Time `json:"alert_time" db:"alert_time"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
@@ -17,8 +41,19 @@ type Alert struct {
 // CreateAlertRequest 创建告警请求结构
 type CreateAlertRequest struct {
 	Message   string `json:"message" binding:"required"`
-	Recipient string `json:"recipient" binding:"required"` // 支持逗号分隔的多个收件人
+	Recipient string `json:"recipient"` // 支持逗号分隔的多个收件人，为空时由路由规则决定
+	Source    string `json:"source"`                        // 告警来源，用于限流和风暴检测
+	Domain    string `json:"domain"`                        // 域名，未填写时从消息中的【】提取
+	Severity  string `json:"severity" binding:"omitempty,oneof=info warning critical"` // 告警级别，默认 warning
+	Labels    map[string]string `json:"labels"`                 // 自定义标签，如 region、idc，用于路由匹配
 	AlertTime string `json:"alert_time"`
+	RequestID string `json:"request_id"` // 幂等键，也可通过请求头 Idempotency-Key 传递
+}
+
+// BatchCreateAlertRequest 批量创建告警请求结构
+type BatchCreateAlertRequest struct {
+	Alerts    []CreateAlertRequest `json:"alerts" binding:"required,min=1,dive"`
+	RequestID string               `json:"request_id"`
 }
 
 // AlertResponse 告警响应结构
@@ -38,4 +73,27 @@ type PeriodRequest struct {
 type UserAlerts struct {
 	Recipient string  `json:"recipient"`
 	Alerts    []Alert `json:"alerts"`
-}
\ No newline at end of file
+	Inhibited []Alert `json:"inhibited,omitempty"` // 被抑制规则屏蔽的告警，在邮件中折叠展示
+}
+
+// AlertActionRequest 确认/恢复告警请求
+type AlertActionRequest struct {
+	By      string `json:"by"`      // 操作人
+	Comment string `json:"comment"` // 备注
+}
+
+// AlertEvent 告警时间线事件
+type AlertEvent struct {
+	ID        int       `json:"id" db:"id"`
+	AlertID   int       `json:"alert_id" db:"alert_id"`
+	Event     string    `json:"event" db:"event"`
+	Actor     string    `json:"actor,omitempty" db:"actor"`
+	Detail    string    `json:"detail,omitempty" db:"detail"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AlertStatsBucket 告警统计的一个分组，Key 为分组维度到取值的映射
+type AlertStatsBucket struct {
+	Key   map[string]string `json:"key"`
+	Count int               `json:"count"`
+}
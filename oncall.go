@@ -0,0 +1,539 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 团队收件人前缀，如 team:cdn、oncall:cdn、lead:cdn
+const (
+	RecipientPrefixTeam   = "team:"   // 团队全部成员
+	RecipientPrefixOnCall = "oncall:" // 团队当前值班人
+	RecipientPrefixLead   = "lead:"   // 团队负责人
+)
+
+// 轮值类型
+const (
+	RotationDaily  = "daily"
+	RotationWeekly = "weekly"
+)
+
+// Rotation 值班轮换：从 start 开始每天或每周交接一次，按 participants 顺序轮换
+type Rotation struct {
+	Type         string   `json:"type"`                   // daily / weekly
+	Start        string   `json:"start"`                  // 第一次交接时间，同时决定每次交接的时刻
+	Timezone     string   `json:"timezone,omitempty"`     // 交接时间所在时区，默认服务器时区
+	Participants []string `json:"participants,omitempty"` // 轮换人员，默认为团队成员
+	OnCallCount  int      `json:"oncall_count,omitempty"` // 每班值班人数，默认 1
+
+	loc   *time.Location
+	start time.Time
+}
+
+// OnCallOverride 临时替班：时间段内由 users 代替轮换值班人
+type OnCallOverride struct {
+	Users   []string `json:"users"`
+	Start   string   `json:"start"`
+	End     string   `json:"end"`
+	Comment string   `json:"comment,omitempty"`
+
+	start time.Time
+	end   time.Time
+}
+
+// Team 团队
+type Team struct {
+	Name      string           `json:"name"`
+	Lead      string           `json:"lead,omitempty"`
+	Members   []string         `json:"members"`
+	Rotation  *Rotation        `json:"rotation,omitempty"`
+	Overrides []OnCallOverride `json:"overrides,omitempty"`
+}
+
+// OnCallUser 值班人员信息
+type OnCallUser struct {
+	EName string `json:"e_name"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// OnCallShift 一个值班班次
+type OnCallShift struct {
+	Team     string       `json:"team"`
+	Users    []OnCallUser `json:"users"`
+	Start    string       `json:"start"`
+	End      string       `json:"end"`
+	Override bool         `json:"override"`
+	Comment  string       `json:"comment,omitempty"`
+}
+
+var (
+	teamsMu sync.RWMutex
+	teams   map[string]*Team
+)
+
+// InitTeams 加载团队与值班配置
+func InitTeams() error {
+	loaded, err := loadTeamsFile(config.Routing.TeamsFile)
+	if err != nil {
+		return err
+	}
+
+	teamsMu.Lock()
+	teams = loaded
+	teamsMu.Unlock()
+	return nil
+}
+
+// loadTeamsFile 读取并校验团队配置文件，文件不存在时不启用团队收件人
+func loadTeamsFile(path string) (map[string]*Team, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		LogSystem(logrus.InfoLevel, "oncall", "未找到团队配置文件，不启用团队收件人", map[string]interface{}{
+			"file": path,
+		})
+		return map[string]*Team{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取团队配置文件失败: %v", err)
+	}
+
+	var list []*Team
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("解析团队配置文件失败: %v", err)
+	}
+
+	loaded := make(map[string]*Team, len(list))
+	for i, team := range list {
+		if team.Name == "" {
+			return nil, fmt.Errorf("第 %d 个团队缺少 name", i+1)
+		}
+		if _, exists := loaded[team.Name]; exists {
+			return nil, fmt.Errorf("团队名称重复: %s", team.Name)
+		}
+		if err := team.prepare(); err != nil {
+			return nil, fmt.Errorf("团队 %s 配置错误: %v", team.Name, err)
+		}
+		loaded[team.Name] = team
+	}
+
+	LogSystem(logrus.InfoLevel, "oncall", "团队配置加载成功", map[string]interface{}{
+		"file":       path,
+		"team_count": len(loaded),
+	})
+	log.Printf("团队配置加载成功，共 %d 个团队", len(loaded))
+	return loaded, nil
+}
+
+// prepare 校验团队配置并解析时间
+func (t *Team) prepare() error {
+	if r := t.Rotation; r != nil {
+		if r.Type != RotationDaily && r.Type != RotationWeekly {
+			return fmt.Errorf("轮换类型必须为 daily 或 weekly")
+		}
+		r.loc = serverLocation
+		if r.Timezone != "" {
+			loc, err := time.LoadLocation(r.Timezone)
+			if err != nil {
+				return fmt.Errorf("加载时区 %s 失败: %v", r.Timezone, err)
+			}
+			r.loc = loc
+		}
+		start, err := parseTimeIn(r.Start, r.loc)
+		if err != nil {
+			return fmt.Errorf("轮换开始时间错误: %v", err)
+		}
+		r.start = start
+		if len(r.Participants) == 0 {
+			r.Participants = t.Members
+		}
+		if len(r.Participants) == 0 {
+			return fmt.Errorf("轮换人员不能为空")
+		}
+		if r.OnCallCount <= 0 {
+			r.OnCallCount = 1
+		}
+		if r.OnCallCount > len(r.Participants) {
+			return fmt.Errorf("每班值班人数不能超过轮换人数")
+		}
+	}
+
+	for i := range t.Overrides {
+		o := &t.Overrides[i]
+		if len(o.Users) == 0 {
+			return fmt.Errorf("第 %d 个替班未指定人员", i+1)
+		}
+		var err error
+		if o.start, err = parseFlexibleTime(o.Start); err != nil {
+			return fmt.Errorf("第 %d 个替班开始时间错误: %v", i+1, err)
+		}
+		if o.end, err = parseFlexibleTime(o.End); err != nil {
+			return fmt.Errorf("第 %d 个替班结束时间错误: %v", i+1, err)
+		}
+		if !o.end.After(o.start) {
+			return fmt.Errorf("第 %d 个替班结束时间必须晚于开始时间", i+1)
+		}
+	}
+	return nil
+}
+
+// parseTimeIn 解析时间，无时区的时间按指定时区解析
+func parseTimeIn(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{legacyTimeLayout, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	t, err := parseFlexibleTime(value)
+	if err != nil {
+		return t, err
+	}
+	return t.In(loc), nil
+}
+
+// findTeam 根据名称查找团队
+func findTeam(name string) *Team {
+	teamsMu.RLock()
+	defer teamsMu.RUnlock()
+	return teams[name]
+}
+
+// shiftDays 每班天数
+func (r *Rotation) shiftDays() int {
+	if r.Type == RotationWeekly {
+		return 7
+	}
+	return 1
+}
+
+// shiftStart 第 n 班的开始时间，按日历日计算以正确处理夏令时
+func (r *Rotation) shiftStart(n int) time.Time {
+	return r.start.AddDate(0, 0, n*r.shiftDays())
+}
+
+// shiftIndex 计算时间点所在班次的序号
+func (r *Rotation) shiftIndex(at time.Time) int {
+	n := int(at.Sub(r.start).Hours() / 24 / float64(r.shiftDays()))
+	for r.shiftStart(n).After(at) {
+		n--
+	}
+	for !r.shiftStart(n + 1).After(at) {
+		n++
+	}
+	return n
+}
+
+// shiftUsers 第 n 班的值班人员
+func (r *Rotation) shiftUsers(n int) []string {
+	users := make([]string, 0, r.OnCallCount)
+	size := len(r.Participants)
+	for i := 0; i < r.OnCallCount; i++ {
+		index := ((n*r.OnCallCount+i)%size + size) % size
+		users = append(users, r.Participants[index])
+	}
+	return users
+}
+
+// OnCallAt 查询团队在指定时间的值班班次，替班优先于轮换
+func (t *Team) OnCallAt(at time.Time) *OnCallShift {
+	var override *OnCallShift
+	for _, o := range t.Overrides {
+		if at.Before(o.start) || !at.Before(o.end) {
+			continue
+		}
+		if override == nil {
+			override = &OnCallShift{Team: t.Name, Start: formatAPITime(o.start), End: formatAPITime(o.end), Override: true, Comment: o.Comment}
+		}
+		override.Users = appendOnCallUsers(override.Users, o.Users)
+	}
+	if override != nil {
+		return override
+	}
+
+	if t.Rotation == nil {
+		return nil
+	}
+	n := t.Rotation.shiftIndex(at)
+	return &OnCallShift{
+		Team:  t.Name,
+		Users: appendOnCallUsers(nil, t.Rotation.shiftUsers(n)),
+		Start: formatAPITime(t.Rotation.shiftStart(n)),
+		End:   formatAPITime(t.Rotation.shiftStart(n + 1)),
+	}
+}
+
+// appendOnCallUsers 追加值班人员并补充用户目录中的姓名和邮箱
+func appendOnCallUsers(users []OnCallUser, eNames []string) []OnCallUser {
+	for _, eName := range eNames {
+		user := OnCallUser{EName: eName}
+		if info, found := findUserInfo(eName); found {
+			user.Name = info.Name
+			user.Email = info.Email
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+// isTeamRecipient 判断收件人是否为团队收件人
+func isTeamRecipient(recipient string) bool {
+	return strings.HasPrefix(recipient, RecipientPrefixTeam) ||
+		strings.HasPrefix(recipient, RecipientPrefixOnCall) ||
+		strings.HasPrefix(recipient, RecipientPrefixLead)
+}
+
+// validateTeamRecipient 校验团队收件人引用的团队是否存在
+func validateTeamRecipient(recipient string) error {
+	if !isTeamRecipient(recipient) {
+		return nil
+	}
+	name := recipient[strings.Index(recipient, ":")+1:]
+	team := findTeam(name)
+	if team == nil {
+		return fmt.Errorf("团队 %s 不存在", name)
+	}
+	if strings.HasPrefix(recipient, RecipientPrefixOnCall) && team.Rotation == nil && len(team.Overrides) == 0 {
+		return fmt.Errorf("团队 %s 未配置值班轮换", name)
+	}
+	if strings.HasPrefix(recipient, RecipientPrefixLead) && team.Lead == "" {
+		return fmt.Errorf("团队 %s 未配置负责人", name)
+	}
+	return nil
+}
+
+// resolveTeamRecipient 将团队收件人解析为具体人员，非团队收件人原样返回
+func resolveTeamRecipient(recipient string, at time.Time) []string {
+	if !isTeamRecipient(recipient) {
+		return []string{recipient}
+	}
+
+	name := recipient[strings.Index(recipient, ":")+1:]
+	team := findTeam(name)
+	if team == nil {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(recipient, RecipientPrefixTeam):
+		return team.Members
+	case strings.HasPrefix(recipient, RecipientPrefixLead):
+		if team.Lead == "" {
+			return nil
+		}
+		return []string{team.Lead}
+	default:
+		shift := team.OnCallAt(at)
+		if shift == nil {
+			return nil
+		}
+		users := make([]string, 0, len(shift.Users))
+		for _, user := range shift.Users {
+			users = append(users, user.EName)
+		}
+		return users
+	}
+}
+
+// expandTeamRecipients 发送前将团队收件人展开为当前的具体人员，同一人员的告警合并为一封邮件。
+// 无法解析的团队收件人保持原样，按未找到用户处理
+func expandTeamRecipients(userAlertsList []UserAlerts) []UserAlerts {
+	now := time.Now()
+	index := make(map[string]int)
+	var result []UserAlerts
+
+	for _, userAlerts := range userAlertsList {
+		recipients := resolveTeamRecipient(userAlerts.Recipient, now)
+		if len(recipients) == 0 {
+			LogSystem(logrus.WarnLevel, "oncall", "团队收件人无法解析到具体人员", map[string]interface{}{
+				"recipient": userAlerts.Recipient,
+			})
+			recipients = []string{userAlerts.Recipient}
+		} else if isTeamRecipient(userAlerts.Recipient) {
+			LogSystem(logrus.InfoLevel, "oncall", "团队收件人已解析", map[string]interface{}{
+				"recipient": userAlerts.Recipient,
+				"users":     recipients,
+			})
+		}
+
+		for _, recipient := range recipients {
+			i, ok := index[recipient]
+			if !ok {
+				index[recipient] = len(result)
+				result = append(result, UserAlerts{Recipient: recipient})
+				i = len(result) - 1
+			}
+			result[i].Alerts = append(result[i].Alerts, userAlerts.Alerts...)
+			result[i].Inhibited = append(result[i].Inhibited, userAlerts.Inhibited...)
+		}
+	}
+	return result
+}
+
+// GetTeamsHandler 查看团队配置
+func GetTeamsHandler(c *gin.Context) {
+	teamsMu.RLock()
+	list := make([]*Team, 0, len(teams))
+	for _, team := range teams {
+		list = append(list, team)
+	}
+	teamsMu.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_teams_ok"),
+		"data":    list,
+		"file":    config.Routing.TeamsFile,
+	})
+}
+
+// GetOnCallHandler 查询当前（或指定时间）的值班人员，可按 team 过滤
+func GetOnCallHandler(c *gin.Context) {
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		var err error
+		if at, err = parseFlexibleTime(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_time"),
+			})
+			return
+		}
+	}
+
+	var selected []*Team
+	if name := c.Query("team"); name != "" {
+		team := findTeam(name)
+		if team == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": apiMessage(c, "api.team_not_found"),
+			})
+			return
+		}
+		selected = append(selected, team)
+	} else {
+		teamsMu.RLock()
+		for _, team := range teams {
+			selected = append(selected, team)
+		}
+		teamsMu.RUnlock()
+	}
+
+	shifts := []OnCallShift{}
+	for _, team := range selected {
+		if shift := team.OnCallAt(at); shift != nil {
+			shifts = append(shifts, *shift)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_oncall_ok"),
+		"data":    shifts,
+		"at":      formatAPITime(at),
+	})
+}
+
+// ExportOnCallICSHandler 导出团队值班表为 iCalendar 格式，默认导出未来 28 天
+func ExportOnCallICSHandler(c *gin.Context) {
+	team := findTeam(c.Param("name"))
+	if team == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": apiMessage(c, "api.team_not_found"),
+		})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "28"))
+	if err != nil || days <= 0 || days > 366 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.days_invalid"),
+		})
+		return
+	}
+
+	from := time.Now()
+	to := from.AddDate(0, 0, days)
+
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//alert-api//oncall//ZH")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "X-WR-CALNAME:"+escapeICSText(team.Name+" 值班表"))
+
+	stamp := from.UTC().Format("20060102T150405Z")
+	if r := team.Rotation; r != nil {
+		for n := r.shiftIndex(from); r.shiftStart(n).Before(to); n++ {
+			users := r.shiftUsers(n)
+			writeICSEvent(&b, fmt.Sprintf("%s-shift-%d@alert-api", team.Name, n), stamp,
+				r.shiftStart(n), r.shiftStart(n+1),
+				fmt.Sprintf("%s 值班: %s", team.Name, strings.Join(users, ", ")), "")
+		}
+	}
+	for i, o := range team.Overrides {
+		if !o.end.After(from) || !o.start.Before(to) {
+			continue
+		}
+		writeICSEvent(&b, fmt.Sprintf("%s-override-%d-%d@alert-api", team.Name, i, o.start.Unix()), stamp,
+			o.start, o.end,
+			fmt.Sprintf("%s 替班: %s", team.Name, strings.Join(o.Users, ", ")), o.Comment)
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", team.Name+"-oncall.ics"))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(b.String()))
+}
+
+// writeICSEvent 写入一个 iCalendar 事件
+func writeICSEvent(b *strings.Builder, uid, stamp string, start, end time.Time, summary, description string) {
+	writeICSLine(b, "BEGIN:VEVENT")
+	writeICSLine(b, "UID:"+uid)
+	writeICSLine(b, "DTSTAMP:"+stamp)
+	writeICSLine(b, "DTSTART:"+start.UTC().Format("20060102T150405Z"))
+	writeICSLine(b, "DTEND:"+end.UTC().Format("20060102T150405Z"))
+	writeICSLine(b, "SUMMARY:"+escapeICSText(summary))
+	if description != "" {
+		writeICSLine(b, "DESCRIPTION:"+escapeICSText(description))
+	}
+	writeICSLine(b, "END:VEVENT")
+}
+
+// icsLineLimit iCalendar 每行最多的字节数（不含 CRLF），见 RFC 5545 3.1
+const icsLineLimit = 75
+
+// writeICSLine 写入一行 iCalendar 内容，使用 CRLF 换行；超过 75 字节时折行，
+// 续行以空格开头，不拆分 UTF-8 多字节字符
+func writeICSLine(b *strings.Builder, line string) {
+	limit := icsLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = icsLineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// escapeICSText 转义 iCalendar 文本中的特殊字符
+func escapeICSText(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return replacer.Replace(text)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestWriteICSLineFolding 超过 75 字节的行折行，续行以空格开头且不拆分 UTF-8 字符
+func TestWriteICSLineFolding(t *testing.T) {
+	cases := []struct {
+		name  string
+		line  string
+		lines int
+	}{
+		{"short", "SUMMARY:cdn", 1},
+		{"exactly 75", "DESCRIPTION:" + strings.Repeat("a", 63), 1},
+		{"76 ascii", "DESCRIPTION:" + strings.Repeat("a", 64), 2},
+		{"long ascii", "DESCRIPTION:" + strings.Repeat("a", 200), 3},
+		{"chinese", "SUMMARY:" + strings.Repeat("值班", 30), 3},
+		{"mixed", "X-WR-CALNAME:" + strings.Repeat("a值", 40), 3},
+		{"emoji", "SUMMARY:a" + strings.Repeat("🔥", 40), 3},
+	}
+	for _, tc := range cases {
+		var b strings.Builder
+		writeICSLine(&b, tc.line)
+		out := b.String()
+		if !strings.HasSuffix(out, "\r\n") {
+			t.Errorf("%s: output does not end with CRLF: %q", tc.name, out)
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+		if len(lines) != tc.lines {
+			t.Errorf("%s: %d lines, want %d", tc.name, len(lines), tc.lines)
+		}
+		for i, line := range lines {
+			if len(line) > icsLineLimit {
+				t.Errorf("%s: line %d is %d octets", tc.name, i, len(line))
+			}
+			if i > 0 && !strings.HasPrefix(line, " ") {
+				t.Errorf("%s: continuation line %d does not start with a space: %q", tc.name, i, line)
+			}
+			if !utf8.ValidString(line) {
+				t.Errorf("%s: line %d splits a UTF-8 sequence: %q", tc.name, i, line)
+			}
+		}
+		if unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", ""); unfolded != tc.line {
+			t.Errorf("%s: unfolded = %q, want %q", tc.name, unfolded, tc.line)
+		}
+	}
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ChannelIM 个人IM机器人通知渠道
+const ChannelIM = "im"
+
+// 汇总邮件频率
+const (
+	DigestDaily  = "daily"  // 每次汇总任务都发送
+	DigestWeekly = "weekly" // 每周一发送最近7天的汇总
+	DigestOff    = "off"    // 不接收汇总邮件，只接收立即通知
+)
+
+// 通知语言，也接受 zh-CN、en-US；为空时使用服务默认语言（SERVER_LOCALE）
+const (
+	LanguageZH = "zh"
+	LanguageEN = "en"
+)
+
+// 邮件正文格式
+const (
+	EmailFormatHTML = "html" // HTML 正文（网关支持时同时附带纯文本）
+	EmailFormatText = "text" // 只发送纯文本正文，适合读屏软件和不显示HTML的客户端
+)
+
+// weeklyDigestWeekday 每周汇总的发送日
+const weeklyDigestWeekday = time.Monday
+
+// severityAny 未单独配置的告警级别使用的偏好键
+const severityAny = "*"
+
+// SeverityPreference 某一告警级别的通知偏好
+type SeverityPreference struct {
+	Channels []string `json:"channels"`           // email、im，为空表示不通知
+	Schedule string   `json:"schedule,omitempty"` // immediate / digest，为空时按路由规则
+}
+
+// QuietHours 免打扰时段，按用户时区计算，支持跨零点（如 22:00-08:00）
+type QuietHours struct {
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	AllowCritical bool   `json:"allow_critical,omitempty"` // 免打扰时段内是否仍立即通知 critical 告警
+}
+
+// UserPreferences 用户通知偏好
+type UserPreferences struct {
+	EName       string                        `json:"e_name"`
+	Severities  map[string]SeverityPreference `json:"severities,omitempty"` // 按告警级别（info/warning/critical/*），未配置时发送邮件并按路由规则
+	Digest      string                        `json:"digest"`               // daily / weekly / off
+	QuietHours  *QuietHours                   `json:"quiet_hours,omitempty"`
+	Timezone    string                        `json:"timezone,omitempty"`   // 为空时使用服务时区
+	Language    string                        `json:"language"`             // zh / en，为空时使用服务默认语言
+	EmailFormat string                        `json:"email_format"`         // html / text
+	Attachment  string                        `json:"attachment,omitempty"` // 告警列表附件：none / csv / xlsx，为空时使用 EMAIL_ATTACHMENT_FORMAT
+	IMWebhook   string                        `json:"im_webhook,omitempty"`
+	UpdatedAt   time.Time                     `json:"updated_at"`
+}
+
+var (
+	preferencesMu sync.RWMutex
+	preferences   = map[string]*UserPreferences{}
+)
+
+// defaultPreferences 未设置偏好的用户的默认值
+func defaultPreferences(eName string) *UserPreferences {
+	return &UserPreferences{EName: eName, Digest: DigestDaily, EmailFormat: EmailFormatHTML}
+}
+
+// createUserPreferenceTable 创建用户通知偏好表
+func createUserPreferenceTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		e_name VARCHAR(100) NOT NULL PRIMARY KEY,
+		preferences TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// GetAllUserPreferences 查询所有用户的通知偏好
+func GetAllUserPreferences() (map[string]*UserPreferences, error) {
+	rows, err := db.Query(`SELECT e_name, preferences, updated_at FROM user_preferences`)
+	if err != nil {
+		LogDatabase("SELECT", "user_preferences", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询用户通知偏好失败: %v", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]*UserPreferences)
+	for rows.Next() {
+		var eName, data string
+		var updatedAt time.Time
+		if err := rows.Scan(&eName, &data, &updatedAt); err != nil {
+			LogDatabase("SELECT", "user_preferences", false, err.Error(), 0)
+			return nil, fmt.Errorf("扫描用户通知偏好失败: %v", err)
+		}
+		pref := defaultPreferences(eName)
+		if err := json.Unmarshal([]byte(data), pref); err != nil {
+			LogSystem(logrus.WarnLevel, "preferences", "用户通知偏好格式错误，使用默认值", map[string]interface{}{
+				"e_name": eName,
+				"error":  err.Error(),
+			})
+			pref = defaultPreferences(eName)
+		}
+		pref.EName = eName
+		pref.UpdatedAt = updatedAt
+		result[eName] = pref
+	}
+
+	LogDatabase("SELECT", "user_preferences", true, "", int64(len(result)))
+	return result, nil
+}
+
+// SaveUserPreferences 保存用户通知偏好
+func SaveUserPreferences(pref *UserPreferences) error {
+	data, err := json.Marshal(pref)
+	if err != nil {
+		return fmt.Errorf("序列化用户通知偏好失败: %v", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO user_preferences (e_name, preferences) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE preferences = VALUES(preferences)`, pref.EName, string(data))
+	if err != nil {
+		LogDatabase("INSERT", "user_preferences", false, err.Error(), 0)
+		return fmt.Errorf("保存用户通知偏好失败: %v", err)
+	}
+
+	LogDatabase("INSERT", "user_preferences", true, "", 1)
+	return nil
+}
+
+// InitUserPreferences 从数据库加载用户通知偏好缓存
+func InitUserPreferences() error {
+	loaded, err := GetAllUserPreferences()
+	if err != nil {
+		return err
+	}
+
+	preferencesMu.Lock()
+	preferences = loaded
+	preferencesMu.Unlock()
+
+	LogSystem(logrus.InfoLevel, "preferences", "用户通知偏好加载成功", map[string]interface{}{
+		"user_count": len(loaded),
+	})
+	log.Printf("用户通知偏好加载成功，共 %d 个用户", len(loaded))
+	return nil
+}
+
+// preferencesFor 获取收件人的通知偏好，未设置时返回 nil
+func preferencesFor(recipient string) *UserPreferences {
+	preferencesMu.RLock()
+	pref, ok := preferences[recipient]
+	empty := len(preferences) == 0
+	preferencesMu.RUnlock()
+	if ok || empty || isTeamRecipient(recipient) {
+		return pref
+	}
+
+	// 收件人写成邮箱或别名时按用户目录解析到英文名
+	if eName := resolveRecipient(recipient).EName; eName != "" && eName != recipient {
+		preferencesMu.RLock()
+		defer preferencesMu.RUnlock()
+		return preferences[eName]
+	}
+	return nil
+}
+
+// severity 获取告警级别对应的偏好，未配置时返回 false
+func (p *UserPreferences) severity(severity string) (SeverityPreference, bool) {
+	if pref, ok := p.Severities[severity]; ok {
+		return pref, true
+	}
+	pref, ok := p.Severities[severityAny]
+	return pref, ok
+}
+
+// location 用户时区，未设置时使用服务时区
+func (p *UserPreferences) location() *time.Location {
+	if p.Timezone != "" {
+		if loc, err := time.LoadLocation(p.Timezone); err == nil {
+			return loc
+		}
+	}
+	return serverLocation
+}
+
+// locale 用户通知语言，未设置时使用服务默认语言
+func (p *UserPreferences) locale() string {
+	if p == nil {
+		return defaultLocale
+	}
+	return localeOrDefault(p.Language)
+}
+
+// inQuietHours 判断某一时刻是否处于用户的免打扰时段
+func (p *UserPreferences) inQuietHours(t time.Time, severity string) bool {
+	q := p.QuietHours
+	if q == nil || (q.AllowCritical && severity == "critical") {
+		return false
+	}
+	start, errStart := parseClock(q.Start)
+	end, errEnd := parseClock(q.End)
+	if errStart != nil || errEnd != nil || start == end {
+		return false
+	}
+
+	local := t.In(p.location())
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock 解析 HH:MM，返回当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式错误，应为 HH:MM: %s", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// preferenceError 通知偏好校验失败时消息目录中的错误消息
+type preferenceError struct {
+	Key  string
+	Args []interface{}
+}
+
+func (e *preferenceError) Error() string { return T(defaultLocale, e.Key, e.Args...) }
+
+// validate 校验并补全用户通知偏好，失败时返回 *preferenceError
+func (p *UserPreferences) validate() error {
+	if p.Digest == "" {
+		p.Digest = DigestDaily
+	}
+	if p.Digest != DigestDaily && p.Digest != DigestWeekly && p.Digest != DigestOff {
+		return &preferenceError{"api.pref_digest_invalid", nil}
+	}
+	if p.Language != "" {
+		if _, ok := normalizeLocale(p.Language); !ok {
+			return &preferenceError{"api.pref_language_invalid", nil}
+		}
+	}
+	if p.EmailFormat == "" {
+		p.EmailFormat = EmailFormatHTML
+	}
+	if p.EmailFormat != EmailFormatHTML && p.EmailFormat != EmailFormatText {
+		return &preferenceError{"api.pref_email_format_invalid", nil}
+	}
+	if !validAttachmentFormat(p.Attachment) {
+		return &preferenceError{"api.pref_attachment_invalid", nil}
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return &preferenceError{"api.pref_timezone_invalid", []interface{}{p.Timezone}}
+		}
+	}
+	if p.QuietHours != nil {
+		for _, clock := range []string{p.QuietHours.Start, p.QuietHours.End} {
+			if _, err := parseClock(clock); err != nil {
+				return &preferenceError{"api.pref_clock_invalid", []interface{}{clock}}
+			}
+		}
+		// 免打扰时段内的告警改为随汇总邮件发送，不接收汇总邮件时这些告警将无法送达
+		if p.Digest == DigestOff {
+			return &preferenceError{"api.pref_quiet_hours_digest_off", nil}
+		}
+	}
+
+	for severity, pref := range p.Severities {
+		if severity != severityAny && severity != "info" && severity != "warning" && severity != "critical" {
+			return &preferenceError{"api.pref_severity_invalid", []interface{}{severity}}
+		}
+		if pref.Schedule != "" && pref.Schedule != ScheduleImmediate && pref.Schedule != ScheduleDigest {
+			return &preferenceError{"api.pref_schedule_invalid", []interface{}{severity}}
+		}
+		if pref.Schedule == ScheduleDigest && p.Digest == DigestOff {
+			return &preferenceError{"api.pref_schedule_digest_off", []interface{}{severity}}
+		}
+		for _, channel := range pref.Channels {
+			switch channel {
+			case ChannelEmail:
+			case ChannelIM:
+				if p.IMWebhook == "" {
+					return &preferenceError{"api.pref_im_webhook_required", nil}
+				}
+			default:
+				return &preferenceError{"api.pref_channel_invalid", []interface{}{channel}}
+			}
+		}
+	}
+	return nil
+}
+
+// notifyTime 告警触发通知的时间，用于判断免打扰时段：使用程序写入的 created_at（写入时的服务器时间），
+// 不使用调用方传入的 alert_time；新写入的告警使用当前时间
+func notifyTime(alert *Alert) time.Time {
+	if alert.CreatedAt.IsZero() {
+		return time.Now()
+	}
+	return alert.CreatedAt
+}
+
+// sentImmediately 判断告警是否（已）立即通知收件人：按路由规则或收件人偏好的 schedule，
+// 处于免打扰时段的告警改为随汇总邮件发送；不接收汇总邮件（digest=off）的收件人始终立即通知。
+// recipient 为团队收件人展开后的具体人员。立即通知和汇总任务使用同一判断，汇总任务只跳过已记录 notified_at 的告警
+func sentImmediately(recipient string, alert *Alert, routes []RouteResult) bool {
+	immediate := isImmediate(routes)
+	pref := preferencesFor(recipient)
+	if pref == nil {
+		return immediate
+	}
+
+	sp, ok := pref.severity(alert.Severity)
+	if ok && len(sp.Channels) == 0 {
+		return false
+	}
+	if pref.Digest == DigestOff {
+		return true
+	}
+	if ok && sp.Schedule != "" {
+		immediate = sp.Schedule == ScheduleImmediate
+	}
+	return immediate && !pref.inQuietHours(notifyTime(alert), alert.Severity)
+}
+
+// applyDigestFrequency 按用户的汇总频率调整汇总列表，userAlertsList 中的团队收件人需已展开为具体人员：
+// off 的用户只补发立即通知未成功（未记录 notified_at）的告警，weekly 的用户只在每周一发送最近7天的汇总
+func applyDigestFrequency(userAlertsList []UserAlerts, startTime, endTime time.Time) ([]UserAlerts, error) {
+	var result []UserAlerts
+	skipped := 0
+	for _, userAlerts := range userAlertsList {
+		pref := preferencesFor(userAlerts.Recipient)
+		if pref != nil && pref.Digest == DigestOff {
+			var undelivered []Alert
+			for _, alert := range userAlerts.Alerts {
+				if alert.NotifiedAt == nil {
+					undelivered = append(undelivered, alert)
+				}
+			}
+			if len(undelivered) > 0 {
+				result = append(result, UserAlerts{Recipient: userAlerts.Recipient, Alerts: undelivered, Inhibited: userAlerts.Inhibited})
+				continue
+			}
+		}
+		if pref != nil && pref.Digest != DigestDaily {
+			skipped++
+			continue
+		}
+		result = append(result, userAlerts)
+	}
+
+	if time.Now().In(serverLocation).Weekday() == weeklyDigestWeekday {
+		weekList, err := GetAlertsGroupedByRecipient(startTime.AddDate(0, 0, -6), endTime)
+		if err != nil {
+			return nil, err
+		}
+		for _, userAlerts := range expandTeamRecipients(weekList) {
+			if pref := preferencesFor(userAlerts.Recipient); pref != nil && pref.Digest == DigestWeekly {
+				result = append(result, userAlerts)
+			}
+		}
+	}
+
+	if skipped > 0 {
+		LogSystem(logrus.InfoLevel, "preferences", "已按汇总频率跳过用户", map[string]interface{}{
+			"skipped_count": skipped,
+		})
+	}
+	return result, nil
+}
+
+// dispatchNotifications 按用户偏好的渠道发送通知：邮件渠道的告警合并发送邮件，im 渠道推送到个人机器人，
+// 未设置偏好的收件人全部发送邮件；返回两个渠道合并后的每个收件人的结果。
+// IM 和邮件同样并发发送，共用整体截止时间
+func dispatchNotifications(ctx context.Context, userAlertsList []UserAlerts) (*SendResult, error) {
+	ctx, cancel := withSendDeadline(ctx)
+	defer cancel()
+
+	// 团队收件人先展开为具体人员，按每个人员自己的渠道偏好发送
+	userAlertsList = expandTeamRecipients(userAlertsList)
+
+	result := &SendResult{}
+	type imMessage struct {
+		pref      *UserPreferences
+		recipient string
+		alerts    []Alert
+		gateway   *GatewayResponse
+		err       error
+	}
+	var imMessages []*imMessage
+	var emailList []UserAlerts
+	for _, userAlerts := range userAlertsList {
+		pref := preferencesFor(userAlerts.Recipient)
+		if pref == nil {
+			emailList = append(emailList, userAlerts)
+			continue
+		}
+
+		var emailAlerts, imAlerts []Alert
+		for _, alert := range userAlerts.Alerts {
+			channels := []string{ChannelEmail}
+			if sp, ok := pref.severity(alert.Severity); ok {
+				channels = sp.Channels
+			}
+			for _, channel := range channels {
+				switch channel {
+				case ChannelEmail:
+					emailAlerts = append(emailAlerts, alert)
+				case ChannelIM:
+					imAlerts = append(imAlerts, alert)
+				}
+			}
+		}
+
+		if len(emailAlerts) > 0 {
+			emailList = append(emailList, UserAlerts{
+				Recipient: userAlerts.Recipient,
+				Alerts:    emailAlerts,
+				Inhibited: userAlerts.Inhibited,
+			})
+		}
+		if len(imAlerts) > 0 && pref.IMWebhook != "" {
+			imMessages = append(imMessages, &imMessage{pref: pref, recipient: userAlerts.Recipient, alerts: imAlerts})
+		}
+	}
+
+	runParallel(ctx, len(imMessages), sendWorkers(), func(ctx context.Context, i int) {
+		msg := imMessages[i]
+		msg.gateway, msg.err = sendIMNotification(ctx, msg.pref, msg.recipient, msg.alerts)
+	})
+	for _, msg := range imMessages {
+		result.add(RecipientSendResult{
+			Recipient:  msg.recipient,
+			Found:      true,
+			Channel:    ChannelIM,
+			AlertCount: len(msg.alerts),
+		}, msg.gateway, msg.err)
+		if msg.err != nil {
+			LogSystem(logrus.ErrorLevel, "preferences", "IM通知发送失败", map[string]interface{}{
+				"recipient": msg.recipient,
+				"error":     msg.err.Error(),
+			})
+		} else {
+			LogSystem(logrus.InfoLevel, "preferences", "IM通知发送成功", map[string]interface{}{
+				"recipient":   msg.recipient,
+				"alert_count": len(msg.alerts),
+			})
+		}
+	}
+
+	if len(emailList) > 0 {
+		emailResult, err := SendAlertEmail(ctx, emailList)
+		result.merge(emailResult)
+		if err != nil {
+			return result, err
+		}
+	}
+	if failed := result.Failed(); len(failed) > 0 {
+		return result, fmt.Errorf("部分通知发送失败，成功: %d，失败: %d", result.SuccessCount(), len(failed))
+	}
+	return result, nil
+}
+
+// sendIMNotification 将告警推送到用户的个人IM机器人，时间按用户时区显示
+func sendIMNotification(ctx context.Context, pref *UserPreferences, recipient string, alerts []Alert) (*GatewayResponse, error) {
+	return postIMText(ctx, pref.IMWebhook, renderIMText(pref, recipient, alerts))
+}
+
+// renderIMText 按用户的语言和时区生成个人IM消息内容，最多列出 20 条告警
+func renderIMText(pref *UserPreferences, recipient string, alerts []Alert) string {
+	loc := pref.location()
+	var b strings.Builder
+	locale := pref.locale()
+	fmt.Fprintln(&b, T(locale, "im.alert.header", recipient, len(alerts)))
+	for i, alert := range alerts {
+		if i == 20 {
+			fmt.Fprintln(&b, T(locale, "im.alert.more", len(alerts)-i))
+			break
+		}
+		fmt.Fprintf(&b, "%d. [%s] %s %s\n", i+1, alert.Severity,
+			alert.AlertTime.In(loc).Format("2006-01-02 15:04:05"), alert.Message)
+	}
+	return b.String()
+}
+
+// loadPreferenceUser 根据路径参数查找用户（本地目录或LDAP），失败时直接写入响应
+func loadPreferenceUser(c *gin.Context) (UserInfo, bool) {
+	user, _, ok := resolveUser(c.Param("ename"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": apiMessage(c, "api.user_not_found"),
+		})
+		return UserInfo{}, false
+	}
+	return user, true
+}
+
+// GetUserPreferencesHandler 查询用户通知偏好，未设置时返回默认值
+func GetUserPreferencesHandler(c *gin.Context) {
+	user, ok := loadPreferenceUser(c)
+	if !ok {
+		return
+	}
+
+	preferencesMu.RLock()
+	pref, found := preferences[user.EName]
+	preferencesMu.RUnlock()
+	if !found {
+		pref = defaultPreferences(user.EName)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_preferences_ok"),
+		"data":    pref,
+	})
+}
+
+// UpdateUserPreferencesHandler 整体替换用户通知偏好
+func UpdateUserPreferencesHandler(c *gin.Context) {
+	user, ok := loadPreferenceUser(c)
+	if !ok {
+		return
+	}
+
+	var pref UserPreferences
+	if err := c.ShouldBindJSON(&pref); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+	pref.EName = user.EName
+	if err := pref.validate(); err != nil {
+		message := err.Error()
+		if pe, ok := err.(*preferenceError); ok {
+			message = apiMessage(c, pe.Key, pe.Args...)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": message,
+		})
+		return
+	}
+
+	if err := SaveUserPreferences(&pref); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.save_preferences_failed", err),
+		})
+		return
+	}
+	pref.UpdatedAt = time.Now()
+
+	preferencesMu.Lock()
+	preferences[pref.EName] = &pref
+	preferencesMu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.save_preferences_ok"),
+		"data":    pref,
+	})
+}
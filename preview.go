@@ -0,0 +1,294 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationPreviewRequest 通知预览请求，告警来源三选一：告警ID、时间范围或内联告警
+type NotificationPreviewRequest struct {
+	Recipient string               `json:"recipient" binding:"required"`
+	AlertIDs  []int                `json:"alert_ids"`
+	StartTime string               `json:"start_time"`
+	EndTime   string               `json:"end_time"`
+	Alerts    []CreateAlertRequest `json:"alerts" binding:"omitempty,dive"`
+}
+
+// NotificationPreview 通知预览结果
+type NotificationPreview struct {
+	Recipient   string                 `json:"recipient"`
+	To          []string               `json:"to"`       // 实际发送时的收件邮箱
+	Template    string                 `json:"template"` // alert_email / fallback_email
+	Mimetype    string                 `json:"mimetype"` // 实际发送的正文类型：html / plain / multipart
+	Subject     string                 `json:"subject"`
+	HTML        string                 `json:"html"`
+	Text        string                 `json:"text"`
+	AlertCount  int                    `json:"alert_count"`
+	Attachments []AttachmentInfo       `json:"attachments,omitempty"` // 实际发送时附带的告警列表附件
+	Resolution  RecipientResolution    `json:"resolution"`
+	Members     []*NotificationPreview `json:"members,omitempty"` // 团队收件人展开后每个人员的预览，此时外层不含邮件内容
+}
+
+// previewError 预览失败时返回的状态码和消息目录中的错误消息
+type previewError struct {
+	Status int
+	Key    string
+	Args   []interface{}
+}
+
+func (e *previewError) Error() string { return T(defaultLocale, e.Key, e.Args...) }
+
+// loadPreviewAlerts 根据请求加载要预览的告警
+func loadPreviewAlerts(req *NotificationPreviewRequest, recipient string) ([]Alert, error) {
+	sources := 0
+	if len(req.AlertIDs) > 0 {
+		sources++
+	}
+	if req.StartTime != "" || req.EndTime != "" {
+		sources++
+	}
+	if len(req.Alerts) > 0 {
+		sources++
+	}
+	if sources != 1 {
+		return nil, &previewError{http.StatusBadRequest, "api.preview_source_invalid", nil}
+	}
+
+	switch {
+	case len(req.AlertIDs) > 0:
+		var alerts []Alert
+		for _, id := range req.AlertIDs {
+			alert, err := GetAlertByID(id)
+			if err != nil {
+				return nil, &previewError{http.StatusInternalServerError, "api.preview_failed", []interface{}{err}}
+			}
+			if alert == nil {
+				return nil, &previewError{http.StatusNotFound, "api.alert_not_found_id", []interface{}{id}}
+			}
+			alerts = append(alerts, *alert)
+		}
+		return alerts, nil
+
+	case req.StartTime != "" || req.EndTime != "":
+		if req.StartTime == "" || req.EndTime == "" {
+			return nil, &previewError{http.StatusBadRequest, "api.preview_range_incomplete", nil}
+		}
+		startTime, err := parseFlexibleTime(req.StartTime)
+		if err != nil {
+			return nil, &previewError{http.StatusBadRequest, "api.invalid_start_time_detail", []interface{}{err}}
+		}
+		endTime, err := parseFlexibleTime(req.EndTime)
+		if err != nil {
+			return nil, &previewError{http.StatusBadRequest, "api.invalid_end_time_detail", []interface{}{err}}
+		}
+		alerts, err := GetAlertsByTimeRangeAndRecipient(startTime, endTime, recipient)
+		if err != nil {
+			return nil, &previewError{http.StatusInternalServerError, "api.preview_failed", []interface{}{err}}
+		}
+		if len(alerts) == 0 {
+			return nil, &previewError{http.StatusNotFound, "api.preview_no_alerts", nil}
+		}
+		return alerts, nil
+
+	default:
+		var alerts []Alert
+		for i, item := range req.Alerts {
+			alertTime := time.Now()
+			if item.AlertTime != "" {
+				parsed, err := parseFlexibleTime(item.AlertTime)
+				if err != nil {
+					return nil, &previewError{http.StatusBadRequest, "api.invalid_item_time", []interface{}{i + 1, err}}
+				}
+				alertTime = parsed
+			}
+			severity := item.Severity
+			if severity == "" {
+				severity = "warning"
+			}
+			domain := item.Domain
+			if domain == "" {
+				domain = extractDomain(item.Message)
+			}
+			alerts = append(alerts, Alert{
+				Message:   item.Message,
+				Recipient: recipient,
+				Source:    item.Source,
+				Domain:    domain,
+				Severity:  severity,
+				Labels:    item.Labels,
+				Status:    AlertStatusOpen,
+				AlertTime: alertTime,
+			})
+		}
+		return alerts, nil
+	}
+}
+
+// buildNotificationPreview 渲染收件人将收到的邮件，找不到收件人邮箱时渲染兜底邮件，不实际发送。
+// 团队收件人（team:/oncall:/lead:）与实际发送一致，先展开为当前的具体人员，分别预览每个人员收到的邮件
+func buildNotificationPreview(req *NotificationPreviewRequest) (*NotificationPreview, error) {
+	recipient := canonicalRecipient(strings.TrimSpace(req.Recipient))
+	alerts, err := loadPreviewAlerts(req, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isTeamRecipient(recipient) {
+		return renderNotificationPreview(UserAlerts{Recipient: recipient, Alerts: alerts})
+	}
+
+	preview := &NotificationPreview{
+		Recipient:  recipient,
+		AlertCount: len(alerts),
+		Resolution: resolveRecipient(recipient),
+	}
+	for _, userAlerts := range expandTeamRecipients([]UserAlerts{{Recipient: recipient, Alerts: alerts}}) {
+		member, err := renderNotificationPreview(userAlerts)
+		if err != nil {
+			return nil, err
+		}
+		preview.To = append(preview.To, member.To...)
+		preview.Members = append(preview.Members, member)
+	}
+	return preview, nil
+}
+
+// renderNotificationPreview 渲染单个收件人（团队收件人已展开）将收到的邮件
+func renderNotificationPreview(userAlerts UserAlerts) (*NotificationPreview, error) {
+	recipient := userAlerts.Recipient
+	resolution := resolveRecipient(recipient)
+	preview := &NotificationPreview{
+		Recipient:  recipient,
+		AlertCount: len(userAlerts.Alerts),
+		Resolution: resolution,
+	}
+	var err error
+
+	if resolution.Found && resolution.Email != "" {
+		info := RecipientInfo{Email: resolution.Email, Found: true}
+		preview.Template = templateAlertEmail
+		preview.To = []string{resolution.Email}
+		var body emailBody
+		preview.Subject, body, err = generateEmailBodyForUser(userAlerts, info)
+		preview.HTML, preview.Text = body.HTML, body.Text
+		preview.Mimetype = body.mimetype(currentEmailConfig())
+		if preview.Mimetype == mimetypeHTML {
+			preview.HTML = body.htmlBody()
+		}
+		for _, attachment := range body.Attachments {
+			preview.Attachments = append(preview.Attachments, attachment.info())
+		}
+	} else {
+		fallbackAlerts := []UserAlerts{userAlerts}
+		notFoundUsers := []string{recipient}
+		preview.Template = templateFallbackEmail
+		preview.To = groupFallbackAlerts(fallbackAlerts)[0].Recipients
+		preview.Subject, preview.HTML, err = generateFallbackEmailContent(fallbackAlerts, notFoundUsers)
+		if err == nil {
+			preview.Text, err = generateFallbackPlainText(fallbackAlerts, notFoundUsers)
+		}
+		preview.Mimetype = emailBody{HTML: preview.HTML, Text: preview.Text}.mimetype(currentEmailConfig())
+	}
+	if err != nil {
+		return nil, &previewError{http.StatusInternalServerError, "api.preview_failed", []interface{}{err}}
+	}
+	return preview, nil
+}
+
+// respondPreviewError 写入预览失败的响应
+func respondPreviewError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	message := err.Error()
+	if pe, ok := err.(*previewError); ok {
+		status = pe.Status
+		message = apiMessage(c, pe.Key, pe.Args...)
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": message,
+	})
+}
+
+// PreviewNotificationHandler 预览通知邮件的主题、HTML 和纯文本内容，不发送邮件
+func PreviewNotificationHandler(c *gin.Context) {
+	var req NotificationPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+
+	preview, err := buildNotificationPreview(&req)
+	if err != nil {
+		respondPreviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.preview_ok"),
+		"data":    preview,
+	})
+}
+
+// PreviewNotificationHTMLHandler 在浏览器中直接查看渲染后的邮件 HTML，
+// 参数 recipient 和 alert_ids（逗号分隔）或 start_time/end_time
+func PreviewNotificationHTMLHandler(c *gin.Context) {
+	req := NotificationPreviewRequest{
+		Recipient: c.Query("recipient"),
+		StartTime: c.Query("start_time"),
+		EndTime:   c.Query("end_time"),
+	}
+	if strings.TrimSpace(req.Recipient) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.recipient_required"),
+		})
+		return
+	}
+	for _, part := range strings.Split(c.Query("alert_ids"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.alert_id_invalid_value", part),
+			})
+			return
+		}
+		req.AlertIDs = append(req.AlertIDs, id)
+	}
+
+	preview, err := buildNotificationPreview(&req)
+	if err != nil {
+		respondPreviewError(c, err)
+		return
+	}
+
+	// 团队收件人显示参数 member 指定的人员收到的邮件，未指定时显示第一个人员
+	if len(preview.Members) > 0 {
+		member := preview.Members[0]
+		for _, m := range preview.Members {
+			if m.Recipient == c.Query("member") {
+				member = m
+			}
+		}
+		preview = member
+	}
+
+	// 选择纯文本邮件的用户没有HTML正文
+	if c.Query("format") == "text" || preview.HTML == "" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(preview.Text))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(preview.HTML))
+}
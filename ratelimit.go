@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenBucket 令牌桶
+type tokenBucket struct {
+	tokens   float64
+	lastFill time.Time
+}
+
+// RateLimiter 按来源划分的令牌桶限流器
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	rate      float64 // 每秒补充的令牌数
+	burst     float64
+	lastSweep time.Time
+}
+
+var alertRateLimiter *RateLimiter
+
+// NewRateLimiter 创建限流器，ratePerMinute 为每分钟补充的令牌数
+func NewRateLimiter(ratePerMinute, burst int) *RateLimiter {
+	if ratePerMinute < 1 {
+		ratePerMinute = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		rate:      float64(ratePerMinute) / 60,
+		burst:     float64(burst),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow 尝试消耗一个令牌，失败时返回需要等待的时间
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	return l.AllowN(key, 1)
+}
+
+// AllowN 尝试消耗 n 个令牌，桶中不足 n 个时拒绝并返回补足所需的等待时间。
+// n 超过桶容量时永远无法放行，调用方需先用 Burst 检查
+func (l *RateLimiter) AllowN(key string, n int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, lastFill: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.lastFill).Seconds()
+	bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+	bucket.lastFill = now
+
+	if bucket.tokens >= float64(n) {
+		bucket.tokens -= float64(n)
+		return true, 0
+	}
+
+	wait := (float64(n) - bucket.tokens) / l.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// Burst 桶容量，即一次最多可消耗的令牌数
+func (l *RateLimiter) Burst() int {
+	return int(l.burst)
+}
+
+// Wait 阻塞直到获得一个令牌，ctx 取消或超时时返回错误
+func (l *RateLimiter) Wait(ctx context.Context, key string) error {
+	for {
+		allowed, wait := l.Allow(key)
+		if allowed {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// sweep 清理已经回满且长时间未使用的令牌桶，避免内存无限增长
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < 10*time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		fullAfter := time.Duration((l.burst - bucket.tokens) / l.rate * float64(time.Second))
+		if now.Sub(bucket.lastFill) > fullAfter {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimitKey 确定限流维度：优先已配置的API Key，其次告警来源，最后客户端IP。
+// 未配置（RATE_LIMIT_API_KEYS）的 X-API-Key 不作为限流维度，避免客户端每次换一个 Key 绕过限流
+func rateLimitKey(c *gin.Context, source string) string {
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" && isConfiguredAPIKey(apiKey) {
+		// 日志中只出现 Key 的摘要
+		sum := sha256.Sum256([]byte(apiKey))
+		return "key:" + hex.EncodeToString(sum[:4])
+	}
+	if source != "" {
+		return "source:" + source
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// isConfiguredAPIKey 判断 API Key 是否在 RATE_LIMIT_API_KEYS 中
+func isConfiguredAPIKey(apiKey string) bool {
+	for _, key := range config.Ingest.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// InitIngestProtection 初始化告警写入限流与风暴检测
+func InitIngestProtection() {
+	if config.Ingest.RateLimitEnabled {
+		alertRateLimiter = NewRateLimiter(config.Ingest.RatePerMinute, config.Ingest.Burst)
+	}
+	if config.Ingest.StormEnabled {
+		alertStormDetector = NewStormDetector(
+			time.Duration(config.Ingest.StormWindow)*time.Second,
+			config.Ingest.StormThreshold,
+		)
+	}
+}
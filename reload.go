@@ -0,0 +1,296 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadDebounce 文件变化后等待的时间，合并编辑器保存时产生的多个事件
+const reloadDebounce = time.Second
+
+// ReloadResult 配置重新加载结果
+type ReloadResult struct {
+	Trigger         string            `json:"trigger"`
+	Reloaded        []string          `json:"reloaded"`
+	Errors          map[string]string `json:"errors,omitempty"`
+	RestartRequired []string          `json:"restart_required,omitempty"` // 已修改但需要重启才能生效的配置
+	Duration        string            `json:"duration"`
+}
+
+// reloadMu 保证同一时间只有一个重新加载在执行
+var reloadMu sync.Mutex
+
+// reloadConfiguration 重新加载 .env、用户列表和各规则文件。
+// 每一项独立加载，失败时保留原配置；数据库、服务端口、日志等需要重启才能生效的配置只做提示
+func reloadConfiguration(trigger string) *ReloadResult {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	start := time.Now()
+	result := &ReloadResult{Trigger: trigger, Reloaded: []string{}, Errors: map[string]string{}}
+	fail := func(name string, err error) {
+		result.Errors[name] = err.Error()
+	}
+
+	// LoadConfig 会重新读取 .env 并覆盖对应的环境变量
+	newConfig := LoadConfig()
+
+	setEmailConfig(newConfig.Email)
+	result.Reloaded = append(result.Reloaded, "email")
+
+	if err := InitEmailTemplates(newConfig.Email.TemplateDir); err != nil {
+		fail("templates", err)
+	} else {
+		result.Reloaded = append(result.Reloaded, "templates")
+	}
+
+	if newConfig.Cron != currentCronConfig() {
+		if err := registerDigestJob(newConfig.Cron); err != nil {
+			fail("cron", err)
+		} else {
+			result.Reloaded = append(result.Reloaded, "cron")
+		}
+	}
+
+	if !reflect.DeepEqual(newConfig.Report, currentReportConfig()) {
+		if err := registerReportJobs(newConfig.Report); err != nil {
+			fail("report", err)
+		} else {
+			result.Reloaded = append(result.Reloaded, "report")
+		}
+	}
+
+	if importResult, err := syncUserListFile(config.Users.SeedFile); err != nil {
+		fail("users", err)
+	} else if importResult != nil {
+		result.Reloaded = append(result.Reloaded, "users")
+		LogSystem(logrus.InfoLevel, "reload", "用户列表文件已合并导入", map[string]interface{}{
+			"created": importResult.Created,
+			"updated": importResult.Updated,
+			"skipped": importResult.Skipped,
+		})
+	}
+
+	if err := InitFallback(newConfig.Fallback); err != nil {
+		fail("fallback", err)
+	} else {
+		result.Reloaded = append(result.Reloaded, "fallback")
+	}
+
+	loaders := []struct {
+		name string
+		load func() error
+	}{
+		{"routing", InitRouting},
+		{"inhibit_rules", InitInhibitRules},
+		{"teams", InitTeams},
+		{"escalation_policies", InitEscalationPolicies},
+	}
+	for _, loader := range loaders {
+		if err := loader.load(); err != nil {
+			fail(loader.name, err)
+			continue
+		}
+		result.Reloaded = append(result.Reloaded, loader.name)
+	}
+	startEscalationScheduler()
+
+	// 兜底规则文件和模板目录的路径可能随本次加载变化，监听目录随之调整
+	if activeConfigWatcher != nil {
+		activeConfigWatcher.update(newConfig)
+	}
+
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"database", config.Database, newConfig.Database},
+		{"server", config.Server, newConfig.Server},
+		{"log", config.Log, newConfig.Log},
+		{"ingest", config.Ingest, newConfig.Ingest},
+		{"routing_files", config.Routing, newConfig.Routing},
+		{"users_file", config.Users, newConfig.Users},
+		{"ldap", config.LDAP, newConfig.LDAP},
+	}
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.old, section.new) {
+			result.RestartRequired = append(result.RestartRequired, section.name)
+		}
+	}
+
+	if len(result.Errors) == 0 {
+		result.Errors = nil
+	}
+	result.Duration = time.Since(start).String()
+
+	level := logrus.InfoLevel
+	if result.Errors != nil {
+		level = logrus.ErrorLevel
+	}
+	LogSystem(level, "reload", "配置重新加载完成", map[string]interface{}{
+		"trigger":          result.Trigger,
+		"reloaded":         result.Reloaded,
+		"errors":           result.Errors,
+		"restart_required": result.RestartRequired,
+		"duration":         result.Duration,
+	})
+	log.Printf("配置重新加载完成（%s），已加载: %v", trigger, result.Reloaded)
+	return result
+}
+
+// configWatcher 配置文件监听器，重新加载后按新的文件路径调整监听的目录
+type configWatcher struct {
+	watcher     *fsnotify.Watcher
+	mu          sync.Mutex
+	files       map[string]bool
+	dirs        map[string]bool
+	templateDir string
+}
+
+// activeConfigWatcher 启用 CONFIG_WATCH 时的监听器，在 reloadMu 保护下创建和更新
+var activeConfigWatcher *configWatcher
+
+// update 按配置计算需要监听的文件和模板目录，添加新目录并移除不再需要的目录。
+// 兜底规则文件和模板目录使用 cfg 中的路径（重新加载时生效），其余文件修改路径需要重启，使用启动时的路径
+func (w *configWatcher) update(cfg *Config) {
+	// 监听文件所在目录，兼容编辑器先写临时文件再重命名的保存方式
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, path := range []string{envFile, config.Users.SeedFile, config.Routing.File,
+		config.Routing.InhibitFile, config.Routing.EscalationFile, config.Routing.TeamsFile, cfg.Fallback.RulesFile} {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		files[abs] = true
+		dirs[filepath.Dir(abs)] = true
+	}
+	// 邮件模板目录中任意 .html / .txt 文件变化都重新加载
+	templateDir, _ := filepath.Abs(cfg.Email.TemplateDir)
+	if info, err := os.Stat(templateDir); err == nil && info.IsDir() {
+		dirs[templateDir] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			LogSystem(logrus.WarnLevel, "reload", "监听配置目录失败", map[string]interface{}{
+				"dir":   dir,
+				"error": err.Error(),
+			})
+			delete(dirs, dir)
+		}
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			w.watcher.Remove(dir)
+		}
+	}
+	w.files, w.dirs, w.templateDir = files, dirs, templateDir
+}
+
+// matches 判断变化的文件是否需要触发重新加载
+func (w *configWatcher) matches(name string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ext := filepath.Ext(name)
+	isTemplate := filepath.Dir(name) == w.templateDir && (ext == ".html" || ext == ".txt")
+	return w.files[name] || isTemplate
+}
+
+// startReloadWatchers 监听 SIGHUP 信号，并在启用时监听配置文件变化自动重新加载
+func startReloadWatchers() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			reloadConfiguration("sighup")
+		}
+	}()
+
+	if !config.Server.ConfigWatch {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		LogSystem(logrus.ErrorLevel, "reload", "创建配置文件监听失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	w := &configWatcher{watcher: watcher}
+	reloadMu.Lock()
+	w.update(config)
+	activeConfigWatcher = w
+	dirCount := len(w.dirs)
+	reloadMu.Unlock()
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !w.matches(event.Name) || event.Op == fsnotify.Chmod {
+					continue
+				}
+				name := filepath.Base(event.Name)
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDebounce, func() {
+					reloadConfiguration("file:" + name)
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				LogSystem(logrus.WarnLevel, "reload", "配置文件监听出错", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
+	}()
+
+	LogSystem(logrus.InfoLevel, "reload", "配置文件监听已启动", map[string]interface{}{
+		"dirs": dirCount,
+	})
+}
+
+// ReloadHandler 手动重新加载配置
+func ReloadHandler(c *gin.Context) {
+	result := reloadConfiguration("api")
+	if result.Errors != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.reload_failed"),
+			"data":    result,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.reload_ok"),
+		"data":    result,
+	})
+}
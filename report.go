@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// 报表周期
+const (
+	ReportPeriodWeekly  = "weekly"
+	ReportPeriodMonthly = "monthly"
+)
+
+// 周报、月报定时任务名称
+const (
+	cronJobWeeklyReport  = "weekly_report"
+	cronJobMonthlyReport = "monthly_report"
+)
+
+// reportScheduleOff 报表cron表达式为该值时不注册对应任务
+const reportScheduleOff = "off"
+
+// ReportCount 报表排行中的一项，Change 为相对上期的变化，如 +25.0%，上期为 0 时为 -
+type ReportCount struct {
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+	Previous int    `json:"previous"`
+	Change   string `json:"change"`
+}
+
+// AlertReport 周报/月报内容。重复告警指上期出现过相同指纹（收件人、来源、去掉数字后的内容）的告警
+type AlertReport struct {
+	Period          string        `json:"period"`
+	StartTime       time.Time     `json:"start_time"`
+	EndTime         time.Time     `json:"end_time"` // 不含
+	StartDate       string        `json:"start_date"`
+	EndDate         string        `json:"end_date"` // 含，用于显示
+	TotalAlerts     int           `json:"total_alerts"`
+	PreviousTotal   int           `json:"previous_total"`
+	TotalChange     string        `json:"total_change"`
+	NewAlerts       int           `json:"new_alerts"`
+	RecurringAlerts int           `json:"recurring_alerts"`
+	Acknowledged    int           `json:"acknowledged"`
+	Resolved        int           `json:"resolved"`
+	MTTASeconds     int64         `json:"mtta_seconds"` // 平均确认耗时，没有已确认的告警时为 0
+	MTTRSeconds     int64         `json:"mttr_seconds"` // 平均恢复耗时，没有已恢复的告警时为 0
+	MTTA            string        `json:"mtta"`
+	MTTR            string        `json:"mttr"`
+	TopDomains      []ReportCount `json:"top_domains"`
+	Recipients      []ReportCount `json:"recipients"`
+}
+
+// validReportPeriod 校验报表周期
+func validReportPeriod(period string) bool {
+	return period == ReportPeriodWeekly || period == ReportPeriodMonthly
+}
+
+// reportPeriodRange 报表统计的时间范围和用于对比的上期范围，均为左闭右开。
+// 周报统计 now 所在周之前的完整一周（周一开始），月报统计上一个自然月
+func reportPeriodRange(period string, now time.Time) (start, end, prevStart, prevEnd time.Time) {
+	now = now.In(serverLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, serverLocation)
+	if period == ReportPeriodMonthly {
+		end = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, serverLocation)
+		start = end.AddDate(0, -1, 0)
+		return start, end, start.AddDate(0, -1, 0), start
+	}
+	end = today.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+	start = end.AddDate(0, 0, -7)
+	return start, end, start.AddDate(0, 0, -7), start
+}
+
+// BuildAlertReport 生成截至 now 的周报或月报，本期和上期的告警均通过 GetAlertsGroupedByRecipient 按收件人分组查询
+func BuildAlertReport(period string, now time.Time, topN int) (*AlertReport, error) {
+	start, end, prevStart, prevEnd := reportPeriodRange(period, now)
+
+	// GetAlertsGroupedByRecipient 的时间范围两端都包含，结束时间减去1秒避免统计到下一周期开始时刻的告警
+	current, err := GetAlertsGroupedByRecipient(start, end.Add(-time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("获取本期预警信息失败: %v", err)
+	}
+	previous, err := GetAlertsGroupedByRecipient(prevStart, prevEnd.Add(-time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("获取上期预警信息失败: %v", err)
+	}
+	return computeAlertReport(period, start, end, current, previous, topN), nil
+}
+
+// computeAlertReport 由本期和上期按收件人分组的告警计算报表
+func computeAlertReport(period string, start, end time.Time, current, previous []UserAlerts, topN int) *AlertReport {
+	report := &AlertReport{
+		Period:    period,
+		StartTime: start,
+		EndTime:   end,
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+
+	seen := map[string]bool{}
+	prevDomains := map[string]int{}
+	prevRecipients := map[string]int{}
+	for _, userAlerts := range previous {
+		prevRecipients[userAlerts.Recipient] += len(userAlerts.Alerts)
+		report.PreviousTotal += len(userAlerts.Alerts)
+		for i := range userAlerts.Alerts {
+			seen[alertFingerprint(&userAlerts.Alerts[i])] = true
+			prevDomains[userAlerts.Alerts[i].Domain]++
+		}
+	}
+
+	domains := map[string]int{}
+	recipients := map[string]int{}
+	var ackTotal, resolveTotal time.Duration
+	for _, userAlerts := range current {
+		recipients[userAlerts.Recipient] += len(userAlerts.Alerts)
+		for i := range userAlerts.Alerts {
+			alert := &userAlerts.Alerts[i]
+			report.TotalAlerts++
+			domains[alert.Domain]++
+			if seen[alertFingerprint(alert)] {
+				report.RecurringAlerts++
+			} else {
+				report.NewAlerts++
+			}
+			if alert.AcknowledgedAt != nil && !alert.AcknowledgedAt.Before(alert.AlertTime) {
+				report.Acknowledged++
+				ackTotal += alert.AcknowledgedAt.Sub(alert.AlertTime)
+			}
+			if alert.ResolvedAt != nil && !alert.ResolvedAt.Before(alert.AlertTime) {
+				report.Resolved++
+				resolveTotal += alert.ResolvedAt.Sub(alert.AlertTime)
+			}
+		}
+	}
+
+	report.TotalChange = formatReportChange(report.TotalAlerts, report.PreviousTotal)
+	if report.Acknowledged > 0 {
+		report.MTTASeconds = int64((ackTotal / time.Duration(report.Acknowledged)).Seconds())
+	}
+	if report.Resolved > 0 {
+		report.MTTRSeconds = int64((resolveTotal / time.Duration(report.Resolved)).Seconds())
+	}
+	report.MTTA = formatReportDuration(report.Acknowledged, report.MTTASeconds)
+	report.MTTR = formatReportDuration(report.Resolved, report.MTTRSeconds)
+	report.TopDomains = rankReportCounts(domains, prevDomains, topN)
+	report.Recipients = rankReportCounts(recipients, prevRecipients, topN)
+	return report
+}
+
+// rankReportCounts 按本期数量降序（相同时按名称）取前 topN 项，topN 不大于 0 时不限制
+func rankReportCounts(counts, previous map[string]int, topN int) []ReportCount {
+	items := make([]ReportCount, 0, len(counts))
+	for name, count := range counts {
+		items = append(items, ReportCount{
+			Name:     name,
+			Count:    count,
+			Previous: previous[name],
+			Change:   formatReportChange(count, previous[name]),
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Name < items[j].Name
+	})
+	if topN > 0 && len(items) > topN {
+		items = items[:topN]
+	}
+	return items
+}
+
+// formatReportChange 相对上期的变化百分比，上期为 0 时无法计算
+func formatReportChange(current, previous int) string {
+	if previous == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", float64(current-previous)*100/float64(previous))
+}
+
+// formatReportDuration 平均耗时，精确到秒，如 1h23m5s
+func formatReportDuration(count int, seconds int64) string {
+	if count == 0 {
+		return "-"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// reportRecipients 报表收件人：REPORT_RECIPIENTS，未配置时为各团队负责人
+func reportRecipients(cfg ReportConfig) []string {
+	if len(cfg.Recipients) > 0 {
+		return cfg.Recipients
+	}
+
+	seen := map[string]bool{}
+	var leads []string
+	teamsMu.RLock()
+	for _, team := range teams {
+		if team.Lead != "" && !seen[team.Lead] {
+			seen[team.Lead] = true
+			leads = append(leads, team.Lead)
+		}
+	}
+	teamsMu.RUnlock()
+	sort.Strings(leads)
+	return leads
+}
+
+// reportEmailData 报表邮件模板数据
+type reportEmailData struct {
+	GenerateTime string
+	Monthly      bool
+	Report       *AlertReport
+	Lang         string
+}
+
+// T 按收件人语言格式化消息，供模板使用
+func (d reportEmailData) T(key string, args ...interface{}) (string, error) {
+	return templateMessage(d.Lang, key, args...)
+}
+
+// generateReportEmail 按收件人语言和邮件格式偏好生成报表邮件
+func generateReportEmail(report *AlertReport, recipient string) (string, emailBody, error) {
+	pref := preferencesFor(recipient)
+	data := reportEmailData{
+		GenerateTime: formatDisplayTime(time.Now()),
+		Monthly:      report.Period == ReportPeriodMonthly,
+		Report:       report,
+		Lang:         pref.locale(),
+	}
+
+	subject := T(data.Lang, "email.report.subject_weekly", report.StartDate, report.EndDate)
+	if data.Monthly {
+		subject = T(data.Lang, "email.report.subject_monthly", report.StartTime.Format("2006-01"))
+	}
+
+	text, err := renderTextTemplate(templateReportEmail, nil, data)
+	if err != nil {
+		return "", emailBody{}, fmt.Errorf("生成报表邮件内容失败: %v", err)
+	}
+	if pref != nil && pref.EmailFormat == EmailFormatText {
+		return subject, emailBody{Text: text}, nil
+	}
+	html, err := renderEmailTemplate(templateReportEmail, nil, data)
+	if err != nil {
+		return "", emailBody{}, fmt.Errorf("生成报表邮件内容失败: %v", err)
+	}
+	return subject, emailBody{HTML: html, Text: text}, nil
+}
+
+// sendAlertReport 逐个收件人发送报表邮件，无法解析邮箱的收件人记为失败
+func sendAlertReport(ctx context.Context, report *AlertReport, recipients []string) *SendResult {
+	result := &SendResult{}
+	for _, recipient := range recipients {
+		recipientInfo := generateRecipientEmail(recipient)
+		item := RecipientSendResult{
+			Recipient:  recipient,
+			Email:      recipientInfo.Email,
+			Found:      recipientInfo.Found,
+			Channel:    ChannelEmail,
+			AlertCount: report.TotalAlerts,
+		}
+		if !recipientInfo.Found {
+			result.add(item, nil, fmt.Errorf("未找到收件人 %s 的邮箱", recipient))
+			continue
+		}
+
+		subject, body, err := generateReportEmail(report, recipient)
+		if err != nil {
+			result.add(item, nil, err)
+			continue
+		}
+		gateway, err := sendEmailViaAPI(ctx, []string{recipientInfo.Email}, subject, body)
+		if err != nil {
+			LogEmail(recipientInfo.Email, subject, false, err.Error())
+			result.add(item, gateway, fmt.Errorf("发送报表邮件失败: %v", err))
+			continue
+		}
+		LogEmail(recipientInfo.Email, subject, true, "")
+		result.add(item, gateway, nil)
+	}
+	return result
+}
+
+// runReportJob 生成并发送周报或月报，执行结果保存在定时任务执行记录中
+func runReportJob(ctx context.Context, period string, cfg ReportConfig) *CronRun {
+	jobName := cronJobWeeklyReport
+	if period == ReportPeriodMonthly {
+		jobName = cronJobMonthlyReport
+	}
+	run := startCronRun(jobName)
+	LogCronJob(jobName, true, "定时任务开始执行", "")
+
+	recipients := reportRecipients(cfg)
+	if len(recipients) == 0 {
+		run.finish(false, "未配置报表收件人（REPORT_RECIPIENTS）且团队配置中没有负责人", nil)
+		return run
+	}
+
+	report, err := BuildAlertReport(period, time.Now(), cfg.TopN)
+	if err != nil {
+		run.finish(false, "生成报表失败: "+err.Error(), nil)
+		return run
+	}
+
+	ctx, cancel := withSendDeadline(ctx)
+	defer cancel()
+	result := sendAlertReport(ctx, report, recipients)
+	if failed := len(result.Failed()); failed > 0 {
+		run.finish(false, fmt.Sprintf("报表邮件发送失败 %d 个，成功 %d 个", failed, result.SuccessCount()), result)
+	} else {
+		run.finish(true, fmt.Sprintf("成功发送报表邮件，统计 %s 至 %s 共 %d 条预警，涉及 %d 个收件人",
+			report.StartDate, report.EndDate, report.TotalAlerts, len(recipients)), result)
+	}
+	return run
+}
+
+// 周报/月报定时任务，与汇总邮件共用调度器，配置重新加载时重新注册
+var (
+	reportMu       sync.Mutex
+	reportEntryIDs []cron.EntryID
+	activeReport   ReportConfig
+)
+
+// registerReportJobs 按配置（重新）注册周报和月报任务，cron表达式无效时保留原任务
+func registerReportJobs(cfg ReportConfig) error {
+	schedules := map[string]string{}
+	if cfg.Enabled {
+		for period, schedule := range map[string]string{
+			ReportPeriodWeekly:  cfg.WeeklySchedule,
+			ReportPeriodMonthly: cfg.MonthlySchedule,
+		} {
+			if schedule == reportScheduleOff {
+				continue
+			}
+			if _, err := cron.ParseStandard(schedule); err != nil {
+				return fmt.Errorf("报表cron表达式 %q 无效: %v", schedule, err)
+			}
+			schedules[period] = schedule
+		}
+	}
+
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	for _, id := range reportEntryIDs {
+		digestCron.Remove(id)
+	}
+	reportEntryIDs = nil
+	activeReport = cfg
+
+	if !cfg.Enabled {
+		LogSystem(logrus.InfoLevel, "cron", "周报/月报已禁用", nil)
+		return nil
+	}
+
+	for period, schedule := range schedules {
+		period := period
+		id, err := digestCron.AddFunc(schedule, func() {
+			runReportJob(context.Background(), period, currentReportConfig())
+		})
+		if err != nil {
+			return err
+		}
+		reportEntryIDs = append(reportEntryIDs, id)
+	}
+
+	LogSystem(logrus.InfoLevel, "cron", "周报/月报任务已启动", map[string]interface{}{
+		"weekly_schedule":  cfg.WeeklySchedule,
+		"monthly_schedule": cfg.MonthlySchedule,
+		"recipients":       reportRecipients(cfg),
+	})
+	return nil
+}
+
+// currentReportConfig 获取当前生效的报表配置
+func currentReportConfig() ReportConfig {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	return activeReport
+}
+
+// GetAlertReportHandler 预览周报或月报内容（不发送），at 指定生成时间，默认为当前时间
+func GetAlertReportHandler(c *gin.Context) {
+	period := c.Param("period")
+	if !validReportPeriod(period) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.report_period_invalid"),
+		})
+		return
+	}
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		var err error
+		if at, err = parseFlexibleTime(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_time"),
+			})
+			return
+		}
+	}
+
+	report, err := BuildAlertReport(period, at, currentReportConfig().TopN)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.build_report_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.build_report_ok"),
+		"data":    report,
+	})
+}
+
+// SendAlertReportHandler 立即生成并发送周报或月报，执行结果同样记录在定时任务执行历史中
+func SendAlertReportHandler(c *gin.Context) {
+	period := c.Param("period")
+	if !validReportPeriod(period) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.report_period_invalid"),
+		})
+		return
+	}
+
+	run := runReportJob(c.Request.Context(), period, currentReportConfig())
+	if !run.Success {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.send_report_failed", run.Message),
+			"data":    run,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.send_report_ok"),
+		"data":    run,
+	})
+}
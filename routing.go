@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 通知时机
+const (
+	ScheduleDigest    = "digest"    // 随定时汇总邮件发送
+	ScheduleImmediate = "immediate" // 写入后立即发送
+)
+
+// ChannelEmail 邮件通知渠道
+const ChannelEmail = "email"
+
+// RouteMatch 路由匹配条件，空字段表示不限制，支持 * 通配符
+type RouteMatch struct {
+	Source   string            `json:"source,omitempty"`
+	Domain   string            `json:"domain,omitempty"`
+	Severity string            `json:"severity,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// Route 路由规则树节点。子节点未设置的字段继承父节点
+type Route struct {
+	Name       string     `json:"name"`
+	Match      RouteMatch `json:"match"`
+	Team       string     `json:"team,omitempty"`
+	Recipients []string   `json:"recipients,omitempty"` // 未指定收件人的告警将发送给这些人，为空且设置了 team 时发给 team:<团队>
+	Channel    string     `json:"channel,omitempty"`
+	Schedule   string     `json:"schedule,omitempty"`
+	GroupBy    []string   `json:"group_by,omitempty"` // 汇总邮件的分组字段：source、domain、severity 或标签名
+	Continue   bool       `json:"continue,omitempty"` // 匹配后是否继续匹配后续兄弟节点
+	Routes     []*Route   `json:"routes,omitempty"`
+}
+
+// RouteResult 告警的路由结果
+type RouteResult struct {
+	Route      string   `json:"route"`
+	Path       []string `json:"path"`
+	Team       string   `json:"team"`
+	Recipients []string `json:"recipients"`
+	Channel    string   `json:"channel"`
+	Schedule   string   `json:"schedule"`
+	GroupBy    []string `json:"group_by"`
+}
+
+// RoutingTestRequest 路由测试请求
+type RoutingTestRequest struct {
+	Message   string            `json:"message"`
+	Recipient string            `json:"recipient"`
+	Source    string            `json:"source"`
+	Domain    string            `json:"domain"`
+	Severity  string            `json:"severity"`
+	Labels    map[string]string `json:"labels"`
+}
+
+var (
+	routingMu   sync.RWMutex
+	routingTree *Route
+)
+
+// defaultRoutingTree 未配置路由文件时的默认路由：按调用方指定的收件人、随汇总邮件发送
+func defaultRoutingTree() *Route {
+	return &Route{Name: "default", Channel: ChannelEmail, Schedule: ScheduleDigest}
+}
+
+// InitRouting 加载路由规则
+func InitRouting() error {
+	tree, err := loadRoutingFile(config.Routing.File)
+	if err != nil {
+		return err
+	}
+
+	routingMu.Lock()
+	routingTree = tree
+	routingMu.Unlock()
+	return nil
+}
+
+// loadRoutingFile 读取并校验路由规则文件，文件不存在时使用默认路由
+func loadRoutingFile(path string) (*Route, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		LogSystem(logrus.InfoLevel, "routing", "未找到路由规则文件，使用默认路由", map[string]interface{}{
+			"file": path,
+		})
+		return defaultRoutingTree(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取路由规则文件失败: %v", err)
+	}
+
+	var tree Route
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("解析路由规则文件失败: %v", err)
+	}
+	if tree.Name == "" {
+		tree.Name = "root"
+	}
+	if tree.Channel == "" {
+		tree.Channel = ChannelEmail
+	}
+	if tree.Schedule == "" {
+		tree.Schedule = ScheduleDigest
+	}
+	if err := validateRoute(&tree, tree.Name); err != nil {
+		return nil, err
+	}
+
+	LogSystem(logrus.InfoLevel, "routing", "路由规则加载成功", map[string]interface{}{
+		"file":        path,
+		"route_count": countRoutes(&tree),
+	})
+	log.Printf("路由规则加载成功: %s", path)
+	return &tree, nil
+}
+
+// validateRoute 递归校验路由节点
+func validateRoute(route *Route, path string) error {
+	if route.Channel != "" && route.Channel != ChannelEmail {
+		return fmt.Errorf("路由 %s 的通知渠道 %s 不受支持", path, route.Channel)
+	}
+	if route.Schedule != "" && route.Schedule != ScheduleDigest && route.Schedule != ScheduleImmediate {
+		return fmt.Errorf("路由 %s 的通知时机 %s 无效，可选 digest 或 immediate", path, route.Schedule)
+	}
+	for i, child := range route.Routes {
+		if child.Name == "" {
+			child.Name = fmt.Sprintf("%s.%d", route.Name, i)
+		}
+		if err := validateRoute(child, path+"/"+child.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countRoutes 统计路由节点数量
+func countRoutes(route *Route) int {
+	count := 1
+	for _, child := range route.Routes {
+		count += countRoutes(child)
+	}
+	return count
+}
+
+// currentRoutingTree 获取当前路由规则
+func currentRoutingTree() *Route {
+	routingMu.RLock()
+	defer routingMu.RUnlock()
+	if routingTree == nil {
+		return defaultRoutingTree()
+	}
+	return routingTree
+}
+
+// matches 判断告警是否满足节点的匹配条件
+func (r *Route) matches(alert *Alert) bool {
+	return r.Match.Matches(alert)
+}
+
+// Matches 判断告警是否满足匹配条件
+func (m RouteMatch) Matches(alert *Alert) bool {
+	if !globMatch(m.Source, alert.Source) || !globMatch(m.Domain, alert.Domain) || !globMatch(m.Severity, alert.Severity) {
+		return false
+	}
+	for key, pattern := range m.Labels {
+		if !globMatch(pattern, alert.Labels[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluate 深度优先匹配路由树，返回所有命中的叶子路由
+func (r *Route) evaluate(alert *Alert, parent RouteResult) []RouteResult {
+	if !r.matches(alert) {
+		return nil
+	}
+
+	current := RouteResult{
+		Route:      r.Name,
+		Path:       append(append([]string{}, parent.Path...), r.Name),
+		Team:       parent.Team,
+		Recipients: parent.Recipients,
+		Channel:    parent.Channel,
+		Schedule:   parent.Schedule,
+		GroupBy:    parent.GroupBy,
+	}
+	if r.Team != "" {
+		// 只指定团队、未指定收件人的路由发给该团队（team:<团队>），不再继承父节点的收件人
+		current.Team = r.Team
+		current.Recipients = []string{RecipientPrefixTeam + r.Team}
+	}
+	if len(r.Recipients) > 0 {
+		current.Recipients = r.Recipients
+	}
+	if r.Channel != "" {
+		current.Channel = r.Channel
+	}
+	if r.Schedule != "" {
+		current.Schedule = r.Schedule
+	}
+	if len(r.GroupBy) > 0 {
+		current.GroupBy = r.GroupBy
+	}
+
+	var results []RouteResult
+	for _, child := range r.Routes {
+		childResults := child.evaluate(alert, current)
+		if len(childResults) == 0 {
+			continue
+		}
+		results = append(results, childResults...)
+		if !child.Continue {
+			break
+		}
+	}
+
+	if len(results) == 0 {
+		results = []RouteResult{current}
+	}
+	return results
+}
+
+// RouteAlert 计算告警的路由结果，至少返回一条（根路由）
+func RouteAlert(alert *Alert) []RouteResult {
+	tree := currentRoutingTree()
+	results := tree.evaluate(alert, RouteResult{})
+	if len(results) == 0 {
+		// 根节点的匹配条件不满足时仍按根节点配置处理
+		results = []RouteResult{{
+			Route:      tree.Name,
+			Path:       []string{tree.Name},
+			Team:       tree.Team,
+			Recipients: tree.Recipients,
+			Channel:    tree.Channel,
+			Schedule:   tree.Schedule,
+			GroupBy:    tree.GroupBy,
+		}}
+	}
+	return results
+}
+
+// routeRecipients 汇总路由结果中的收件人（去重，保持顺序）
+func routeRecipients(results []RouteResult) []string {
+	seen := make(map[string]bool)
+	var recipients []string
+	for _, result := range results {
+		for _, recipient := range result.Recipients {
+			if !seen[recipient] {
+				seen[recipient] = true
+				recipients = append(recipients, recipient)
+			}
+		}
+	}
+	return recipients
+}
+
+// isImmediate 路由结果中是否包含立即通知
+func isImmediate(results []RouteResult) bool {
+	for _, result := range results {
+		if result.Schedule == ScheduleImmediate {
+			return true
+		}
+	}
+	return false
+}
+
+// groupValue 获取告警在分组字段上的取值
+func groupValue(alert *Alert, field string) string {
+	switch field {
+	case "source":
+		return alert.Source
+	case "domain":
+		return alert.Domain
+	case "severity":
+		return alert.Severity
+	case "recipient":
+		return alert.Recipient
+	default:
+		return alert.Labels[field]
+	}
+}
+
+// routeGroupKey 告警在汇总邮件中所在分组的 key，由第一条命中路由的分组字段组成
+func routeGroupKey(alert *Alert, routes []RouteResult) string {
+	var parts []string
+	for _, field := range routes[0].GroupBy {
+		parts = append(parts, field+"="+groupValue(alert, field))
+	}
+	return strings.Join(parts, ",")
+}
+
+// applyDigestRouting 在汇总邮件发送前应用路由：跳过已立即发送成功的告警，并按路由的分组字段拆分邮件。
+// 立即通知失败（未记录 notified_at）的告警随汇总邮件补发；userAlertsList 中的团队收件人需已展开为具体人员
+func applyDigestRouting(userAlertsList []UserAlerts) []UserAlerts {
+	var result []UserAlerts
+	skipped, resent := 0, 0
+
+	for _, userAlerts := range userAlertsList {
+		groups := make(map[string][]Alert)
+		var groupKeys []string
+
+		for _, alert := range userAlerts.Alerts {
+			routes := RouteAlert(&alert)
+			if sentImmediately(userAlerts.Recipient, &alert, routes) {
+				if alert.NotifiedAt != nil {
+					skipped++
+					continue
+				}
+				resent++
+			}
+
+			key := routeGroupKey(&alert, routes)
+			if _, ok := groups[key]; !ok {
+				groupKeys = append(groupKeys, key)
+			}
+			groups[key] = append(groups[key], alert)
+		}
+
+		sort.Strings(groupKeys)
+		for _, key := range groupKeys {
+			group := UserAlerts{Recipient: userAlerts.Recipient, Alerts: groups[key]}
+			// 被抑制的告警只附在该收件人的第一封邮件中
+			if len(result) == 0 || result[len(result)-1].Recipient != userAlerts.Recipient {
+				group.Inhibited = userAlerts.Inhibited
+			}
+			result = append(result, group)
+		}
+	}
+
+	if skipped > 0 {
+		LogSystem(logrus.InfoLevel, "routing", "已跳过立即发送的告警", map[string]interface{}{
+			"skipped_count": skipped,
+		})
+	}
+	if resent > 0 {
+		LogSystem(logrus.WarnLevel, "routing", "立即通知未成功的告警将随汇总邮件补发", map[string]interface{}{
+			"resent_count": resent,
+		})
+	}
+	return result
+}
+
+// notifyImmediateAlerts 发送路由或用户偏好为立即通知的告警，已静默或处于免打扰时段的告警不发送。
+// 团队收件人先展开为具体人员，按每个人员的偏好判断并分别发送；
+// 告警发给的所有人员都发送成功后记录 notified_at，否则留给汇总任务补发
+func notifyImmediateAlerts(alerts []Alert) {
+	grouped := make(map[string][]Alert)
+	var recipients []string
+	for _, alert := range alerts {
+		if alert.Silenced {
+			continue
+		}
+		if _, ok := grouped[alert.Recipient]; !ok {
+			recipients = append(recipients, alert.Recipient)
+		}
+		grouped[alert.Recipient] = append(grouped[alert.Recipient], alert)
+	}
+
+	var userAlertsList []UserAlerts
+	for _, recipient := range recipients {
+		userAlertsList = append(userAlertsList, UserAlerts{Recipient: recipient, Alerts: grouped[recipient]})
+	}
+
+	var immediate []UserAlerts
+	for _, userAlerts := range expandTeamRecipients(userAlertsList) {
+		var selected []Alert
+		for _, alert := range userAlerts.Alerts {
+			if sentImmediately(userAlerts.Recipient, &alert, RouteAlert(&alert)) {
+				selected = append(selected, alert)
+			}
+		}
+		if len(selected) > 0 {
+			immediate = append(immediate, UserAlerts{Recipient: userAlerts.Recipient, Alerts: selected})
+		}
+	}
+	if len(immediate) == 0 {
+		return
+	}
+
+	go func() {
+		failed := make([]bool, len(immediate))
+		runParallel(context.Background(), len(immediate), sendWorkers(), func(ctx context.Context, i int) {
+			if _, err := dispatchNotifications(ctx, []UserAlerts{immediate[i]}); err != nil {
+				failed[i] = true
+				LogSystem(logrus.ErrorLevel, "routing", "立即通知发送失败，将随汇总邮件补发", map[string]interface{}{
+					"recipient": immediate[i].Recipient,
+					"error":     err.Error(),
+				})
+			}
+		})
+
+		failedIDs := make(map[int]bool)
+		for i, userAlerts := range immediate {
+			if failed[i] {
+				for _, alert := range userAlerts.Alerts {
+					failedIDs[alert.ID] = true
+				}
+			}
+		}
+		var ids []int
+		seen := make(map[int]bool)
+		for _, userAlerts := range immediate {
+			for _, alert := range userAlerts.Alerts {
+				if !failedIDs[alert.ID] && !seen[alert.ID] {
+					seen[alert.ID] = true
+					ids = append(ids, alert.ID)
+				}
+			}
+		}
+		if err := MarkAlertsNotified(ids); err != nil {
+			LogSystem(logrus.ErrorLevel, "routing", "记录告警通知状态失败", map[string]interface{}{
+				"alert_count": len(ids),
+				"error":       err.Error(),
+			})
+		}
+	}()
+}
+
+// GetRoutingHandler 查看当前路由规则
+func GetRoutingHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_routing_ok"),
+		"data":    currentRoutingTree(),
+		"file":    config.Routing.File,
+	})
+}
+
+// TestRoutingHandler 测试示例告警会命中哪条路由
+func TestRoutingHandler(c *gin.Context) {
+	var req RoutingTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+
+	alert := &Alert{
+		Message:   req.Message,
+		Recipient: req.Recipient,
+		Source:    req.Source,
+		Domain:    req.Domain,
+		Severity:  req.Severity,
+		Labels:    req.Labels,
+	}
+	if alert.Domain == "" {
+		alert.Domain = extractDomain(req.Message)
+	}
+	if alert.Severity == "" {
+		alert.Severity = "warning"
+	}
+
+	routes := RouteAlert(alert)
+	recipients := parseRecipients(req.Recipient)
+	if len(recipients) == 0 {
+		recipients = routeRecipients(routes)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.test_routing_ok"),
+		"data": gin.H{
+			"alert":      alert,
+			"routes":     routes,
+			"recipients": recipients,
+		},
+	})
+}
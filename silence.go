@@ -0,0 +1,579 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SilenceMatchers 静默匹配条件，空字段表示不限制。
+// recipient/source/domain 支持 * 通配符，如 *.kgidc.cn
+type SilenceMatchers struct {
+	Recipient    string `json:"recipient,omitempty"`
+	Source       string `json:"source,omitempty"`
+	Domain       string `json:"domain,omitempty"`
+	MessageRegex string `json:"message_regex,omitempty"`
+	Severity     string `json:"severity,omitempty"`
+}
+
+// Silence 静默规则（维护窗口），匹配的告警照常存储但不发送通知
+type Silence struct {
+	ID        int             `json:"id"`
+	Matchers  SilenceMatchers `json:"matchers"`
+	StartsAt  time.Time       `json:"starts_at"`
+	EndsAt    time.Time       `json:"ends_at"`
+	CreatedBy string          `json:"created_by"`
+	Comment   string          `json:"comment"`
+	State     string          `json:"state"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+
+	messageRegex *regexp.Regexp
+}
+
+// SilenceRequest 创建/更新静默规则请求
+type SilenceRequest struct {
+	Matchers  SilenceMatchers `json:"matchers"`
+	StartsAt  string          `json:"starts_at"`
+	EndsAt    string          `json:"ends_at" binding:"required"`
+	CreatedBy string          `json:"created_by" binding:"required"`
+	Comment   string          `json:"comment"`
+}
+
+// 静默规则状态
+const (
+	SilenceStatePending = "pending"
+	SilenceStateActive  = "active"
+	SilenceStateExpired = "expired"
+)
+
+// createSilenceTable 创建静默规则表
+func createSilenceTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS silences (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		recipient VARCHAR(255) NOT NULL DEFAULT '',
+		source VARCHAR(100) NOT NULL DEFAULT '',
+		domain VARCHAR(255) NOT NULL DEFAULT '',
+		message_regex VARCHAR(1024) NOT NULL DEFAULT '',
+		severity VARCHAR(20) NOT NULL DEFAULT '',
+		starts_at DATETIME NOT NULL,
+		ends_at DATETIME NOT NULL,
+		created_by VARCHAR(100) NOT NULL,
+		comment TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_ends_at (ends_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+const silenceColumns = "id, recipient, source, domain, message_regex, severity, starts_at, ends_at, created_by, comment, created_at, updated_at"
+
+// scanSilence 扫描一行静默规则
+func scanSilence(scanner rowScanner) (Silence, error) {
+	var silence Silence
+	var comment sql.NullString
+	err := scanner.Scan(&silence.ID, &silence.Matchers.Recipient, &silence.Matchers.Source,
+		&silence.Matchers.Domain, &silence.Matchers.MessageRegex, &silence.Matchers.Severity,
+		&silence.StartsAt, &silence.EndsAt, &silence.CreatedBy, &comment,
+		&silence.CreatedAt, &silence.UpdatedAt)
+	silence.Comment = comment.String
+	silence.State = silence.stateAt(time.Now())
+	return silence, err
+}
+
+// querySilences 查询静默规则
+func querySilences(query string, args ...interface{}) ([]Silence, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		LogDatabase("SELECT", "silences", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询静默规则失败: %v", err)
+	}
+	defer rows.Close()
+
+	silences := []Silence{}
+	for rows.Next() {
+		silence, err := scanSilence(rows)
+		if err != nil {
+			LogDatabase("SELECT", "silences", false, err.Error(), 0)
+			return nil, fmt.Errorf("扫描静默规则失败: %v", err)
+		}
+		silences = append(silences, silence)
+	}
+
+	LogDatabase("SELECT", "silences", true, "", int64(len(silences)))
+	return silences, nil
+}
+
+// GetSilences 获取静默规则，state 为空时返回全部
+func GetSilences(state string) ([]Silence, error) {
+	now := time.Now()
+	switch state {
+	case SilenceStateActive:
+		return querySilences(`SELECT `+silenceColumns+` FROM silences WHERE starts_at <= ? AND ends_at > ? ORDER BY ends_at`, now, now)
+	case SilenceStatePending:
+		return querySilences(`SELECT `+silenceColumns+` FROM silences WHERE starts_at > ? ORDER BY starts_at`, now)
+	case SilenceStateExpired:
+		return querySilences(`SELECT `+silenceColumns+` FROM silences WHERE ends_at <= ? ORDER BY ends_at DESC`, now)
+	default:
+		return querySilences(`SELECT ` + silenceColumns + ` FROM silences ORDER BY id DESC`)
+	}
+}
+
+// GetSilenceByID 根据ID获取静默规则
+func GetSilenceByID(id int) (*Silence, error) {
+	row := db.QueryRow(`SELECT `+silenceColumns+` FROM silences WHERE id = ?`, id)
+	silence, err := scanSilence(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询静默规则失败: %v", err)
+	}
+	return &silence, nil
+}
+
+// InsertSilence 新增静默规则
+func InsertSilence(silence *Silence) error {
+	result, err := db.Exec(`INSERT INTO silences (recipient, source, domain, message_regex, severity, starts_at, ends_at, created_by, comment)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		silence.Matchers.Recipient, silence.Matchers.Source, silence.Matchers.Domain,
+		silence.Matchers.MessageRegex, silence.Matchers.Severity,
+		silence.StartsAt, silence.EndsAt, silence.CreatedBy, silence.Comment)
+	if err != nil {
+		LogDatabase("INSERT", "silences", false, err.Error(), 0)
+		return fmt.Errorf("新增静默规则失败: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("获取静默规则ID失败: %v", err)
+	}
+	silence.ID = int(id)
+	LogDatabase("INSERT", "silences", true, "", 1)
+	return nil
+}
+
+// UpdateSilence 更新静默规则
+func UpdateSilence(silence *Silence) error {
+	_, err := db.Exec(`UPDATE silences SET recipient = ?, source = ?, domain = ?, message_regex = ?, severity = ?,
+		starts_at = ?, ends_at = ?, created_by = ?, comment = ? WHERE id = ?`,
+		silence.Matchers.Recipient, silence.Matchers.Source, silence.Matchers.Domain,
+		silence.Matchers.MessageRegex, silence.Matchers.Severity,
+		silence.StartsAt, silence.EndsAt, silence.CreatedBy, silence.Comment, silence.ID)
+	if err != nil {
+		LogDatabase("UPDATE", "silences", false, err.Error(), 0)
+		return fmt.Errorf("更新静默规则失败: %v", err)
+	}
+	LogDatabase("UPDATE", "silences", true, "", 1)
+	return nil
+}
+
+// ExpireSilence 立即结束静默规则
+func ExpireSilence(id int) error {
+	_, err := db.Exec(`UPDATE silences SET ends_at = ? WHERE id = ? AND ends_at > ?`, time.Now(), id, time.Now())
+	if err != nil {
+		LogDatabase("UPDATE", "silences", false, err.Error(), 0)
+		return fmt.Errorf("结束静默规则失败: %v", err)
+	}
+	LogDatabase("UPDATE", "silences", true, "", 1)
+	return nil
+}
+
+// stateAt 计算静默规则在指定时间的状态
+func (s *Silence) stateAt(t time.Time) string {
+	switch {
+	case t.Before(s.StartsAt):
+		return SilenceStatePending
+	case t.Before(s.EndsAt):
+		return SilenceStateActive
+	default:
+		return SilenceStateExpired
+	}
+}
+
+// silenceError 静默规则校验失败时消息目录中的错误消息
+type silenceError struct {
+	Key  string
+	Args []interface{}
+}
+
+func (e *silenceError) Error() string { return T(defaultLocale, e.Key, e.Args...) }
+
+// compile 编译消息正则，失败时返回 *silenceError
+func (s *Silence) compile() error {
+	if s.Matchers.MessageRegex == "" {
+		s.messageRegex = nil
+		return nil
+	}
+	re, err := regexp.Compile(s.Matchers.MessageRegex)
+	if err != nil {
+		return &silenceError{Key: "api.silence_invalid_regex", Args: []interface{}{err}}
+	}
+	s.messageRegex = re
+	return nil
+}
+
+// Matches 判断告警是否匹配静默条件
+func (s *Silence) Matches(alert *Alert) bool {
+	m := s.Matchers
+	if !globMatch(m.Recipient, alert.Recipient) || !globMatch(m.Source, alert.Source) || !globMatch(m.Domain, alert.Domain) {
+		return false
+	}
+	if m.Severity != "" && !strings.EqualFold(m.Severity, alert.Severity) {
+		return false
+	}
+	if s.messageRegex != nil && !s.messageRegex.MatchString(alert.Message) {
+		return false
+	}
+	return true
+}
+
+// globMatch 通配符匹配，pattern 为空表示匹配任意值
+func globMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && matched
+}
+
+// loadActiveSilences 加载当前生效的静默规则并编译正则
+func loadActiveSilences() ([]Silence, error) {
+	silences, err := GetSilences(SilenceStateActive)
+	if err != nil {
+		return nil, err
+	}
+	for i := range silences {
+		if err := silences[i].compile(); err != nil {
+			LogSystem(logrus.WarnLevel, "silence", "静默规则正则无效，已跳过消息匹配", map[string]interface{}{
+				"silence_id": silences[i].ID,
+				"error":      err.Error(),
+			})
+		}
+	}
+	return silences, nil
+}
+
+// findMatchingSilence 返回第一条匹配告警的静默规则
+func findMatchingSilence(silences []Silence, alert *Alert) *Silence {
+	for i := range silences {
+		if silences[i].Matches(alert) {
+			return &silences[i]
+		}
+	}
+	return nil
+}
+
+// applyIngestSilences 告警写入时标记命中的静默规则
+func applyIngestSilences(alerts []*Alert) {
+	silences, err := loadActiveSilences()
+	if err != nil {
+		LogSystem(logrus.WarnLevel, "silence", "加载静默规则失败，告警按未静默处理", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	for _, alert := range alerts {
+		if silence := findMatchingSilence(silences, alert); silence != nil {
+			alert.SilenceID = silence.ID
+			alert.Silenced = true
+		}
+	}
+}
+
+// filterSilencedAlerts 发送通知前过滤被静默的告警：写入时已被静默的，以及命中当前生效静默规则的。
+// 返回需要通知的告警分组和被过滤的告警数量
+func filterSilencedAlerts(userAlertsList []UserAlerts) ([]UserAlerts, int) {
+	silences, err := loadActiveSilences()
+	if err != nil {
+		LogSystem(logrus.WarnLevel, "silence", "加载静默规则失败，仅过滤已标记的静默告警", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	var result []UserAlerts
+	silencedCount := 0
+	newlySilenced := make(map[int][]int)
+
+	for _, userAlerts := range userAlertsList {
+		var kept []Alert
+		for _, alert := range userAlerts.Alerts {
+			if alert.Silenced {
+				silencedCount++
+				continue
+			}
+			if silence := findMatchingSilence(silences, &alert); silence != nil {
+				newlySilenced[silence.ID] = append(newlySilenced[silence.ID], alert.ID)
+				silencedCount++
+				continue
+			}
+			kept = append(kept, alert)
+		}
+		if len(kept) > 0 {
+			result = append(result, UserAlerts{Recipient: userAlerts.Recipient, Alerts: kept, Inhibited: userAlerts.Inhibited})
+		}
+	}
+
+	for silenceID, alertIDs := range newlySilenced {
+		if err := MarkAlertsSilenced(silenceID, alertIDs); err != nil {
+			LogSystem(logrus.WarnLevel, "silence", "标记静默告警失败", map[string]interface{}{
+				"silence_id": silenceID,
+				"error":      err.Error(),
+			})
+		}
+	}
+
+	if silencedCount > 0 {
+		LogSystem(logrus.InfoLevel, "silence", "已过滤静默告警", map[string]interface{}{
+			"silenced_count": silencedCount,
+		})
+	}
+	return result, silencedCount
+}
+
+// buildSilence 校验请求并生成静默规则，校验失败时返回 *silenceError
+func buildSilence(req SilenceRequest) (*Silence, error) {
+	m := req.Matchers
+	if m.Recipient == "" && m.Source == "" && m.Domain == "" && m.MessageRegex == "" && m.Severity == "" {
+		return nil, &silenceError{Key: "api.silence_matcher_required"}
+	}
+
+	startsAt := time.Now()
+	if req.StartsAt != "" {
+		t, err := parseFlexibleTime(req.StartsAt)
+		if err != nil {
+			return nil, &silenceError{Key: "api.silence_invalid_starts_at", Args: []interface{}{err}}
+		}
+		startsAt = t
+	}
+	endsAt, err := parseFlexibleTime(req.EndsAt)
+	if err != nil {
+		return nil, &silenceError{Key: "api.silence_invalid_ends_at", Args: []interface{}{err}}
+	}
+	if !endsAt.After(startsAt) {
+		return nil, &silenceError{Key: "api.silence_ends_before_start"}
+	}
+
+	silence := &Silence{
+		Matchers:  m,
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+		CreatedBy: req.CreatedBy,
+		Comment:   req.Comment,
+	}
+	if err := silence.compile(); err != nil {
+		return nil, err
+	}
+	silence.State = silence.stateAt(time.Now())
+	return silence, nil
+}
+
+// CreateSilenceHandler 创建静默规则
+func CreateSilenceHandler(c *gin.Context) {
+	var req SilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+
+	silence, err := buildSilence(req)
+	if err != nil {
+		message := err.Error()
+		if se, ok := err.(*silenceError); ok {
+			message = apiMessage(c, se.Key, se.Args...)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": message,
+		})
+		return
+	}
+
+	if err := InsertSilence(silence); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.silence_create_failed", err),
+		})
+		return
+	}
+
+	LogSystem(logrus.InfoLevel, "silence", "静默规则已创建", map[string]interface{}{
+		"silence_id": silence.ID,
+		"created_by": silence.CreatedBy,
+		"starts_at":  formatDisplayTime(silence.StartsAt),
+		"ends_at":    formatDisplayTime(silence.EndsAt),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.create_silence_ok"),
+		"data":    silence,
+	})
+}
+
+// GetSilencesHandler 获取静默规则列表
+func GetSilencesHandler(c *gin.Context) {
+	state := c.Query("state")
+	if state != "" && state != SilenceStateActive && state != SilenceStatePending && state != SilenceStateExpired {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.silence_state_invalid"),
+		})
+		return
+	}
+
+	silences, err := GetSilences(state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.silence_get_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_silences_ok"),
+		"data":    silences,
+		"total":   len(silences),
+	})
+}
+
+// GetSilenceHandler 获取单条静默规则
+func GetSilenceHandler(c *gin.Context) {
+	silence, ok := loadSilenceFromPath(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_silences_ok"),
+		"data":    silence,
+	})
+}
+
+// UpdateSilenceHandler 更新静默规则
+func UpdateSilenceHandler(c *gin.Context) {
+	existing, ok := loadSilenceFromPath(c)
+	if !ok {
+		return
+	}
+
+	var req SilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+	if req.StartsAt == "" {
+		req.StartsAt = formatAPITime(existing.StartsAt)
+	}
+
+	silence, err := buildSilence(req)
+	if err != nil {
+		message := err.Error()
+		if se, ok := err.(*silenceError); ok {
+			message = apiMessage(c, se.Key, se.Args...)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": message,
+		})
+		return
+	}
+	silence.ID = existing.ID
+	silence.CreatedAt = existing.CreatedAt
+
+	if err := UpdateSilence(silence); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.silence_update_failed", err),
+		})
+		return
+	}
+
+	LogSystem(logrus.InfoLevel, "silence", "静默规则已更新", map[string]interface{}{
+		"silence_id": silence.ID,
+		"created_by": silence.CreatedBy,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.update_silence_ok"),
+		"data":    silence,
+	})
+}
+
+// ExpireSilenceHandler 立即结束静默规则
+func ExpireSilenceHandler(c *gin.Context) {
+	silence, ok := loadSilenceFromPath(c)
+	if !ok {
+		return
+	}
+
+	if err := ExpireSilence(silence.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.silence_expire_failed", err),
+		})
+		return
+	}
+
+	LogSystem(logrus.InfoLevel, "silence", "静默规则已结束", map[string]interface{}{
+		"silence_id": silence.ID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.expire_silence_ok"),
+	})
+}
+
+// loadSilenceFromPath 根据路径参数加载静默规则，失败时写入错误响应
+func loadSilenceFromPath(c *gin.Context) (*Silence, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.silence_id_invalid"),
+		})
+		return nil, false
+	}
+
+	silence, err := GetSilenceByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.silence_get_failed", err),
+		})
+		return nil, false
+	}
+	if silence == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": apiMessage(c, "api.silence_not_found"),
+		})
+		return nil, false
+	}
+	return silence, true
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// digitPattern 用于归一化告警内容中的数字（耗时、百分比、计数等）
+var digitPattern = regexp.MustCompile(`[0-9]+`)
+
+// stormState 单个告警指纹的风暴状态
+type stormState struct {
+	windowStart    time.Time
+	lastSeen       time.Time
+	count          int
+	summaryAlertID int
+	sample         string
+}
+
+// StormDetector 告警风暴检测器，将短时间内大量相似告警合并为一条汇总告警
+type StormDetector struct {
+	mu        sync.Mutex
+	window    time.Duration
+	threshold int
+	states    map[string]*stormState
+}
+
+var alertStormDetector *StormDetector
+
+// NewStormDetector 创建风暴检测器
+func NewStormDetector(window time.Duration, threshold int) *StormDetector {
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &StormDetector{
+		window:    window,
+		threshold: threshold,
+		states:    make(map[string]*stormState),
+	}
+}
+
+// alertFingerprint 生成相似告警的指纹：收件人 + 来源 + 去掉数字后的内容
+func alertFingerprint(alert *Alert) string {
+	return alert.Recipient + "|" + alert.Source + "|" + digitPattern.ReplaceAllString(alert.Message, "#")
+}
+
+// stormBatch 一个写入事务中的风暴合并。汇总告警通过同一事务写入，
+// 对检测器状态的修改先记录在批次中，事务提交后由 Commit 应用，回滚时直接丢弃
+type stormBatch struct {
+	detector *StormDetector
+	pending  map[string]*stormPending
+}
+
+// stormPending 批次中某个指纹的风暴状态：已生效的状态加上本批次尚未提交的修改
+type stormPending struct {
+	stormState
+	added   int   // 本批次新增的告警数
+	created bool  // 本批次新建了汇总告警
+	summary Alert // 新建的汇总告警，提交后通知管理员
+}
+
+// Begin 开始一个写入批次
+func (d *StormDetector) Begin() *stormBatch {
+	return &stormBatch{detector: d, pending: make(map[string]*stormPending)}
+}
+
+// snapshot 读取指纹当前生效的风暴状态，窗口已过期时从零开始
+func (d *StormDetector) snapshot(key string, now time.Time) stormState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweep(now)
+	state, ok := d.states[key]
+	if !ok || now.Sub(state.lastSeen) > d.window {
+		return stormState{windowStart: now}
+	}
+	return *state
+}
+
+// Collapse 记录一条待写入的告警，处于风暴状态时将其合并到汇总告警中，汇总告警通过 ex（写入事务）写入。
+// collapsed 为 true 表示告警已被合并，alert 会被替换为汇总告警，调用方无需再写入数据库；
+// created 为 true 表示本次新建了汇总告警，只有这时才需要通知收件人，后续合并只更新汇总内容
+func (b *stormBatch) Collapse(ex sqlExecer, alert *Alert) (collapsed, created bool, err error) {
+	now := time.Now()
+	key := alertFingerprint(alert)
+	pending, ok := b.pending[key]
+	if !ok {
+		pending = &stormPending{stormState: b.detector.snapshot(key, now)}
+		b.pending[key] = pending
+	}
+	pending.lastSeen = now
+	pending.count++
+	pending.added++
+
+	if pending.count <= b.detector.threshold {
+		return false, false, nil
+	}
+
+	if pending.summaryAlertID == 0 {
+		pending.sample = alert.Message
+		summary := &Alert{
+			Message:   stormSummaryMessage(&pending.stormState, b.detector.window),
+			Recipient: alert.Recipient,
+			Source:    alert.Source,
+			Domain:    alert.Domain,
+			Severity:  alert.Severity,
+			Labels:    alert.Labels,
+			Route:     alert.Route,
+			SilenceID: alert.SilenceID,
+			Silenced:  alert.Silenced,
+			AlertTime: alert.AlertTime,
+		}
+		if err := insertAlert(ex, summary); err != nil {
+			return false, false, fmt.Errorf("写入告警风暴汇总失败: %v", err)
+		}
+		pending.summaryAlertID = summary.ID
+		pending.created = true
+		pending.summary = *summary
+
+		*alert = *summary
+		return true, true, nil
+	}
+
+	message := stormSummaryMessage(&pending.stormState, b.detector.window)
+	if err := updateAlertMessage(ex, pending.summaryAlertID, message); err != nil {
+		return false, false, fmt.Errorf("更新告警风暴汇总失败: %v", err)
+	}
+
+	alert.ID = pending.summaryAlertID
+	alert.Message = message
+	return true, false, nil
+}
+
+// Commit 事务提交后把批次的计数和新建的汇总告警应用到检测器，并通知管理员
+func (b *stormBatch) Commit() {
+	d := b.detector
+	var created []*stormPending
+
+	d.mu.Lock()
+	now := time.Now()
+	for key, pending := range b.pending {
+		state, ok := d.states[key]
+		if !ok || now.Sub(state.lastSeen) > d.window {
+			state = &stormState{windowStart: pending.windowStart}
+			d.states[key] = state
+		}
+		state.lastSeen = now
+		state.count += pending.added
+		if pending.created {
+			// 并发的批次可能已先提交了汇总告警，保留先提交的那条
+			if state.summaryAlertID == 0 {
+				state.summaryAlertID = pending.summaryAlertID
+				state.sample = pending.sample
+			}
+			created = append(created, pending)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, pending := range created {
+		LogSystem(logrus.WarnLevel, "storm", "检测到告警风暴", map[string]interface{}{
+			"recipient":        pending.summary.Recipient,
+			"source":           pending.summary.Source,
+			"count":            pending.count,
+			"summary_alert_id": pending.summaryAlertID,
+		})
+		if !pending.summary.Silenced {
+			go notifyStormAdmin(pending.summary, pending.count)
+		}
+	}
+}
+
+// sweep 清理已经平息的风暴状态
+func (d *StormDetector) sweep(now time.Time) {
+	for key, state := range d.states {
+		if now.Sub(state.lastSeen) > d.window {
+			if state.summaryAlertID != 0 {
+				LogSystem(logrus.InfoLevel, "storm", "告警风暴已平息", map[string]interface{}{
+					"summary_alert_id": state.summaryAlertID,
+					"count":            state.count,
+					"duration":         state.lastSeen.Sub(state.windowStart).String(),
+				})
+			}
+			delete(d.states, key)
+		}
+	}
+}
+
+// stormSummaryMessage 生成风暴汇总告警内容
+func stormSummaryMessage(state *stormState, window time.Duration) string {
+	return fmt.Sprintf("【告警风暴】自 %s 起相似告警已重复 %d 次（检测窗口 %s），后续告警已合并到本条记录。示例: %s",
+		state.windowStart.Format("2006-01-02 15:04:05"), state.count, window.String(), state.sample)
+}
+
+// notifyStormAdmin 通知管理员发生告警风暴，每次风暴只通知一次
+func notifyStormAdmin(summary Alert, count int) {
+	subject := fmt.Sprintf("【管理员】告警风暴 - %s - %s", summary.Recipient, time.Now().Format("2006-01-02 15:04:05"))
+	body := fmt.Sprintf(`<p>系统检测到告警风暴，已自动合并相似告警。</p>
+<p><strong>收件人:</strong> %s</p>
+<p><strong>来源:</strong> %s</p>
+<p><strong>触发时已收到:</strong> %d 条</p>
+<p><strong>汇总告警ID:</strong> %d</p>
+<p><strong>内容:</strong> %s</p>`,
+		template.HTMLEscapeString(summary.Recipient), template.HTMLEscapeString(summary.Source),
+		count, summary.ID, template.HTMLEscapeString(summary.Message))
+
+	if err := sendAdminEmail(subject, body); err != nil {
+		log.Printf("发送告警风暴通知失败: %v", err)
+	}
+}
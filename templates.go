@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// embeddedTemplates 内置的默认邮件模板，模板目录中没有对应文件时使用
+//
+//go:embed templates/*.html templates/*.txt
+var embeddedTemplates embed.FS
+
+// 邮件模板种类，对应模板文件名前缀
+const (
+	templateAlertEmail    = "alert_email"    // 用户预警邮件
+	templateFallbackEmail = "fallback_email" // 兜底收件人（未找到用户）邮件
+	templateReportEmail   = "report_email"   // 周报/月报邮件
+)
+
+// 模板格式，对应文件扩展名
+const (
+	templateFormatHTML = "html" // HTML 正文，使用 html/template 自动转义
+	templateFormatText = "text" // 纯文本正文（.txt），使用 text/template
+)
+
+// 按来源/团队选择模板时的文件名限定词，如 alert_email.source.cdn-monitor.html、alert_email.team.dba.html
+const (
+	templateScopeSource = "source"
+	templateScopeTeam   = "team"
+)
+
+// alertEmailData 用户预警邮件模板数据
+type alertEmailData struct {
+	GenerateTime string
+	StartTime    string
+	EndTime      string
+	TotalCount   int
+	Recipient    string
+	UserFound    bool
+	Alerts       []Alert
+	Inhibited    []Alert
+	Lang         string // 收件人语言，如 zh-CN、en-US
+
+	AttachmentName string // 告警列表附件的文件名，未附带时为空
+	DownloadURL    string // 附件超过大小限制时的下载链接
+}
+
+// T 按收件人语言格式化消息，供模板使用，如 {{.T "email.alert_count" .TotalCount}}
+func (d alertEmailData) T(key string, args ...interface{}) (string, error) {
+	return templateMessage(d.Lang, key, args...)
+}
+
+// fallbackEmailData 兜底收件人邮件模板数据
+type fallbackEmailData struct {
+	GenerateTime   string
+	NotFoundUsers  string
+	UserCount      int
+	TotalAlerts    int
+	StartTime      string
+	EndTime        string
+	UserAlertsList []UserAlerts
+	Lang           string // 邮件语言，使用默认语言
+}
+
+// T 按邮件语言格式化消息，供模板使用
+func (d fallbackEmailData) T(key string, args ...interface{}) (string, error) {
+	return templateMessage(d.Lang, key, args...)
+}
+
+// templateMessage 模板中引用不存在的 key 时返回错误，模板加载校验即可发现
+func templateMessage(locale, key string, args ...interface{}) (string, error) {
+	if _, ok := lookupMessage(locale, key); !ok {
+		return "", fmt.Errorf("消息目录中不存在 %s", key)
+	}
+	return T(locale, key, args...), nil
+}
+
+// EmailTemplateInfo 已加载的邮件模板
+type EmailTemplateInfo struct {
+	Name   string `json:"name"`   // 如 alert_email、alert_email.team.dba
+	Format string `json:"format"` // html / text
+	Kind   string `json:"kind"`   // alert_email / fallback_email
+	Scope  string `json:"scope"`  // default / source / team
+	Value  string `json:"value"`  // 来源或团队名称
+	Origin string `json:"origin"` // embedded 或模板文件路径
+}
+
+// emailTemplateSet 一组已解析并校验的邮件模板
+type emailTemplateSet struct {
+	html  map[string]*template.Template
+	text  map[string]*texttemplate.Template
+	infos []EmailTemplateInfo
+}
+
+var (
+	emailTemplatesMu sync.RWMutex
+	emailTemplates   *emailTemplateSet
+)
+
+// templateFuncs 邮件模板可用的函数
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"add":        func(a, b int) int { return a + b },
+		"formatTime": formatDisplayTime,
+	}
+}
+
+// InitEmailTemplates 加载内置模板和模板目录中的模板，全部校验通过后替换当前模板；重新加载配置时同样调用
+func InitEmailTemplates(dir string) error {
+	set, err := loadEmailTemplates(dir)
+	if err != nil {
+		return err
+	}
+
+	emailTemplatesMu.Lock()
+	emailTemplates = set
+	emailTemplatesMu.Unlock()
+
+	overrides := 0
+	for _, info := range set.infos {
+		if info.Origin != "embedded" {
+			overrides++
+		}
+	}
+	LogSystem(logrus.InfoLevel, "templates", "邮件模板加载成功", map[string]interface{}{
+		"dir":            dir,
+		"template_count": len(set.infos),
+		"file_count":     overrides,
+	})
+	log.Printf("邮件模板加载成功，共 %d 个，其中 %d 个来自模板目录", len(set.infos), overrides)
+	return nil
+}
+
+// loadEmailTemplates 先加载内置模板，再用模板目录中的同名文件覆盖，目录不存在时只使用内置模板
+func loadEmailTemplates(dir string) (*emailTemplateSet, error) {
+	set := &emailTemplateSet{html: map[string]*template.Template{}, text: map[string]*texttemplate.Template{}}
+	origins := map[string]string{}
+
+	embedded, err := embeddedTemplates.ReadDir("templates")
+	if err != nil {
+		return nil, fmt.Errorf("读取内置邮件模板失败: %v", err)
+	}
+	for _, entry := range embedded {
+		data, err := embeddedTemplates.ReadFile("templates/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("读取内置邮件模板失败: %v", err)
+		}
+		if err := set.add(entry.Name(), data); err != nil {
+			return nil, err
+		}
+		origins[entry.Name()] = "embedded"
+	}
+
+	if dir != "" {
+		var files []string
+		for _, pattern := range []string{"*.html", "*.txt"} {
+			matches, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return nil, fmt.Errorf("读取邮件模板目录失败: %v", err)
+			}
+			files = append(files, matches...)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("读取邮件模板失败: %v", err)
+			}
+			if err := set.add(filepath.Base(file), data); err != nil {
+				return nil, err
+			}
+			origins[filepath.Base(file)] = file
+		}
+	}
+
+	for fileName, origin := range origins {
+		name, format := splitTemplateFileName(fileName)
+		kind, scope, value, _ := parseTemplateName(name)
+		set.infos = append(set.infos, EmailTemplateInfo{Name: name, Format: format, Kind: kind, Scope: scope, Value: value, Origin: origin})
+	}
+	sort.Slice(set.infos, func(i, j int) bool {
+		if set.infos[i].Name != set.infos[j].Name {
+			return set.infos[i].Name < set.infos[j].Name
+		}
+		return set.infos[i].Format < set.infos[j].Format
+	})
+	return set, nil
+}
+
+// splitTemplateFileName 拆分模板文件名为模板名称和格式
+func splitTemplateFileName(fileName string) (string, string) {
+	if strings.HasSuffix(fileName, ".txt") {
+		return strings.TrimSuffix(fileName, ".txt"), templateFormatText
+	}
+	return strings.TrimSuffix(fileName, ".html"), templateFormatHTML
+}
+
+// parseTemplateName 解析模板名称：kind、kind.source.<来源>、kind.team.<团队>
+func parseTemplateName(name string) (kind, scope, value string, err error) {
+	parts := strings.SplitN(name, ".", 3)
+	kind = parts[0]
+	if kind != templateAlertEmail && kind != templateFallbackEmail && kind != templateReportEmail {
+		return "", "", "", fmt.Errorf("邮件模板 %s 的名称无效，应以 alert_email、fallback_email 或 report_email 开头", name)
+	}
+	if len(parts) == 1 {
+		return kind, "default", "", nil
+	}
+	if len(parts) != 3 || (parts[1] != templateScopeSource && parts[1] != templateScopeTeam) || parts[2] == "" {
+		return "", "", "", fmt.Errorf("邮件模板 %s 的名称无效，应为 %s.source.<来源> 或 %s.team.<团队>", name, kind, kind)
+	}
+	return kind, parts[1], parts[2], nil
+}
+
+// add 解析模板并用每种语言的示例数据试渲染，字段名、消息 key 错误等问题在加载时即可发现
+func (s *emailTemplateSet) add(fileName string, data []byte) error {
+	name, format := splitTemplateFileName(fileName)
+	kind, _, _, err := parseTemplateName(name)
+	if err != nil {
+		return err
+	}
+
+	if format == templateFormatText {
+		tmpl, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(templateFuncs())).Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("解析邮件模板 %s 失败: %v", fileName, err)
+		}
+		for _, locale := range supportedLocales {
+			if err := tmpl.Execute(io.Discard, sampleTemplateData(kind, locale)); err != nil {
+				return fmt.Errorf("校验邮件模板 %s (%s) 失败: %v", fileName, locale, err)
+			}
+		}
+		s.text[name] = tmpl
+		return nil
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs()).Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("解析邮件模板 %s 失败: %v", fileName, err)
+	}
+	for _, locale := range supportedLocales {
+		if err := tmpl.Execute(io.Discard, sampleTemplateData(kind, locale)); err != nil {
+			return fmt.Errorf("校验邮件模板 %s (%s) 失败: %v", fileName, locale, err)
+		}
+	}
+	s.html[name] = tmpl
+	return nil
+}
+
+// sampleTemplateData 校验模板使用的示例数据
+func sampleTemplateData(kind, locale string) interface{} {
+	now := time.Now()
+	alerts := []Alert{{ID: 1, Message: "示例告警", Recipient: "zhangsan", Source: "example", Severity: "warning", AlertTime: now}}
+	inhibited := []Alert{{ID: 2, Message: "示例被抑制告警", Recipient: "zhangsan", InhibitedBy: 1, AlertTime: now}}
+	if kind == templateReportEmail {
+		start, end, _, _ := reportPeriodRange(ReportPeriodWeekly, now)
+		current := []UserAlerts{{Recipient: "zhangsan", Alerts: []Alert{{ID: 1, Message: "示例告警", Recipient: "zhangsan", Domain: "example.com", AlertTime: start}}}}
+		return reportEmailData{
+			GenerateTime: formatDisplayTime(now),
+			Report:       computeAlertReport(ReportPeriodWeekly, start, end, current, current, 10),
+			Lang:         locale,
+		}
+	}
+	if kind == templateFallbackEmail {
+		return fallbackEmailData{
+			GenerateTime:   formatDisplayTime(now),
+			NotFoundUsers:  "zhangsan",
+			UserCount:      1,
+			TotalAlerts:    1,
+			StartTime:      formatDisplayTime(now),
+			EndTime:        formatDisplayTime(now),
+			UserAlertsList: []UserAlerts{{Recipient: "zhangsan", Alerts: alerts, Inhibited: inhibited}},
+			Lang:           locale,
+		}
+	}
+	return alertEmailData{
+		GenerateTime: formatDisplayTime(now),
+		StartTime:    formatDisplayTime(now),
+		EndTime:      formatDisplayTime(now),
+		TotalCount:   1,
+		Recipient:    "zhangsan",
+		UserFound:    true,
+		Alerts:       alerts,
+		Inhibited:    inhibited,
+		Lang:         locale,
+
+		AttachmentName: "alerts-zhangsan.csv",
+		DownloadURL:    "http://localhost:8080/api/v1/digests/attachment",
+	}
+}
+
+// selectTemplateName 按告警来源、路由团队选择模板名称，没有对应模板时使用默认模板
+func selectTemplateName(kind string, alert *Alert, exists func(name string) bool) string {
+	if alert != nil {
+		if alert.Source != "" {
+			if name := kind + "." + templateScopeSource + "." + alert.Source; exists(name) {
+				return name
+			}
+		}
+		if team := RouteAlert(alert)[0].Team; team != "" {
+			if name := kind + "." + templateScopeTeam + "." + team; exists(name) {
+				return name
+			}
+		}
+	}
+	return kind
+}
+
+// currentEmailTemplates 获取当前邮件模板
+func currentEmailTemplates() (*emailTemplateSet, error) {
+	emailTemplatesMu.RLock()
+	defer emailTemplatesMu.RUnlock()
+	if emailTemplates == nil {
+		return nil, fmt.Errorf("邮件模板未加载")
+	}
+	return emailTemplates, nil
+}
+
+// renderEmailTemplate 渲染 HTML 邮件模板，alert 为用于选择来源/团队模板的代表告警
+func renderEmailTemplate(kind string, alert *Alert, data interface{}) (string, error) {
+	set, err := currentEmailTemplates()
+	if err != nil {
+		return "", err
+	}
+	name := selectTemplateName(kind, alert, func(name string) bool { return set.html[name] != nil })
+	tmpl, ok := set.html[name]
+	if !ok {
+		return "", fmt.Errorf("邮件模板 %s 未加载", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("执行邮件模板 %s 失败: %v", name, err)
+	}
+
+	// 内联样式以兼容不支持 <style> 的邮件客户端，失败时使用原始HTML
+	inlined, err := inlineCSS(buf.String())
+	if err != nil {
+		LogSystem(logrus.WarnLevel, "templates", "邮件样式内联失败，使用原始HTML", map[string]interface{}{
+			"template": name,
+			"error":    err.Error(),
+		})
+		return buf.String(), nil
+	}
+	return inlined, nil
+}
+
+// renderTextTemplate 渲染纯文本邮件模板，选择规则与 HTML 模板相同
+func renderTextTemplate(kind string, alert *Alert, data interface{}) (string, error) {
+	set, err := currentEmailTemplates()
+	if err != nil {
+		return "", err
+	}
+	name := selectTemplateName(kind, alert, func(name string) bool { return set.text[name] != nil })
+	tmpl, ok := set.text[name]
+	if !ok {
+		return "", fmt.Errorf("邮件模板 %s.txt 未加载", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("执行邮件模板 %s.txt 失败: %v", name, err)
+	}
+	return buf.String(), nil
+}
+
+// firstAlert 返回第一条告警，用于选择模板
+func firstAlert(alerts []Alert) *Alert {
+	if len(alerts) == 0 {
+		return nil
+	}
+	return &alerts[0]
+}
+
+// GetEmailTemplatesHandler 查看已加载的邮件模板及其来源
+func GetEmailTemplatesHandler(c *gin.Context) {
+	emailTemplatesMu.RLock()
+	set := emailTemplates
+	emailTemplatesMu.RUnlock()
+
+	infos := []EmailTemplateInfo{}
+	if set != nil {
+		infos = set.infos
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_templates_ok"),
+		"data":    infos,
+		"dir":     currentEmailConfig().TemplateDir,
+	})
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// testEmailSource 测试告警的来源，便于在日志和模板选择中区分
+const testEmailSource = "test-email"
+
+// defaultTestMessage 未指定 messages 时使用的测试告警内容
+const defaultTestMessage = "这是一条测试告警，用于验证告警通知链路是否正常"
+
+// TestEmailRequest 测试通知请求，所有字段可选
+type TestEmailRequest struct {
+	Recipients []string `json:"recipients"` // 收件人，元素可为逗号分隔的多个收件人；为空时发送给调用者（请求头 X-User）
+	Messages   []string `json:"messages"`   // 告警内容，每条生成一个测试告警
+	Severity   string   `json:"severity" binding:"omitempty,oneof=info warning critical"`
+	Channel    string   `json:"channel" binding:"omitempty,oneof=email im"` // 默认 email
+	DryRun     bool     `json:"dry_run"`                                    // 只解析收件人并渲染内容，不实际发送
+}
+
+// buildTestAlerts 为每个收件人生成测试告警
+func buildTestAlerts(recipients, messages []string, severity string) []UserAlerts {
+	now := time.Now()
+	var userAlertsList []UserAlerts
+	for _, recipient := range recipients {
+		var alerts []Alert
+		for _, message := range messages {
+			alerts = append(alerts, Alert{
+				Message:   message,
+				Recipient: recipient,
+				Source:    testEmailSource,
+				Domain:    extractDomain(message),
+				Severity:  severity,
+				Status:    AlertStatusOpen,
+				AlertTime: now,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+		}
+		userAlertsList = append(userAlertsList, UserAlerts{Recipient: recipient, Alerts: alerts})
+	}
+	return userAlertsList
+}
+
+// testRecipientAllowed 测试通知只能发给用户目录中的用户、能展开为具体人员的团队收件人或允许域名下的邮箱，
+// 避免接口被用来向任意地址发送邮件
+func testRecipientAllowed(recipient string, allowedDomains []string) bool {
+	if at := strings.LastIndex(recipient, "@"); at >= 0 {
+		domain := recipient[at+1:]
+		for _, allowed := range allowedDomains {
+			if strings.EqualFold(domain, strings.TrimPrefix(allowed, "@")) {
+				return true
+			}
+		}
+		return false
+	}
+	if isTeamRecipient(recipient) {
+		return len(resolveTeamRecipient(recipient, time.Now())) > 0
+	}
+	resolution := resolveRecipient(recipient)
+	return resolution.Found && resolution.EName != ""
+}
+
+// TestIMPreview 测试IM消息的预览结果
+type TestIMPreview struct {
+	Recipient  string              `json:"recipient"`
+	Configured bool                `json:"configured"`     // 是否配置了个人IM机器人，未配置时实际发送会失败
+	Text       string              `json:"text,omitempty"` // 推送到个人IM机器人的消息内容
+	AlertCount int                 `json:"alert_count"`
+	Resolution RecipientResolution `json:"resolution"`
+}
+
+// previewTestIM 将团队收件人展开为具体人员后，按各自的通知偏好渲染测试IM消息
+func previewTestIM(userAlertsList []UserAlerts) []TestIMPreview {
+	var previews []TestIMPreview
+	for _, userAlerts := range expandTeamRecipients(userAlertsList) {
+		preview := TestIMPreview{
+			Recipient:  userAlerts.Recipient,
+			AlertCount: len(userAlerts.Alerts),
+			Resolution: resolveRecipient(userAlerts.Recipient),
+		}
+		if pref := preferencesFor(userAlerts.Recipient); pref != nil && pref.IMWebhook != "" {
+			preview.Configured = true
+			preview.Text = renderIMText(pref, userAlerts.Recipient, userAlerts.Alerts)
+		}
+		previews = append(previews, preview)
+	}
+	return previews
+}
+
+// sendTestIM 将团队收件人展开为具体人员后，按各自的通知偏好推送测试IM消息，未配置个人机器人的收件人记为失败
+func sendTestIM(ctx context.Context, userAlertsList []UserAlerts) *SendResult {
+	result := &SendResult{}
+	for _, userAlerts := range expandTeamRecipients(userAlertsList) {
+		resolution := resolveRecipient(userAlerts.Recipient)
+		item := RecipientSendResult{
+			Recipient:  userAlerts.Recipient,
+			Email:      resolution.Email,
+			Found:      resolution.Found,
+			Channel:    ChannelIM,
+			AlertCount: len(userAlerts.Alerts),
+		}
+		pref := preferencesFor(userAlerts.Recipient)
+		if pref == nil || pref.IMWebhook == "" {
+			result.add(item, nil, fmt.Errorf("收件人未配置个人IM机器人"))
+			continue
+		}
+		gateway, err := sendIMNotification(ctx, pref, userAlerts.Recipient, userAlerts.Alerts)
+		result.add(item, gateway, err)
+	}
+	return result
+}
+
+// TestEmailHandler 发送测试通知，可指定收件人、告警内容和渠道；dry_run 时只返回收件人解析和渲染结果
+func TestEmailHandler(c *gin.Context) {
+	var req TestEmailRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_request", err),
+			})
+			return
+		}
+	}
+
+	var recipients []string
+	for _, item := range req.Recipients {
+		recipients = append(recipients, parseRecipients(item)...)
+	}
+	if len(recipients) == 0 {
+		if caller := strings.TrimSpace(c.GetHeader("X-User")); caller != "" {
+			recipients = parseRecipients(caller)
+		}
+	}
+	if len(recipients) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.test_recipient_required"),
+		})
+		return
+	}
+
+	emailCfg := currentEmailConfig()
+	if emailCfg.TestMaxRecipients > 0 && len(recipients) > emailCfg.TestMaxRecipients {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.test_too_many_recipients", emailCfg.TestMaxRecipients),
+		})
+		return
+	}
+	var notAllowed []string
+	for _, recipient := range recipients {
+		if !testRecipientAllowed(recipient, emailCfg.TestAllowedDomains) {
+			notAllowed = append(notAllowed, recipient)
+		}
+	}
+	if len(notAllowed) > 0 {
+		LogSystem(logrus.WarnLevel, "email", "测试通知收件人不在允许范围内", map[string]interface{}{
+			"recipients": notAllowed,
+			"client_ip":  c.ClientIP(),
+		})
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.test_recipient_not_allowed", strings.Join(notAllowed, ", ")),
+		})
+		return
+	}
+
+	var messages []string
+	for _, message := range req.Messages {
+		if message = strings.TrimSpace(message); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) == 0 {
+		messages = []string{defaultTestMessage}
+	}
+	if emailCfg.TestMaxMessages > 0 && len(messages) > emailCfg.TestMaxMessages {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.test_too_many_messages", emailCfg.TestMaxMessages),
+		})
+		return
+	}
+	severity := req.Severity
+	if severity == "" {
+		severity = "info"
+	}
+	channel := req.Channel
+	if channel == "" {
+		channel = ChannelEmail
+	}
+
+	LogSystem(logrus.InfoLevel, "email", "收到测试通知请求", map[string]interface{}{
+		"recipients":    recipients,
+		"message_count": len(messages),
+		"channel":       channel,
+		"dry_run":       req.DryRun,
+	})
+
+	if req.DryRun && channel == ChannelIM {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": apiMessage(c, "api.test_preview_ok"),
+			"data": gin.H{
+				"channel":  channel,
+				"dry_run":  true,
+				"previews": previewTestIM(buildTestAlerts(recipients, messages, severity)),
+			},
+		})
+		return
+	}
+	if req.DryRun {
+		var alerts []CreateAlertRequest
+		for _, message := range messages {
+			alerts = append(alerts, CreateAlertRequest{Message: message, Source: testEmailSource, Severity: severity})
+		}
+		var previews []*NotificationPreview
+		for _, recipient := range recipients {
+			preview, err := buildNotificationPreview(&NotificationPreviewRequest{Recipient: recipient, Alerts: alerts})
+			if err != nil {
+				respondPreviewError(c, err)
+				return
+			}
+			previews = append(previews, preview)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": apiMessage(c, "api.test_preview_ok"),
+			"data": gin.H{
+				"channel":  channel,
+				"dry_run":  true,
+				"previews": previews,
+			},
+		})
+		return
+	}
+
+	// 实际发送的测试告警与写入告警共用限流，按告警条数计
+	if !checkAlertRateLimit(c, testEmailSource, len(recipients)*len(messages)) {
+		return
+	}
+
+	userAlertsList := buildTestAlerts(recipients, messages, severity)
+	var result *SendResult
+	var err error
+	if channel == ChannelIM {
+		result = sendTestIM(c.Request.Context(), userAlertsList)
+		if failed := result.Failed(); len(failed) > 0 {
+			err = fmt.Errorf("部分IM消息发送失败，成功: %d，失败: %d", result.SuccessCount(), len(failed))
+		}
+	} else {
+		// 调用方断开连接时停止发送剩余邮件
+		result, err = SendAlertEmail(c.Request.Context(), userAlertsList)
+	}
+
+	data := gin.H{
+		"channel":       channel,
+		"dry_run":       false,
+		"user_count":    len(userAlertsList),
+		"total_alerts":  len(userAlertsList) * len(messages),
+		"success_count": result.SuccessCount(),
+		"fail_count":    len(result.Failed()),
+		"results":       result.Recipients,
+	}
+	if err != nil {
+		log.Printf("测试通知发送失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.test_send_failed", err),
+			"data":    data,
+		})
+		return
+	}
+
+	log.Printf("测试通知发送成功，共发送给 %d 个收件人", len(userAlertsList))
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.test_send_ok"),
+		"data":    data,
+	})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 告警时间线事件类型
+const (
+	AlertEventCreated           = "created"
+	AlertEventAcknowledged      = "acknowledged"
+	AlertEventResolved          = "resolved"
+	AlertEventEscalated         = "escalated"
+	AlertEventEscalationStopped = "escalation_stopped"
+)
+
+// createAlertEventTable 创建告警时间线表
+func createAlertEventTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS alert_events (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		alert_id INT NOT NULL,
+		event VARCHAR(50) NOT NULL,
+		actor VARCHAR(100) NOT NULL DEFAULT '',
+		detail TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_alert_id (alert_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// InsertAlertEvent 记录告警时间线事件
+func InsertAlertEvent(alertID int, event, actor, detail string) error {
+	_, err := db.Exec(`INSERT INTO alert_events (alert_id, event, actor, detail, created_at) VALUES (?, ?, ?, ?, ?)`,
+		alertID, event, actor, detail, time.Now())
+	if err != nil {
+		LogDatabase("INSERT", "alert_events", false, err.Error(), 0)
+		return fmt.Errorf("记录告警时间线失败: %v", err)
+	}
+
+	LogDatabase("INSERT", "alert_events", true, "", 1)
+	return nil
+}
+
+// recordAlertEvent 记录告警时间线事件，失败时仅记录日志
+func recordAlertEvent(alertID int, event, actor, detail string) {
+	if err := InsertAlertEvent(alertID, event, actor, detail); err != nil {
+		LogSystem(logrus.WarnLevel, "timeline", "记录告警时间线失败", map[string]interface{}{
+			"alert_id": alertID,
+			"event":    event,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// GetAlertEvents 获取告警的时间线事件，按时间正序
+func GetAlertEvents(alertID int) ([]AlertEvent, error) {
+	rows, err := db.Query(`SELECT id, alert_id, event, actor, detail, created_at 
+		FROM alert_events WHERE alert_id = ? ORDER BY created_at ASC, id ASC`, alertID)
+	if err != nil {
+		LogDatabase("SELECT", "alert_events", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询告警时间线失败: %v", err)
+	}
+	defer rows.Close()
+
+	events := []AlertEvent{}
+	for rows.Next() {
+		var event AlertEvent
+		var detail sql.NullString
+		if err := rows.Scan(&event.ID, &event.AlertID, &event.Event, &event.Actor, &detail, &event.CreatedAt); err != nil {
+			LogDatabase("SELECT", "alert_events", false, err.Error(), 0)
+			return nil, fmt.Errorf("扫描告警时间线失败: %v", err)
+		}
+		event.Detail = detail.String
+		events = append(events, event)
+	}
+
+	LogDatabase("SELECT", "alert_events", true, "", int64(len(events)))
+	return events, nil
+}
+
+// GetAlertHandler 查询单条告警
+func GetAlertHandler(c *gin.Context) {
+	alert, ok := loadAlertFromPath(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_alert_ok"),
+		"data":    alert,
+	})
+}
+
+// AcknowledgeAlertHandler 确认告警，确认后停止升级
+func AcknowledgeAlertHandler(c *gin.Context) {
+	changeAlertStatus(c, AlertStatusAcknowledged)
+}
+
+// ResolveAlertHandler 恢复告警，恢复后停止升级
+func ResolveAlertHandler(c *gin.Context) {
+	changeAlertStatus(c, AlertStatusResolved)
+}
+
+// changeAlertStatus 确认或恢复告警，并记录时间线
+func changeAlertStatus(c *gin.Context, status string) {
+	var req AlertActionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": apiMessage(c, "api.invalid_request", err),
+			})
+			return
+		}
+	}
+
+	alert, ok := loadAlertFromPath(c)
+	if !ok {
+		return
+	}
+
+	var updated bool
+	var err error
+	event := AlertEventAcknowledged
+	if status == AlertStatusResolved {
+		event = AlertEventResolved
+		updated, err = ResolveAlert(alert.ID, req.By)
+	} else {
+		updated, err = AcknowledgeAlert(alert.ID, req.By)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.update_alert_status_failed", err),
+		})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": apiMessage(c, "api.alert_status_conflict", alert.Status, status),
+		})
+		return
+	}
+
+	recordAlertEvent(alert.ID, event, req.By, req.Comment)
+	LogSystem(logrus.InfoLevel, "handler", "告警状态已变更", map[string]interface{}{
+		"alert_id": alert.ID,
+		"status":   status,
+		"by":       req.By,
+	})
+
+	alert, err = GetAlertByID(alert.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_alerts_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.update_alert_status_ok"),
+		"data":    alert,
+	})
+}
+
+// GetAlertTimelineHandler 查询告警时间线：创建、升级通知、确认、恢复
+func GetAlertTimelineHandler(c *gin.Context) {
+	alert, ok := loadAlertFromPath(c)
+	if !ok {
+		return
+	}
+
+	events, err := GetAlertEvents(alert.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_timeline_failed", err),
+		})
+		return
+	}
+
+	// 创建事件由告警记录本身生成，不单独存储；created_at 与其他事件一样由程序写入
+	timeline := append([]AlertEvent{{
+		AlertID:   alert.ID,
+		Event:     AlertEventCreated,
+		Actor:     alert.Source,
+		Detail:    alert.Message,
+		CreatedAt: alert.CreatedAt,
+	}}, events...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_timeline_ok"),
+		"data": gin.H{
+			"alert":    alert,
+			"timeline": timeline,
+		},
+	})
+}
+
+// loadAlertFromPath 根据路径参数加载告警，失败时直接写入响应
+func loadAlertFromPath(c *gin.Context) (*Alert, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.alert_id_invalid"),
+		})
+		return nil, false
+	}
+
+	alert, err := GetAlertByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_alerts_failed", err),
+		})
+		return nil, false
+	}
+	if alert == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": apiMessage(c, "api.alert_not_found"),
+		})
+		return nil, false
+	}
+	return alert, true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// legacyTimeLayout 历史接口使用的时间格式，按服务器时区解析
+const legacyTimeLayout = "2006-01-02 15:04:05"
+
+// serverLocation 服务器时区，数据库、定时任务和邮件展示统一使用
+var serverLocation = time.Local
+
+// InitTimezone 初始化服务器时区，并将其设置为进程默认时区
+func InitTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("加载时区 %s 失败: %v", name, err)
+	}
+
+	serverLocation = loc
+	// time.Now() 及未显式指定时区的格式化都以服务器时区为准
+	time.Local = loc
+	return nil
+}
+
+// mysqlTimeZone 服务器时区当前的 UTC 偏移，如 '+08:00'，作为 MySQL 会话时区。
+// NOW()、CURRENT_TIMESTAMP 写入的时间与 Go 按 loc 读写的时间因此一致（夏令时切换后需重启生效）
+func mysqlTimeZone() string {
+	_, offset := time.Now().In(serverLocation).Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("'%c%02d:%02d'", sign, offset/3600, offset%3600/60)
+}
+
+// parseFlexibleTime 解析时间参数，支持以下格式：
+//   - RFC3339，如 2025-01-15T19:30:00+08:00
+//   - 秒级或毫秒级时间戳，如 1736940600 / 1736940600000
+//   - 无时区的 "2006-01-02 15:04:05" 或 "2006-01-02T15:04:05"，按服务器时区解析
+func parseFlexibleTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("时间不能为空")
+	}
+
+	if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// 13位及以上视为毫秒
+		if epoch >= 1e12 || epoch <= -1e12 {
+			return time.UnixMilli(epoch).In(serverLocation), nil
+		}
+		return time.Unix(epoch, 0).In(serverLocation), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.In(serverLocation), nil
+	}
+
+	for _, layout := range []string{legacyTimeLayout, "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, serverLocation); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("无法解析时间 %q", value)
+}
+
+// formatAPITime 格式化接口返回的时间，带时区偏移
+func formatAPITime(t time.Time) string {
+	return t.In(serverLocation).Format(time.RFC3339)
+}
+
+// formatDisplayTime 格式化邮件和日志中展示的时间（服务器时区）
+func formatDisplayTime(t time.Time) string {
+	return t.In(serverLocation).Format(legacyTimeLayout)
+}
@@ -0,0 +1,686 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// User 用户目录中的用户
+type User struct {
+	ID        int       `json:"id"`
+	EName     string    `json:"e_name"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserRequest 创建/更新用户请求
+type UserRequest struct {
+	EName string `json:"e_name"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// UserImportResult 用户导入结果
+type UserImportResult struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Deleted int               `json:"deleted"`
+	Skipped int               `json:"skipped"`
+	Errors  []UserImportError `json:"errors,omitempty"`
+}
+
+// UserImportError 导入失败的条目
+type UserImportError struct {
+	Index int    `json:"index"`
+	EName string `json:"e_name"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// userDirectory 用户目录缓存，按英文名索引，数据库变更后整体刷新
+type userDirectory struct {
+	mu      sync.RWMutex
+	byEName map[string]UserInfo
+	index   *identityIndex // 模糊匹配索引
+}
+
+var directory = &userDirectory{byEName: map[string]UserInfo{}, index: newIdentityIndex(nil)}
+
+// createUserTable 创建用户表
+func createUserTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS users (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		e_name VARCHAR(100) NOT NULL,
+		name VARCHAR(100) NOT NULL DEFAULT '',
+		email VARCHAR(255) NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		UNIQUE KEY uk_e_name (e_name),
+		INDEX idx_email (email)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+const userColumns = "id, e_name, name, email, created_at, updated_at"
+
+// queryUsers 查询用户
+func queryUsers(query string, args ...interface{}) ([]User, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		LogDatabase("SELECT", "users", false, err.Error(), 0)
+		return nil, fmt.Errorf("查询用户失败: %v", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.EName, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			LogDatabase("SELECT", "users", false, err.Error(), 0)
+			return nil, fmt.Errorf("扫描用户失败: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	LogDatabase("SELECT", "users", true, "", int64(len(users)))
+	return users, nil
+}
+
+// GetUsers 获取用户列表，keyword 不为空时按英文名、姓名、邮箱模糊匹配
+func GetUsers(keyword string) ([]User, error) {
+	if keyword == "" {
+		return queryUsers(`SELECT ` + userColumns + ` FROM users ORDER BY e_name`)
+	}
+	like := "%" + keyword + "%"
+	return queryUsers(`SELECT `+userColumns+` FROM users WHERE e_name LIKE ? OR name LIKE ? OR email LIKE ? ORDER BY e_name`,
+		like, like, like)
+}
+
+// GetUserByEName 根据英文名获取用户，不存在时返回 nil
+func GetUserByEName(eName string) (*User, error) {
+	users, err := queryUsers(`SELECT `+userColumns+` FROM users WHERE e_name = ?`, eName)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	return &users[0], nil
+}
+
+// InsertUser 新增用户
+func InsertUser(user *User) error {
+	result, err := db.Exec(`INSERT INTO users (e_name, name, email) VALUES (?, ?, ?)`, user.EName, user.Name, user.Email)
+	if err != nil {
+		LogDatabase("INSERT", "users", false, err.Error(), 0)
+		return fmt.Errorf("新增用户失败: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		LogDatabase("INSERT", "users", false, err.Error(), 0)
+		return fmt.Errorf("获取插入ID失败: %v", err)
+	}
+	user.ID = int(id)
+	LogDatabase("INSERT", "users", true, "", 1)
+	return nil
+}
+
+// UpdateUser 更新用户姓名和邮箱
+func UpdateUser(user *User) error {
+	result, err := db.Exec(`UPDATE users SET name = ?, email = ? WHERE e_name = ?`, user.Name, user.Email, user.EName)
+	if err != nil {
+		LogDatabase("UPDATE", "users", false, err.Error(), 0)
+		return fmt.Errorf("更新用户失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("UPDATE", "users", true, "", affected)
+	return nil
+}
+
+// DeleteUser 删除用户
+func DeleteUser(eName string) error {
+	result, err := db.Exec(`DELETE FROM users WHERE e_name = ?`, eName)
+	if err != nil {
+		LogDatabase("DELETE", "users", false, err.Error(), 0)
+		return fmt.Errorf("删除用户失败: %v", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	LogDatabase("DELETE", "users", true, "", affected)
+	return nil
+}
+
+// countUsers 统计用户数量
+func countUsers() (int, error) {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		LogDatabase("SELECT", "users", false, err.Error(), 0)
+		return 0, fmt.Errorf("统计用户数量失败: %v", err)
+	}
+	return count, nil
+}
+
+// userListFileHash 最近一次读取的用户列表文件内容摘要，用于判断文件是否变化
+var userListFileHash string
+
+// InitUserDirectory 初始化用户目录：用户表为空时从 USERLIST_FILE 导入，并加载缓存
+func InitUserDirectory() error {
+	count, err := countUsers()
+	if err != nil {
+		return err
+	}
+
+	users, hash, err := readUserListFile(config.Users.SeedFile)
+	if err != nil {
+		if count == 0 {
+			LogSystem(logrus.WarnLevel, "users", "读取用户列表文件失败，用户目录为空", map[string]interface{}{
+				"file":  config.Users.SeedFile,
+				"error": err.Error(),
+			})
+		}
+		return refreshUserDirectory()
+	}
+	userListFileHash = hash
+
+	if count == 0 {
+		result, err := importUsers(users, false)
+		if err != nil {
+			return err
+		}
+		LogSystem(logrus.InfoLevel, "users", "已从用户列表文件导入用户", map[string]interface{}{
+			"file":    config.Users.SeedFile,
+			"created": result.Created,
+			"skipped": result.Skipped,
+		})
+		for _, skipped := range result.Errors {
+			LogSystem(logrus.WarnLevel, "users", "用户列表文件中的条目未导入", map[string]interface{}{
+				"file":  config.Users.SeedFile,
+				"index": skipped.Index,
+				"name":  skipped.Name,
+				"error": skipped.Error,
+			})
+		}
+	}
+
+	return refreshUserDirectory()
+}
+
+// syncUserListFile 用户列表文件内容变化时合并导入用户表并刷新缓存，文件未变化时返回 nil
+func syncUserListFile(path string) (*UserImportResult, error) {
+	users, hash, err := readUserListFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if hash == userListFileHash {
+		return nil, nil
+	}
+
+	result, err := importUsers(users, false)
+	if err != nil {
+		return nil, err
+	}
+	userListFileHash = hash
+	if err := refreshUserDirectory(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readUserListFile 读取 userlist.json 格式的用户列表文件，同时返回内容摘要
+func readUserListFile(path string) ([]UserInfo, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("打开用户列表文件失败: %v", err)
+	}
+
+	var users []UserInfo
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, "", fmt.Errorf("解析用户列表JSON失败: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return users, hex.EncodeToString(sum[:]), nil
+}
+
+// refreshUserDirectory 从数据库重新加载用户目录缓存
+func refreshUserDirectory() error {
+	users, err := GetUsers("")
+	if err != nil {
+		return err
+	}
+
+	byEName := make(map[string]UserInfo, len(users))
+	infos := make([]UserInfo, 0, len(users))
+	for _, user := range users {
+		info := UserInfo{Name: user.Name, EName: user.EName, Email: user.Email}
+		byEName[user.EName] = info
+		infos = append(infos, info)
+	}
+	index := newIdentityIndex(infos)
+
+	directory.mu.Lock()
+	directory.byEName = byEName
+	directory.index = index
+	directory.mu.Unlock()
+
+	LogSystem(logrus.InfoLevel, "users", "用户目录加载成功", map[string]interface{}{
+		"user_count": len(byEName),
+	})
+	log.Printf("用户目录加载成功，共 %d 个用户", len(byEName))
+	return nil
+}
+
+// findUserInfo 根据英文名查找用户信息，启用LDAP时优先查找LDAP，未找到再查本地用户目录
+func findUserInfo(eName string) (UserInfo, bool) {
+	user, _, ok := resolveUser(eName)
+	return user, ok
+}
+
+// userError 用户信息校验失败时消息目录中的错误消息
+type userError struct {
+	Key  string
+	Args []interface{}
+}
+
+func (e *userError) Error() string { return T(defaultLocale, e.Key, e.Args...) }
+
+// validateEmail 校验邮箱格式，允许为空
+func validateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return &userError{Key: "api.user_invalid_email", Args: []interface{}{email}}
+	}
+	return nil
+}
+
+// normalizeUser 清理并校验用户信息，英文名为空时取邮箱前缀（userlist.json 中部分用户只有姓名和邮箱），校验失败时返回 *userError
+func normalizeUser(info UserInfo) (UserInfo, error) {
+	info.EName = strings.TrimSpace(info.EName)
+	info.Name = strings.TrimSpace(info.Name)
+	info.Email = strings.TrimSpace(info.Email)
+	if err := validateEmail(info.Email); err != nil {
+		return info, err
+	}
+	if info.EName == "" {
+		if at := strings.Index(info.Email, "@"); at > 0 {
+			info.EName = strings.ToLower(info.Email[:at])
+		}
+	}
+	if info.EName == "" {
+		return info, &userError{Key: "api.user_ename_required"}
+	}
+	if strings.ContainsAny(info.EName, ",，:@ ") {
+		return info, &userError{Key: "api.user_invalid_ename"}
+	}
+	return info, nil
+}
+
+// importUsers 导入用户：已存在的更新，不存在的新增；replace 为 true 时删除未出现在导入列表中的用户
+func importUsers(users []UserInfo, replace bool) (*UserImportResult, error) {
+	existing, err := GetUsers("")
+	if err != nil {
+		return nil, err
+	}
+	existingByEName := make(map[string]User, len(existing))
+	for _, user := range existing {
+		existingByEName[user.EName] = user
+	}
+
+	result := &UserImportResult{}
+	seen := make(map[string]bool)
+	for i, info := range users {
+		info, err := normalizeUser(info)
+		if err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, UserImportError{Index: i, EName: info.EName, Name: info.Name, Error: err.Error()})
+			continue
+		}
+		if seen[info.EName] {
+			result.Skipped++
+			result.Errors = append(result.Errors, UserImportError{Index: i, EName: info.EName, Name: info.Name, Error: "英文名重复"})
+			continue
+		}
+		seen[info.EName] = true
+
+		user := User{EName: info.EName, Name: info.Name, Email: info.Email}
+		if old, ok := existingByEName[info.EName]; ok {
+			if old.Name == user.Name && old.Email == user.Email {
+				continue
+			}
+			if err := UpdateUser(&user); err != nil {
+				return nil, err
+			}
+			result.Updated++
+			continue
+		}
+		if err := InsertUser(&user); err != nil {
+			return nil, err
+		}
+		result.Created++
+	}
+
+	if replace {
+		for eName := range existingByEName {
+			if seen[eName] {
+				continue
+			}
+			if err := DeleteUser(eName); err != nil {
+				return nil, err
+			}
+			result.Deleted++
+		}
+	}
+	return result, nil
+}
+
+// missingContactUsers 获取缺少联系方式的用户
+func missingContactUsers() []UserInfo {
+	directory.mu.RLock()
+	defer directory.mu.RUnlock()
+
+	users := []UserInfo{}
+	for _, user := range directory.byEName {
+		if user.Email == "" {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].EName < users[j].EName })
+	return users
+}
+
+// GetUsersHandler 查询用户列表，支持 q 关键字搜索
+func GetUsersHandler(c *gin.Context) {
+	users, err := GetUsers(strings.TrimSpace(c.Query("q")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_users_failed", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_users_ok"),
+		"data":    users,
+		"total":   len(users),
+	})
+}
+
+// GetUserHandler 查询单个用户
+func GetUserHandler(c *gin.Context) {
+	user, ok := loadUserFromPath(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_user_ok"),
+		"data":    user,
+	})
+}
+
+// CreateUserHandler 新增用户
+func CreateUserHandler(c *gin.Context) {
+	var req UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+
+	info, err := normalizeUser(UserInfo{EName: req.EName, Name: req.Name, Email: req.Email})
+	if err != nil {
+		message := err.Error()
+		if ue, ok := err.(*userError); ok {
+			message = apiMessage(c, ue.Key, ue.Args...)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": message,
+		})
+		return
+	}
+
+	existing, err := GetUserByEName(info.EName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_users_failed", err),
+		})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": apiMessage(c, "api.user_exists"),
+		})
+		return
+	}
+
+	user := User{EName: info.EName, Name: info.Name, Email: info.Email}
+	if err := InsertUser(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.create_user_failed", err),
+		})
+		return
+	}
+	respondUserChanged(c, http.StatusCreated, "api.create_user_ok", user.EName)
+}
+
+// UpdateUserHandler 更新用户姓名和邮箱
+func UpdateUserHandler(c *gin.Context) {
+	existing, ok := loadUserFromPath(c)
+	if !ok {
+		return
+	}
+
+	var req UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_request", err),
+		})
+		return
+	}
+
+	info, err := normalizeUser(UserInfo{EName: existing.EName, Name: req.Name, Email: req.Email})
+	if err != nil {
+		message := err.Error()
+		if ue, ok := err.(*userError); ok {
+			message = apiMessage(c, ue.Key, ue.Args...)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": message,
+		})
+		return
+	}
+
+	user := User{EName: existing.EName, Name: info.Name, Email: info.Email}
+	if err := UpdateUser(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.update_user_failed", err),
+		})
+		return
+	}
+	respondUserChanged(c, http.StatusOK, "api.update_user_ok", user.EName)
+}
+
+// DeleteUserHandler 删除用户
+func DeleteUserHandler(c *gin.Context) {
+	user, ok := loadUserFromPath(c)
+	if !ok {
+		return
+	}
+
+	if err := DeleteUser(user.EName); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.delete_user_failed", err),
+		})
+		return
+	}
+	if err := refreshUserDirectory(); err != nil {
+		LogSystem(logrus.WarnLevel, "users", "刷新用户目录失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.delete_user_ok"),
+		"data":    user,
+	})
+}
+
+// ImportUsersHandler 导入 userlist.json 格式的用户列表，mode=replace 时删除列表外的用户
+func ImportUsersHandler(c *gin.Context) {
+	var users []UserInfo
+	if err := c.ShouldBindJSON(&users); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.invalid_userlist", err),
+		})
+		return
+	}
+
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": apiMessage(c, "api.users_mode_invalid"),
+		})
+		return
+	}
+
+	result, err := importUsers(users, mode == "replace")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.import_users_failed", err),
+		})
+		return
+	}
+	if err := refreshUserDirectory(); err != nil {
+		LogSystem(logrus.WarnLevel, "users", "刷新用户目录失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	LogSystem(logrus.InfoLevel, "users", "用户导入完成", map[string]interface{}{
+		"mode":    mode,
+		"created": result.Created,
+		"updated": result.Updated,
+		"deleted": result.Deleted,
+		"skipped": result.Skipped,
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.import_users_ok"),
+		"data":    result,
+	})
+}
+
+// ExportUsersHandler 导出 userlist.json 格式的用户列表
+func ExportUsersHandler(c *gin.Context) {
+	users, err := GetUsers("")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_users_failed", err),
+		})
+		return
+	}
+
+	list := make([]UserInfo, 0, len(users))
+	for _, user := range users {
+		list = append(list, UserInfo{Name: user.Name, EName: user.EName, Email: user.Email})
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="userlist.json"`)
+	c.JSON(http.StatusOK, list)
+}
+
+// GetMissingContactUsersHandler 查询缺少联系方式的用户，这些用户的告警会发送给管理员
+func GetMissingContactUsersHandler(c *gin.Context) {
+	users := missingContactUsers()
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": apiMessage(c, "api.get_missing_contact_ok"),
+		"data":    users,
+		"total":   len(users),
+	})
+}
+
+// respondUserChanged 刷新用户目录缓存并返回最新的用户信息，messageKey 为成功消息的 key
+func respondUserChanged(c *gin.Context, status int, messageKey, eName string) {
+	if err := refreshUserDirectory(); err != nil {
+		LogSystem(logrus.WarnLevel, "users", "刷新用户目录失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	user, err := GetUserByEName(eName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_users_failed", err),
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": apiMessage(c, messageKey),
+		"data":    user,
+	})
+}
+
+// loadUserFromPath 根据路径参数加载用户，失败时直接写入响应
+func loadUserFromPath(c *gin.Context) (*User, bool) {
+	user, err := GetUserByEName(c.Param("ename"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": apiMessage(c, "api.get_users_failed", err),
+		})
+		return nil, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": apiMessage(c, "api.user_not_found"),
+		})
+		return nil, false
+	}
+	return user, true
+}